@@ -8,10 +8,13 @@ import (
 	"os"
 	"os/signal"
 	"process-video-service/internal/adapters/ffmpeg"
+	"process-video-service/internal/adapters/keystore"
 	"process-video-service/internal/adapters/rabbitmq"
 	"process-video-service/internal/adapters/s3"
 	"process-video-service/internal/app"
 	"process-video-service/internal/config"
+	"process-video-service/internal/interfaces"
+	"process-video-service/internal/keyserver"
 	"syscall"
 	"time"
 )
@@ -32,7 +35,16 @@ func main() {
 
 	ffmpeg := ffmpeg.NewFFMPEGProcessor(s3Client, cfg.BucketProcessedName, cfg.EnableGPUProcess, cfg.EnableGPUScaleNPP)
 
-	processor := app.NewProcessor(cfg, rmqConn, s3Client, ffmpeg, cfg.BucketProcessedName)
+	var keyStore interfaces.KeyStore
+	if cfg.KeyStore == "postgres" {
+		pgStore := keystore.NewPostgresStore(cfg.KeyStoreDatabaseURL)
+		defer pgStore.Close()
+		keyStore = pgStore
+	} else {
+		keyStore = keystore.NewFileStore(cfg.KeyStorePath)
+	}
+
+	processor := app.NewProcessor(cfg, rmqConn, s3Client, ffmpeg, keyStore, cfg.BucketProcessedName)
 
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -42,6 +54,18 @@ func main() {
 		})
 	})
 
+	if cfg.PlaybackTokenSecret != "" {
+		keys := keyserver.NewHandler(keyStore, cfg.PlaybackTokenSecret)
+		http.HandleFunc("GET /keys/{epId}/{keyId}", keys.Key)
+		http.HandleFunc("POST /keys/{epId}/{keyId}/license", keys.License)
+		http.HandleFunc("OPTIONS /keys/{epId}/{keyId}", keys.Preflight)
+		http.HandleFunc("OPTIONS /keys/{epId}/{keyId}/license", keys.Preflight)
+		// episodes encrypted before keys had ids
+		http.HandleFunc("GET /keys/{epId}", keys.Key)
+		http.HandleFunc("POST /keys/{epId}/license", keys.License)
+		http.HandleFunc("OPTIONS /keys/{epId}", keys.Preflight)
+	}
+
 	server := &http.Server{
 		Addr:         ":" + cfg.Port,
 		ReadTimeout:  5 * time.Second,
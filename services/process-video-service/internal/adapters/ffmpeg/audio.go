@@ -0,0 +1,60 @@
+package ffmpeg
+
+import (
+	"strconv"
+
+	"process-video-service/internal/models"
+)
+
+// stereoDownmixes use the ITU-R BS.775 coefficients without normalizing, so dialogue keeps its level.
+var stereoDownmixes = map[string]string{
+	"5.1":       "pan=stereo|FL=FL+0.707*FC+0.707*BL|FR=FR+0.707*FC+0.707*BR",
+	"5.1(side)": "pan=stereo|FL=FL+0.707*FC+0.707*SL|FR=FR+0.707*FC+0.707*SR",
+	"7.1":       "pan=stereo|FL=FL+0.707*FC+0.707*SL+0.5*BL|FR=FR+0.707*FC+0.707*SR+0.5*BR",
+}
+
+func channelFilter(track models.AudioTrack) string {
+	if track.Surround {
+		return "aformat=channel_layouts=5.1|5.1(side)"
+	}
+	if pan, ok := stereoDownmixes[track.ChannelLayout]; ok {
+		return pan
+	}
+	return "aformat=channel_layouts=stereo"
+}
+
+func audioCodecArgs(track models.AudioTrack, surroundCodec string) []string {
+	if !track.Surround {
+		return []string{"-c:a", "aac", "-b:a", "128k"}
+	}
+	switch surroundCodec {
+	case models.SurroundCodecAC3:
+		return []string{"-c:a", "ac3", "-b:a", "448k"}
+	case models.SurroundCodecAAC:
+		return []string{"-c:a", "aac", "-b:a", "384k"}
+	}
+	return []string{"-c:a", "eac3", "-b:a", "384k"}
+}
+
+// audioDir is the directory of the rendition under videos/<epId>/audio/ (0, 0-surround).
+func audioDir(track models.AudioTrack) string {
+	dir := strconv.Itoa(track.Index)
+	if track.Surround {
+		dir += "-surround"
+	}
+	return dir
+}
+
+// defaultAudioTrack is the source track flagged as default, or the first one.
+func defaultAudioTrack(tracks []models.AudioTrack) (models.AudioTrack, bool) {
+	if len(tracks) == 0 {
+		return models.AudioTrack{}, false
+	}
+	track := tracks[0]
+	for _, t := range tracks {
+		if t.Default {
+			track = t
+		}
+	}
+	return track, true
+}
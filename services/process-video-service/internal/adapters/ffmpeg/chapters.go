@@ -0,0 +1,176 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+// frames a quarter, half and three quarters into the chapter, away from its edges
+var chapterCandidates = []float64{0.25, 0.5, 0.75}
+
+var sceneTime = regexp.MustCompile(`pts_time:([0-9.]+)`)
+
+// DetectScenes returns the times where the scene change score exceeds threshold.
+func (f *FFMPEGProcessor) DetectScenes(ctx context.Context, job *models.Job, threshold float64) ([]float64, error) {
+	stream, err := f.bucket.GetObjectStream(job.Event.Bucket, job.Event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-i", "pipe:0",
+		"-map", "0:v:0", "-an", "-sn",
+		// the score barely depends on resolution, decoding small is enough
+		"-vf", fmt.Sprintf("scale=320:-2,select='gt(scene,%g)',metadata=mode=print", threshold),
+		"-f", "null", "-",
+	)
+	cmd.Stdin = stream
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro na detecção de cenas: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	var cuts []float64
+	for _, m := range sceneTime.FindAllStringSubmatch(stderr.String(), -1) {
+		cuts = append(cuts, parseFloat(m[1]))
+	}
+	return cuts, nil
+}
+
+// GenerateChapters picks a thumbnail for every chapter and uploads the thumbnails and
+// a WebVTT chapters track under videos/<epId>/chapters/.
+func (f *FFMPEGProcessor) GenerateChapters(ctx context.Context, job *models.Job, chapters []models.Chapter, opts models.ChapterOptions) (*models.ChapterAssets, error) {
+	event := job.Event
+
+	tmp := filepath.Join(f.tmpDir, fmt.Sprintf("%s-chapters", event.Key))
+	framesDir := filepath.Join(tmp, "frames")
+	os.MkdirAll(framesDir, 0755)
+	defer os.RemoveAll(tmp)
+
+	// select the first frame at or after every candidate time, in a single pass; close
+	// candidates can land on the same frame, so showinfo logs which ones came out
+	var terms []string
+	for _, c := range chapters {
+		for _, at := range chapterCandidates {
+			t := c.Start + (c.End-c.Start)*at
+			terms = append(terms, fmt.Sprintf("gte(t,%.3f)*lt(prev_t,%.3f)", t, t))
+		}
+	}
+	filter := fmt.Sprintf("select='%s',showinfo,", strings.Join(terms, "+")) + sourceFilter(job) + fmt.Sprintf("scale=%d:-2", opts.ThumbnailWidth)
+	if job.Info.HDR() {
+		filter += toneMapFilter
+	}
+
+	stream, err := f.bucket.GetObjectStream(event.Bucket, event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-i", "pipe:0",
+		"-map", "0:v:0", "-an", "-sn",
+		"-vf", filter,
+		"-fps_mode", "vfr",
+		"-q:v", "3",
+		filepath.Join(framesDir, "frame%05d.jpg"),
+	)
+	cmd.Stdin = stream
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro ao extrair frames dos capítulos: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	frames, err := os.ReadDir(framesDir)
+	if err != nil {
+		return nil, err
+	}
+	var frameTimes []float64
+	for _, m := range sceneTime.FindAllStringSubmatch(stderr.String(), -1) {
+		frameTimes = append(frameTimes, parseFloat(m[1]))
+	}
+	frameTimes = frameTimes[:min(len(frameTimes), len(frames))]
+
+	s3Prefix := fmt.Sprintf("videos/%s/chapters", event.EpId)
+	assets := &models.ChapterAssets{VTTKey: s3Prefix + "/chapters.vtt"}
+	for n, chapter := range chapters {
+		var best *frameScore
+		for _, i := range chapterFrames(chapter, frameTimes) {
+			score, err := scoreFrame(filepath.Join(framesDir, frames[i].Name()))
+			if err != nil {
+				continue
+			}
+			score.Time = frameTimes[i]
+			// a rejected (black, washed out) frame only wins when nothing better exists
+			if best == nil || best.Rejected && !score.Rejected || best.Rejected == score.Rejected && score.Score > best.Score {
+				best = &score
+			}
+		}
+		if best != nil {
+			file, err := os.Open(best.Path)
+			if err != nil {
+				return nil, err
+			}
+			key := fmt.Sprintf("%s/%d.jpg", s3Prefix, n)
+			err = f.bucket.UploadFileReader(f.processedBucketName, key, file)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+			chapter.ThumbnailKey = key
+		}
+		assets.Chapters = append(assets.Chapters, chapter)
+	}
+
+	if err := f.bucket.UploadFileReader(f.processedBucketName, assets.VTTKey, bytes.NewReader(chaptersVTT(assets.Chapters))); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// chapterFrames maps the chapter's candidates to the extracted frames by their time.
+func chapterFrames(chapter models.Chapter, frameTimes []float64) []int {
+	var picked []int
+	for _, at := range chapterCandidates {
+		t := chapter.Start + (chapter.End-chapter.Start)*at
+		// the select compared against t rounded to the millisecond
+		i := sort.SearchFloat64s(frameTimes, math.Round(t*1000)/1000-0.0005)
+		if i < len(frameTimes) && frameTimes[i] < chapter.End && !slices.Contains(picked, i) {
+			picked = append(picked, i)
+		}
+	}
+	return picked
+}
+
+func chaptersVTT(chapters []models.Chapter) []byte {
+	var vtt bytes.Buffer
+	vtt.WriteString("WEBVTT\n\n")
+	for i, c := range chapters {
+		// embedded titles may carry line breaks, which would end the cue
+		title := strings.Join(strings.Fields(c.Title), " ")
+		fmt.Fprintf(&vtt, "%d\n%s --> %s\n%s\n\n", i+1, formatVTTTimestamp(c.Start), formatVTTTimestamp(c.End), title)
+	}
+	return vtt.Bytes()
+}
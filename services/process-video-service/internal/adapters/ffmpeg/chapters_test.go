@@ -0,0 +1,66 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChapterFrames(t *testing.T) {
+	tests := []struct {
+		name       string
+		chapter    models.Chapter
+		frameTimes []float64
+		want       []int
+	}{
+		{
+			name:       "one frame per candidate",
+			chapter:    models.Chapter{Start: 0, End: 40},
+			frameTimes: []float64{10, 20.04, 30, 55},
+			want:       []int{0, 1, 2},
+		},
+		{
+			// a single frame came out for candidates 10s and 20s: the select merged them
+			name:       "merged candidates",
+			chapter:    models.Chapter{Start: 0, End: 40},
+			frameTimes: []float64{20.5, 30, 55},
+			want:       []int{0, 1},
+		},
+		{
+			name:       "second chapter skips the first one's frames",
+			chapter:    models.Chapter{Start: 40, End: 80},
+			frameTimes: []float64{10, 20, 30, 50, 60, 70},
+			want:       []int{3, 4, 5},
+		},
+		{
+			name:       "candidate rounded to the millisecond",
+			chapter:    models.Chapter{Start: 0, End: 10.0002},
+			frameTimes: []float64{2.5, 5, 7.5},
+			want:       []int{0, 1, 2},
+		},
+		{
+			name:       "no frame before the chapter ends",
+			chapter:    models.Chapter{Start: 40, End: 80},
+			frameTimes: []float64{10, 20, 30, 90},
+			want:       nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, chapterFrames(tt.chapter, tt.frameTimes))
+		})
+	}
+}
+
+func TestChaptersVTT(t *testing.T) {
+	chapters := []models.Chapter{
+		{Start: 0, End: 61.5, Title: "Abertura"},
+		{Start: 61.5, End: 3725.25, Title: "Parte\n  dois"},
+	}
+	want := "WEBVTT\n\n" +
+		"1\n00:00:00.000 --> 00:01:01.500\nAbertura\n\n" +
+		"2\n00:01:01.500 --> 01:02:05.250\nParte dois\n\n"
+	assert.Equal(t, want, string(chaptersVTT(chapters)))
+}
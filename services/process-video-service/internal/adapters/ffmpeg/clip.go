@@ -0,0 +1,107 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"process-video-service/internal/models"
+)
+
+// ExtractClip cuts the planned renditions into a Matroska file uploaded to bucket/key:
+// to the exact frame into a near-lossless intermediate in reencode mode, copying the
+// streams in copy mode, where the plan already starts on a keyframe.
+func (f *FFMPEGProcessor) ExtractClip(ctx context.Context, plan models.ClipPlan, bucket, key string) (*models.ClipCut, error) {
+	tmp := filepath.Join(f.tmpDir, "clip-"+path.Base(key))
+	os.MkdirAll(tmp, 0755)
+	defer os.RemoveAll(tmp)
+
+	inputs := append([]models.ClipInput{plan.Video}, plan.Audio...)
+	files := make([]string, len(inputs))
+	for i, input := range inputs {
+		files[i] = filepath.Join(tmp, fmt.Sprintf("%d%s", i, segmentsExt(input.Keys)))
+		if err := f.concatSegments(input.Keys, files[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// -ss is relative to the first frame of each input, which starts at input.Start
+	args := []string{"-hide_banner", "-nostats", "-y"}
+	for i, input := range inputs {
+		args = append(args, "-ss", strconv.FormatFloat(max(plan.In-input.Start, 0), 'f', 6, 64), "-i", files[i])
+	}
+	args = append(args, "-map", "0:v:0")
+	for i, input := range plan.Audio {
+		args = append(args,
+			"-map", fmt.Sprintf("%d:a:0", i+1),
+			fmt.Sprintf("-metadata:s:a:%d", i), "language="+input.Track.Language,
+			fmt.Sprintf("-metadata:s:a:%d", i), "title="+input.Track.Name,
+		)
+		disposition := "0"
+		if input.Track.Default {
+			disposition = "default"
+		}
+		args = append(args, fmt.Sprintf("-disposition:a:%d", i), disposition)
+	}
+	if plan.Mode == models.ClipModeCopy {
+		args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+	} else {
+		args = append(args, "-c:v", "libx264", "-preset", "fast", "-crf", "12", "-c:a", "flac")
+	}
+	output := filepath.Join(tmp, "clip.mkv")
+	args = append(args, "-t", strconv.FormatFloat(plan.Out-plan.In, 'f', 6, 64), "-f", "matroska", output)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro ao cortar: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := f.bucket.UploadStream(bucket, key, file); err != nil {
+		return nil, err
+	}
+	return &models.ClipCut{Mode: plan.Mode, In: plan.In, Out: plan.Out}, nil
+}
+
+// concatenated TS segments are a TS file, an init segment and its fragments an fMP4
+func segmentsExt(keys []string) string {
+	if len(keys) > 0 && path.Ext(keys[len(keys)-1]) == ".ts" {
+		return ".ts"
+	}
+	return ".mp4"
+}
+
+func (f *FFMPEGProcessor) concatSegments(keys []string, dest string) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	for _, key := range keys {
+		stream, err := f.bucket.GetObjectStream(f.processedBucketName, key)
+		if err != nil {
+			return fmt.Errorf("erro ao ler %s: %w", key, err)
+		}
+		_, err = io.Copy(out, stream)
+		stream.Close()
+		if err != nil {
+			return fmt.Errorf("erro ao ler %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+// AnalyzeComplexity encodes evenly spaced scenes of the source at every ladder height
+// with a fixed CRF and reports the bitrate each height needed for that quality. It
+// always uses libx264 so results are comparable across workers with and without GPUs.
+func (f *FFMPEGProcessor) AnalyzeComplexity(ctx context.Context, job *models.Job, opts models.ComplexityOptions) (*models.ComplexityAnalysis, error) {
+	event, info := job.Event, job.Info
+
+	var heights []int
+	for _, rung := range job.Ladder {
+		if !slices.Contains(heights, rung.Height) {
+			heights = append(heights, rung.Height)
+		}
+	}
+	if len(heights) == 0 {
+		return nil, fmt.Errorf("ladder vazio")
+	}
+
+	tmp := filepath.Join(f.tmpDir, fmt.Sprintf("%s-complexity", event.Key))
+	os.MkdirAll(tmp, 0755)
+	defer os.RemoveAll(tmp)
+
+	// one pass decodes the source once and keeps only the sampled scenes, split per height
+	filter := "[0:v:0]" + sourceFilter(job) + sampleFilter(info.Duration, opts.Samples, opts.SampleSeconds)
+	filter += fmt.Sprintf("split=%d", len(heights))
+	for i := range heights {
+		filter += fmt.Sprintf("[s%d]", i)
+	}
+	for i, h := range heights {
+		w, sh := renditionSize(job, h)
+		filter += fmt.Sprintf(";[s%d]scale=%d:%d[o%d]", i, w, sh, i)
+	}
+
+	args := []string{"-v", "error", "-i", "pipe:0", "-filter_complex", filter}
+	outputs := make([]string, len(heights))
+	for i, h := range heights {
+		outputs[i] = filepath.Join(tmp, fmt.Sprintf("%dp.mp4", h))
+		args = append(args,
+			"-map", fmt.Sprintf("[o%d]", i),
+			"-an", "-sn",
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", fmt.Sprint(opts.CRF),
+			"-pix_fmt", "yuv420p",
+			outputs[i],
+		)
+	}
+
+	stream, err := f.bucket.GetObjectStream(event.Bucket, event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = stream
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro na codificação de análise: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	analysis := &models.ComplexityAnalysis{CRF: opts.CRF}
+	for i, h := range heights {
+		stat, err := os.Stat(outputs[i])
+		if err != nil {
+			return nil, err
+		}
+		seconds, err := probeDuration(outputs[i])
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("erro ao medir amostra %dp: %v", h, err)
+		}
+		analysis.SampledSeconds = seconds
+		analysis.Probes = append(analysis.Probes, models.ComplexityProbe{
+			Height:  h,
+			Bitrate: int(float64(stat.Size()*8) / seconds),
+		})
+	}
+	return analysis, nil
+}
+
+// sampleFilter is empty or ends with a comma.
+func sampleFilter(duration float64, samples int, seconds float64) string {
+	if samples <= 0 || duration <= float64(samples)*seconds {
+		return ""
+	}
+	interval := duration / float64(samples)
+	return fmt.Sprintf("select='lt(mod(t\\,%g)\\,%g)',setpts=N/FRAME_RATE/TB,", interval, seconds)
+}
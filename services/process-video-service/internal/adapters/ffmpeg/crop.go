@@ -0,0 +1,84 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"process-video-service/internal/models"
+)
+
+// reset=1 lets every frame vote on its own
+const cropDetectFilter = "cropdetect=limit=24:round=2:reset=1"
+
+var cropLine = regexp.MustCompile(`crop=(-?\d+):(-?\d+):(\d+):(\d+)`)
+
+// DetectCrop runs cropdetect over sampled scenes of the source and returns the rectangle
+// most frames agreed on, with the share of frames that did as confidence.
+func (f *FFMPEGProcessor) DetectCrop(ctx context.Context, job *models.Job, opts models.CropOptions) (*models.CropDetection, error) {
+	event, info := job.Event, job.Info
+
+	filter := sampleFilter(info.Duration, opts.Samples, opts.SampleSeconds)
+	if job.Deinterlace != "" {
+		filter = job.Deinterlace + "," + filter
+	}
+	args := []string{
+		"-hide_banner", "-nostats",
+		"-i", "pipe:0",
+		"-map", "0:v:0", "-an", "-sn",
+		"-vf", filter + cropDetectFilter,
+		"-f", "null", "-",
+	}
+
+	stream, err := f.bucket.GetObjectStream(event.Bucket, event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = stream
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro na detecção de bordas: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	votes := map[models.Crop]int{}
+	total := 0
+	for _, m := range cropLine.FindAllStringSubmatch(stderr.String(), -1) {
+		var c models.Crop
+		c.Width, _ = strconv.Atoi(m[1])
+		c.Height, _ = strconv.Atoi(m[2])
+		c.X, _ = strconv.Atoi(m[3])
+		c.Y, _ = strconv.Atoi(m[4])
+		total++
+		// all-black frames report an empty or inverted rectangle; they still count against confidence
+		if c.Width <= 0 || c.Height <= 0 {
+			continue
+		}
+		votes[c]++
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("cropdetect não retornou resultados")
+	}
+
+	best, count := models.Crop{}, 0
+	for c, n := range votes {
+		// ties go to the larger rectangle so picture is never cut on a coin flip
+		if n > count || n == count && c.Width*c.Height > best.Width*best.Height {
+			best, count = c, n
+		}
+	}
+	return &models.CropDetection{
+		Rect:       best,
+		Confidence: float64(count) / float64(total),
+		Source:     models.CropSourceDetected,
+	}, nil
+}
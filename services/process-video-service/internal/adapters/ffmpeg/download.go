@@ -0,0 +1,84 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"process-video-service/internal/models"
+)
+
+// ProcessDownload remuxes the planned renditions into a single MP4 with the moov atom
+// up front, so it plays while still downloading, and uploads it under
+// videos/<epId>/download/. Nothing is encoded again: the download is the streamed
+// picture and sound.
+func (f *FFMPEGProcessor) ProcessDownload(ctx context.Context, job *models.Job, plan models.DownloadPlan) (*models.Download, error) {
+	tmp := filepath.Join(f.tmpDir, fmt.Sprintf("%s-download-%s", job.Event.Key, plan.Name))
+	os.MkdirAll(tmp, 0755)
+	defer os.RemoveAll(tmp)
+
+	video := filepath.Join(tmp, "video"+segmentsExt(plan.VideoKeys))
+	if err := f.concatSegments(plan.VideoKeys, video); err != nil {
+		return nil, err
+	}
+	args := []string{"-hide_banner", "-nostats", "-i", video}
+	if len(plan.AudioKeys) > 0 {
+		audio := filepath.Join(tmp, "audio"+segmentsExt(plan.AudioKeys))
+		if err := f.concatSegments(plan.AudioKeys, audio); err != nil {
+			return nil, err
+		}
+		args = append(args, "-i", audio, "-map", "0:v:0", "-map", "1:a:0")
+	} else {
+		args = append(args, "-map", "0:v:0")
+	}
+
+	output := filepath.Join(tmp, plan.Name+".mp4")
+	args = append(args, "-c", "copy", "-movflags", "+faststart", "-f", "mp4", output)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro ao gerar download: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	stat, err := os.Stat(output)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := probeDuration(output)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler duração do download: %w", err)
+	}
+	streams, err := probeSegment(output)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler streams do download: %w", err)
+	}
+
+	download := &models.Download{
+		Width:    streams.Width,
+		Height:   streams.Height,
+		Key:      fmt.Sprintf("videos/%s/download/%s.mp4", job.Event.EpId, plan.Name),
+		Size:     stat.Size(),
+		Duration: duration,
+	}
+	if duration > 0 {
+		download.Bitrate = int(float64(download.Size*8) / duration)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := f.bucket.UploadStream(f.processedBucketName, download.Key, file); err != nil {
+		return nil, err
+	}
+	return download, nil
+}
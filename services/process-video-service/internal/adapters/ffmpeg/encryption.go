@@ -0,0 +1,93 @@
+package ffmpeg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"process-video-service/internal/hls"
+	"process-video-service/internal/models"
+)
+
+// ffmpeg only implements cenc-aes-ctr, in the fMP4 sub-muxer.
+func encryptionArgs(tmp string, enc *models.Encryption) ([]string, error) {
+	switch enc.Scheme {
+	case models.EncryptionAES128:
+		keyInfo, err := writeKeyInfo(tmp, enc)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"-hls_key_info_file", keyInfo}, nil
+	case models.EncryptionCENC:
+		opts := fmt.Sprintf("encryption_scheme=cenc-aes-ctr:encryption_key=%s:encryption_kid=%s",
+			hex.EncodeToString(enc.Key), hex.EncodeToString(enc.KID))
+		return []string{"-hls_segment_options", opts}, nil
+	}
+	return nil, fmt.Errorf("criptografia desconhecida: %s", enc.Scheme)
+}
+
+// CENC is signalled as SAMPLE-AES-CTR with the ClearKey key format.
+func playlistKey(enc *models.Encryption) *hls.Key {
+	if enc.Scheme == models.EncryptionCENC {
+		return &hls.Key{
+			Method:            "SAMPLE-AES-CTR",
+			URI:               enc.LicenseURL,
+			KeyFormat:         "org.w3.clearkey",
+			KeyFormatVersions: "1",
+		}
+	}
+	return &hls.Key{Method: "AES-128", URI: enc.KeyURI, IV: "0x" + hex.EncodeToString(enc.IV)}
+}
+
+func writeKeyInfo(tmp string, enc *models.Encryption) (string, error) {
+	keyPath := filepath.Join(tmp, "enc.key")
+	if err := os.WriteFile(keyPath, enc.Key, 0600); err != nil {
+		return "", err
+	}
+	infoPath := filepath.Join(tmp, "enc.keyinfo")
+	info := fmt.Sprintf("%s\n%s\n%s\n", enc.KeyURI, keyPath, hex.EncodeToString(enc.IV))
+	if err := os.WriteFile(infoPath, []byte(info), 0600); err != nil {
+		return "", err
+	}
+	return infoPath, nil
+}
+
+// decryptSegment writes a clear copy of an AES-128-CBC segment so ffprobe can read it.
+func decryptSegment(path string, enc *models.Encryption) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	data, err = decryptAES128(data, enc)
+	if err != nil {
+		return "", err
+	}
+
+	clear := path + ".clear"
+	if err := os.WriteFile(clear, data, 0600); err != nil {
+		return "", err
+	}
+	return clear, nil
+}
+
+// decryptAES128 undoes whole-segment AES-128-CBC encryption in place.
+func decryptAES128(data []byte, enc *models.Encryption) ([]byte, error) {
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("segmento cifrado com tamanho inválido: %d", len(data))
+	}
+	block, err := aes.NewCipher(enc.Key)
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCBCDecrypter(block, enc.IV).CryptBlocks(data, data)
+
+	// strip PKCS#7 padding
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, fmt.Errorf("padding inválido")
+	}
+	return data[:len(data)-pad], nil
+}
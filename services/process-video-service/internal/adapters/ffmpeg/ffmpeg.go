@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"math"
 	"os"
 	"os/exec"
@@ -13,14 +12,16 @@ import (
 	"strings"
 	"time"
 
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/hls"
 	"process-video-service/internal/interfaces"
 	"process-video-service/internal/models"
 )
 
-type segmentInfo struct {
-	Name     string
-	Duration float64
-}
+// hlsTime is the target segment length, in seconds, for every rendition.
+const hlsTime = 10
+
+const fmp4InitName = "init.mp4"
 
 type FFMPEGProcessor struct {
 	bucket              interfaces.Bucket
@@ -40,68 +41,291 @@ func NewFFMPEGProcessor(bucket interfaces.Bucket, processedBucketName string, en
 	}
 }
 
-func (f *FFMPEGProcessor) GetHeight(ctx context.Context, bucket, key string) (int, error) {
-	h, err := f.getVideoHeightPartial(bucket, key, "bytes=0-2097152")
-	if err == nil {
-		return h, nil
+func (f *FFMPEGProcessor) Process(ctx context.Context, job *models.Job, rung models.Rung) (*models.Rendition, error) {
+	rendition, err := f.processResolution(ctx, job, rung)
+	if err != nil {
+		return nil, err
 	}
-	return f.getVideoHeightFull(bucket, key)
+	rendition.VideoCodec = rung.Codec
+	rendition.VideoRange = rungRange(job, rung)
+	return rendition, nil
 }
 
-func (f *FFMPEGProcessor) Process(ctx context.Context, event models.UploadEvent, resolution int) error {
-	if err := f.processResolution(ctx, event, resolution); err != nil {
-		return err
+func (f *FFMPEGProcessor) ProcessAudio(ctx context.Context, job *models.Job, track models.AudioTrack) (*models.AudioRendition, error) {
+	dir := audioDir(track)
+	tmp := filepath.Join(f.tmpDir, fmt.Sprintf("%s-audio-%s", job.Event.Key, dir))
+	os.MkdirAll(tmp, 0755)
+	defer os.RemoveAll(tmp)
+
+	args := []string{
+		"-i", "pipe:0",
+		"-map", fmt.Sprintf("0:a:%d", track.Index),
+		"-vn",
 	}
-	return nil
+
+	filter := channelFilter(track)
+	var loudness *models.Loudness
+	if job.Loudness != nil {
+		measured, stats, err := f.measureLoudness(ctx, job, track, filter)
+		if err != nil {
+			return nil, err
+		}
+		if measured != nil {
+			filter += "," + loudnormFilter(*job.Loudness, stats)
+			loudness = measured
+		}
+	}
+	args = append(args, "-af", filter)
+	if loudness != nil {
+		// loudnorm resamples internally; pin the output back to 48kHz
+		args = append(args, "-ar", "48000")
+	}
+	args = append(args, audioCodecArgs(track, job.SurroundCodec)...)
+
+	rendition, err := f.encodeHLS(ctx, job, args, tmp, fmt.Sprintf("videos/%s/audio/%s", job.Event.EpId, dir), false)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AudioRendition{AudioTrack: track, Rendition: *rendition, Loudness: loudness}, nil
 }
 
-func (f *FFMPEGProcessor) processResolution(ctx context.Context, event models.UploadEvent, resolution int) error {
-	tmp := filepath.Join(f.tmpDir, fmt.Sprintf("%s-%dp", event.Key, resolution))
+func (f *FFMPEGProcessor) processResolution(ctx context.Context, job *models.Job, rung models.Rung) (*models.Rendition, error) {
+	name := helpers.RenditionName(rung)
+	tmp := filepath.Join(f.tmpDir, fmt.Sprintf("%s-%s", job.Event.Key, name))
 	os.MkdirAll(tmp, 0755)
 	defer os.RemoveAll(tmp)
 
-	segmentPattern := filepath.Join(tmp, "seg%03d.ts")
+	resolution := rung.Height
+	outRange := rungRange(job, rung)
 
-	stream, err := f.bucket.GetObjectStream(event.Bucket, event.Key)
-	if err != nil {
-		return err
+	// audio is packaged once per source track by ProcessAudio, so video renditions carry no audio
+	args := []string{"-i", "pipe:0", "-map", "0:v:0", "-an"}
+	switch {
+	case rung.Copy:
+		args = append(args, "-c:v", "copy")
+	case rung.Codec == models.CodecHEVC && rung.HDR:
+		args = append(args, hdrHEVCArgs(outRange)...)
+		args = append(args, "-vf", videoFilter(job, "scale", resolution, outRange))
+	case rung.Codec == models.CodecHEVC:
+		args = append(args,
+			"-c:v", "libx265", "-preset", "fast", "-crf", "26", "-tag:v", "hvc1",
+			"-x265-params", "log-level=error",
+			"-pix_fmt", "yuv420p",
+			"-vf", videoFilter(job, "scale", resolution, outRange),
+		)
+	case rung.Codec == models.CodecAV1:
+		args = append(args,
+			"-c:v", "libsvtav1", "-preset", "8", "-crf", "35",
+			"-pix_fmt", "yuv420p",
+			"-vf", videoFilter(job, "scale", resolution, outRange),
+		)
+	default:
+		args = append(args, f.h264Args(job, resolution)...)
 	}
-	defer stream.Close()
+	args = append(args, rateControlArgs(rung, f.enableGpuProcess)...)
 
-	args := []string{"-i", "pipe:0"}
-	if f.enableGpuProcess {
-		args = append(args, "-c:v", "h264_nvenc", "-preset", "fast")
-		if f.enableGPUScaleNPP {
-			args = append(args, "-vf", fmt.Sprintf("scale_npp=-2:%d", resolution))
-		} else {
-			args = append(args, "-vf", fmt.Sprintf("scale=-2:%d", resolution))
-		}
-	} else {
-		args = append(args, "-c:v", "libx264", "-preset", "fast", "-vf", fmt.Sprintf("scale=-2:%d", resolution))
+	return f.encodeHLS(ctx, job, args, tmp, fmt.Sprintf("videos/%s/%s", job.Event.EpId, name), rung.IFrames)
+}
+
+func (f *FFMPEGProcessor) h264Args(job *models.Job, height int) []string {
+	if !f.enableGpuProcess {
+		return []string{"-c:v", "libx264", "-preset", "fast", "-vf", videoFilter(job, "scale", height, models.VideoRangeSDR)}
+	}
+	// tone mapping and overlays run in software, so their frames can't go through scale_npp
+	scaler := "scale"
+	if f.enableGPUScaleNPP && !job.Info.HDR() && job.Overlay == nil {
+		scaler = "scale_npp"
+	}
+	return []string{"-c:v", "h264_nvenc", "-preset", "fast", "-vf", videoFilter(job, scaler, height, models.VideoRangeSDR)}
+}
+
+func rungRange(job *models.Job, rung models.Rung) string {
+	if rung.HDR && job.Info.HDR() {
+		return job.Info.VideoRange
+	}
+	return models.VideoRangeSDR
+}
+
+// hdrHEVCArgs repeats the parameter sets so every segment carries the HDR signalling.
+func hdrHEVCArgs(videoRange string) []string {
+	transfer := "smpte2084"
+	x265 := "log-level=error:repeat-headers=1:hdr10-opt=1"
+	if videoRange == models.VideoRangeHLG {
+		transfer = "arib-std-b67"
+		x265 = "log-level=error:repeat-headers=1"
+	}
+	x265 += ":colorprim=bt2020:colormatrix=bt2020nc:transfer=" + transfer
+	return []string{
+		"-c:v", "libx265", "-preset", "fast", "-crf", "24", "-tag:v", "hvc1",
+		"-profile:v", "main10",
+		"-pix_fmt", "yuv420p10le",
+		"-color_primaries", "bt2020", "-color_trc", transfer, "-colorspace", "bt2020nc",
+		"-x265-params", x265,
+	}
+}
+
+// toneMapFilter starts with a comma.
+const toneMapFilter = ",zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709," +
+	"tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p"
+
+func videoFilter(job *models.Job, scaler string, height int, videoRange string) string {
+	w, h := renditionSize(job, height)
+	filter := sourceFilter(job) + fmt.Sprintf("%s=%d:%d", scaler, w, h)
+	if job.Info.HDR() && videoRange == models.VideoRangeSDR {
+		filter += toneMapFilter
+	}
+	return filter + overlayFilter(job, height)
+}
+
+// sourceFilter is empty or ends with a comma.
+func sourceFilter(job *models.Job) string {
+	var filter string
+	if job.Deinterlace != "" {
+		filter += job.Deinterlace + ","
+	}
+	if c := job.Crop; c != nil {
+		filter += fmt.Sprintf("crop=%d:%d:%d:%d,", c.Width, c.Height, c.X, c.Y)
 	}
+	return filter
+}
+
+// sourceSize is the picture size left after the crop.
+func sourceSize(job *models.Job) (int, int) {
+	if c := job.Crop; c != nil {
+		return c.Width, c.Height
+	}
+	return job.Info.Width, job.Info.Height
+}
+
+// renditionSize keeps the rung's scale factor for a cropped source.
+func renditionSize(job *models.Job, height int) (int, int) {
+	c := job.Crop
+	if c == nil || job.Info == nil || job.Info.Height == 0 {
+		return -2, height
+	}
+	factor := float64(height) / float64(job.Info.Height)
+	return max(evenRound(float64(c.Width)*factor), 2), max(evenRound(float64(c.Height)*factor), 2)
+}
+
+func evenRound(v float64) int {
+	return int(math.Round(v/2)) * 2
+}
 
+func rateControlArgs(rung models.Rung, gpu bool) []string {
+	if rung.MaxBitrate <= 0 {
+		return nil
+	}
+	var args []string
+	if gpu && (rung.Codec == models.CodecH264 || rung.Codec == "") {
+		args = append(args, "-rc", "vbr", "-cq", "23", "-b:v", "0")
+	}
+	return append(args,
+		"-maxrate", strconv.Itoa(rung.MaxBitrate),
+		"-bufsize", strconv.Itoa(rung.MaxBitrate*2),
+	)
+}
+
+// encodeHLS uploads every finished segment and writes the media playlist last.
+func (f *FFMPEGProcessor) encodeHLS(ctx context.Context, job *models.Job, args []string, tmp, s3Prefix string, iframes bool) (*models.Rendition, error) {
+	fmp4 := job.Packaging.SegmentType == models.SegmentTypeFMP4
+	segmentExt := ".ts"
+	if fmp4 {
+		segmentExt = ".m4s"
+	}
+	segmentPattern := filepath.Join(tmp, "seg%03d"+segmentExt)
 	playlistTmp := filepath.Join(tmp, "index.m3u8")
 
+	stream, err := f.bucket.GetObjectStream(job.Event.Bucket, job.Event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
 	args = append(args,
-		"-c:a", "aac",
 		"-f", "hls",
-		"-hls_time", "10",
+		"-hls_time", strconv.Itoa(hlsTime),
 		"-hls_list_size", "0",
+		"-hls_flags", "temp_file",
 		"-hls_segment_filename", segmentPattern,
-		playlistTmp,
 	)
+	if fmp4 {
+		args = append(args, "-hls_segment_type", "fmp4", "-hls_fmp4_init_filename", fmp4InitName)
+	}
+	if job.Encryption != nil {
+		encArgs, err := encryptionArgs(tmp, job.Encryption)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, encArgs...)
+	}
+	args = append(args, playlistTmp)
 
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	cmd.Stdin = stream
 
 	if err := cmd.Start(); err != nil {
-		return err
+		return nil, err
 	}
 
 	uploaded := map[string]bool{}
-	s3Prefix := fmt.Sprintf("videos/%s/%dp", event.EpId, resolution)
 
-	var segments []segmentInfo
+	var segments []models.Segment
+	var streams segmentStreams
+
+	uploadReady := func() error {
+		files, _ := os.ReadDir(tmp)
+		for _, fl := range files {
+			if uploaded[fl.Name()] {
+				continue
+			}
+			uploaded[fl.Name()] = true
+			if !strings.HasSuffix(fl.Name(), segmentExt) {
+				continue
+			}
+
+			localPath := filepath.Join(tmp, fl.Name())
+			file, err := os.Open(localPath)
+			if err != nil {
+				continue
+			}
+
+			if err := f.bucket.UploadFileReader(f.processedBucketName, s3Prefix+"/"+fl.Name(), file); err != nil {
+				file.Close()
+				return err
+			}
+			file.Close()
+
+			// fMP4 fragments can't be probed without the init segment and encrypted
+			// ones not at all; their durations come from ffmpeg's own playlist once it finishes
+			if !fmp4 && len(segments) == 0 {
+				streams, _ = probeEncodedSegment(localPath, job.Encryption)
+			}
+
+			var dur float64
+			if !fmp4 && job.Encryption == nil {
+				dur, _ = probeDuration(localPath)
+			}
+			var size int64
+			if stat, err := os.Stat(localPath); err == nil {
+				size = stat.Size()
+			}
+			segment := models.Segment{
+				Name:     fl.Name(),
+				Duration: dur,
+				Size:     size,
+			}
+			// a segment whose keyframes can't be read is just left out of the I-frame playlist
+			if iframes && fmp4 {
+				segment.IFrames, _ = fmp4Keyframe(localPath)
+			} else if iframes {
+				segment.IFrames, _ = tsKeyframes(localPath)
+			}
+			segments = append(segments, segment)
+
+			os.Remove(localPath)
+		}
+		return nil
+	}
 
 	done := make(chan error, 1)
 	go func() { done <- cmd.Wait() }()
@@ -111,110 +335,141 @@ loop:
 		select {
 		case <-ctx.Done():
 			_ = cmd.Process.Kill()
-			return fmt.Errorf("cancelado pelo contexto")
+			return nil, fmt.Errorf("cancelado pelo contexto")
 		case err := <-done:
 			if err != nil {
-				return err
+				return nil, err
 			}
 			break loop
 		default:
-			files, _ := os.ReadDir(tmp)
-			for _, fl := range files {
-				if uploaded[fl.Name()] {
-					continue
-				}
-				uploaded[fl.Name()] = true
-				if !strings.HasSuffix(fl.Name(), ".ts") {
-					continue
-				}
-
-				localPath := filepath.Join(tmp, fl.Name())
-				file, err := os.Open(localPath)
-				if err != nil {
-					continue
-				}
-
-				if err := f.bucket.UploadFileReader(f.processedBucketName, s3Prefix+"/"+fl.Name(), file); err != nil {
-					file.Close()
-					return err
-				}
-				file.Close()
-
-				dur, _ := probeDuration(localPath)
-				segments = append(segments, segmentInfo{
-					Name:     fl.Name(),
-					Duration: dur,
-				})
-
-				os.Remove(localPath)
+			if err := uploadReady(); err != nil {
+				return nil, err
 			}
 			time.Sleep(50 * time.Millisecond)
 		}
 	}
 
-	// creating index.m3u8
-	var playlist bytes.Buffer
-	playlist.WriteString("#EXTM3U\n")
-	playlist.WriteString("#EXT-X-VERSION:3\n")
+	// the last segments are only renamed right before ffmpeg exits
+	if err := uploadReady(); err != nil {
+		return nil, err
+	}
 
-	maxDur := 0.0
+	durations := localPlaylistDurations(playlistTmp)
+	for i := range segments {
+		if segments[i].Duration == 0 {
+			segments[i].Duration = durations[segments[i].Name]
+		}
+	}
+
+	initKey := ""
+	if fmp4 {
+		initPath := filepath.Join(tmp, fmp4InitName)
+		streams, _ = probeSegment(initPath)
+
+		file, err := os.Open(initPath)
+		if err != nil {
+			return nil, err
+		}
+		initKey = s3Prefix + "/" + fmp4InitName
+		err = f.bucket.UploadFileReader(f.processedBucketName, initKey, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.uploadMediaPlaylist(s3Prefix, segments, initKey, job.Encryption); err != nil {
+		return nil, err
+	}
+
+	rendition := renditionFromSegments(segments, s3Prefix+"/index.m3u8")
+	rendition.InitKey = initKey
+	if iframes {
+		fillIFrameDurations(segments)
+		rendition.IFramePlaylistKey, rendition.IFrameBitrate, err = f.uploadIFramePlaylist(s3Prefix, segments, initKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rendition.Codecs = streams.Codecs
+	rendition.Width = streams.Width
+	rendition.Height = streams.Height
+	return rendition, nil
+}
+
+func renditionFromSegments(segments []models.Segment, playlistKey string) *models.Rendition {
+	rendition := &models.Rendition{PlaylistKey: playlistKey, Segments: segments}
 	for _, s := range segments {
-		if s.Duration > maxDur {
-			maxDur = s.Duration
+		rendition.Bytes += s.Size
+		rendition.Duration += s.Duration
+		if s.Duration > 0 {
+			rendition.PeakBitrate = max(rendition.PeakBitrate, int(float64(s.Size*8)/s.Duration))
 		}
 	}
-	playlist.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(maxDur))))
-	playlist.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	if rendition.Duration > 0 {
+		rendition.Bitrate = int(float64(rendition.Bytes*8) / rendition.Duration)
+	}
+	return rendition
+}
+
+func (f *FFMPEGProcessor) uploadMediaPlaylist(s3Prefix string, segments []models.Segment, initKey string, enc *models.Encryption) error {
+	playlist := hls.MediaPlaylist{Version: 3}
+	if initKey != "" {
+		playlist.Version = 7
+		playlist.Map = &hls.Map{URI: filepath.Base(initKey)}
+	}
+	if enc != nil {
+		playlist.Key = playlistKey(enc)
+	}
 
+	maxDur := 0.0
 	for _, s := range segments {
-		playlist.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n%s\n", s.Duration, s.Name))
+		maxDur = max(maxDur, s.Duration)
+		playlist.Segments = append(playlist.Segments, hls.Segment{Duration: s.Duration, URI: s.Name})
+	}
+	playlist.TargetDuration = int(math.Ceil(maxDur))
+	if err := playlist.Validate(); err != nil {
+		return fmt.Errorf("playlist inválida %s: %w", s3Prefix, err)
 	}
-	playlist.WriteString("#EXT-X-ENDLIST\n")
 
 	return f.bucket.UploadFileReader(
 		f.processedBucketName,
 		s3Prefix+"/index.m3u8",
-		bytes.NewReader(playlist.Bytes()),
+		bytes.NewReader(playlist.Encode()),
 	)
 }
 
-func (f *FFMPEGProcessor) getVideoHeightPartial(bucket, key, byteRange string) (int, error) {
-	stream, err := f.bucket.GetPartOfObjectStream(bucket, key, byteRange)
+// probeEncodedSegment probes a finished segment, decrypting a copy first when the job is encrypted.
+func probeEncodedSegment(path string, enc *models.Encryption) (segmentStreams, error) {
+	if enc == nil || enc.Scheme != models.EncryptionAES128 {
+		return probeSegment(path)
+	}
+	clear, err := decryptSegment(path, enc)
 	if err != nil {
-		return 0, err
+		return segmentStreams{}, err
 	}
-	defer stream.Close()
-	return f.getHeightFromStream(stream)
+	defer os.Remove(clear)
+	return probeSegment(clear)
 }
 
-func (f *FFMPEGProcessor) getVideoHeightFull(bucket, key string) (int, error) {
-	stream, err := f.bucket.GetObjectStream(bucket, key)
+// localPlaylistDurations reads the EXTINF durations ffmpeg wrote in its own playlist.
+func localPlaylistDurations(path string) map[string]float64 {
+	durations := map[string]float64{}
+
+	file, err := os.Open(path)
 	if err != nil {
-		return 0, err
+		return durations
 	}
-	defer stream.Close()
-	return f.getHeightFromStream(stream)
-}
+	defer file.Close()
 
-func (f *FFMPEGProcessor) getHeightFromStream(stream io.Reader) (int, error) {
-	cmd := exec.Command(
-		"ffprobe",
-		"-v", "error",
-		"-select_streams", "v:0",
-		"-show_entries", "stream=height",
-		"-of", "csv=p=0",
-		"pipe:0",
-	)
-	cmd.Stdin = stream
-	out, err := cmd.Output()
+	playlist, err := hls.ParseMedia(file)
 	if err != nil {
-		return 0, err
+		return durations
 	}
-	h, err := strconv.Atoi(string(bytes.TrimSpace(out)))
-	if err != nil {
-		return 0, err
+	for _, s := range playlist.Segments {
+		durations[filepath.Base(s.URI)] = s.Duration
 	}
-	return h, nil
+	return durations
 }
 
 func probeDuration(path string) (float64, error) {
@@ -0,0 +1,247 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"process-video-service/internal/hls"
+	"process-video-service/internal/models"
+)
+
+const iframePlaylistName = "iframes.m3u8"
+
+type packetOutput struct {
+	Packets []struct {
+		PtsTime string `json:"pts_time"`
+		Pos     string `json:"pos"`
+		Flags   string `json:"flags"`
+	} `json:"packets"`
+}
+
+// tsKeyframes lists the keyframes of a TS segment.
+func tsKeyframes(path string) ([]models.IFrame, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,pos,flags", "-of", "json", path).Output()
+	if err != nil {
+		return nil, err
+	}
+	var probe packetOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return keyframeRanges(probe, stat.Size()), nil
+}
+
+// keyframeRanges leaves the last duration to the caller, who knows where the segment ends.
+func keyframeRanges(probe packetOutput, size int64) []models.IFrame {
+	var frames []models.IFrame
+	var starts []float64
+	for i, pkt := range probe.Packets {
+		if len(pkt.Flags) == 0 || pkt.Flags[0] != 'K' {
+			continue
+		}
+		pos, err := strconv.ParseInt(pkt.Pos, 10, 64)
+		if err != nil {
+			continue
+		}
+		end := size
+		if i+1 < len(probe.Packets) {
+			if next, err := strconv.ParseInt(probe.Packets[i+1].Pos, 10, 64); err == nil {
+				end = next
+			}
+		}
+		pts, _ := strconv.ParseFloat(pkt.PtsTime, 64)
+		frames = append(frames, models.IFrame{Offset: pos, Length: end - pos})
+		starts = append(starts, pts)
+	}
+	for i := 0; i+1 < len(frames); i++ {
+		frames[i].Duration = starts[i+1] - starts[i]
+	}
+	return frames
+}
+
+// fmp4Keyframe runs up to the end of the first sample, so the moof is included.
+func fmp4Keyframe(path string) ([]models.IFrame, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	moof, ok := findBox(data, 0, "moof")
+	if !ok {
+		return nil, fmt.Errorf("moof não encontrado")
+	}
+	traf, ok := findBox(data[moof.start:moof.end], 8, "traf")
+	if !ok {
+		return nil, fmt.Errorf("traf não encontrado")
+	}
+	trafData := data[moof.start+traf.start : moof.start+traf.end]
+
+	base := int64(moof.start)
+	var defaultSize uint32
+	if tfhd, ok := findBox(trafData, 8, "tfhd"); ok {
+		body := trafData[tfhd.start+8 : tfhd.end]
+		if len(body) < 8 {
+			return nil, fmt.Errorf("tfhd inválido")
+		}
+		flags := binary.BigEndian.Uint32(body[0:4]) & 0xffffff
+		needed := 8
+		for _, field := range []struct {
+			flag uint32
+			size int
+		}{{0x01, 8}, {0x02, 4}, {0x08, 4}, {0x10, 4}} {
+			if flags&field.flag != 0 {
+				needed += field.size
+			}
+		}
+		if len(body) < needed {
+			return nil, fmt.Errorf("tfhd inválido")
+		}
+		off := 8 // version/flags + track_ID
+		if flags&0x01 != 0 {
+			base = int64(binary.BigEndian.Uint64(body[off:]))
+			off += 8
+		}
+		if flags&0x02 != 0 {
+			off += 4
+		}
+		if flags&0x08 != 0 {
+			off += 4
+		}
+		if flags&0x10 != 0 {
+			defaultSize = binary.BigEndian.Uint32(body[off:])
+		}
+	}
+
+	trun, ok := findBox(trafData, 8, "trun")
+	if !ok {
+		return nil, fmt.Errorf("trun não encontrado")
+	}
+	body := trafData[trun.start+8 : trun.end]
+	if len(body) < 8 {
+		return nil, fmt.Errorf("trun inválido")
+	}
+	flags := binary.BigEndian.Uint32(body[0:4]) & 0xffffff
+	// data offset, first sample flags, then the first sample's duration and size
+	needed := 8
+	for _, flag := range []uint32{0x01, 0x04, 0x100, 0x200} {
+		if flags&flag != 0 {
+			needed += 4
+		}
+	}
+	if len(body) < needed {
+		return nil, fmt.Errorf("trun inválido")
+	}
+	off := 8 // version/flags + sample_count
+	var dataOffset int64
+	if flags&0x01 != 0 {
+		dataOffset = int64(int32(binary.BigEndian.Uint32(body[off:])))
+		off += 4
+	}
+	if flags&0x04 != 0 {
+		off += 4
+	}
+	if flags&0x100 != 0 {
+		off += 4
+	}
+	size := defaultSize
+	if flags&0x200 != 0 {
+		size = binary.BigEndian.Uint32(body[off:])
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("tamanho da amostra desconhecido")
+	}
+
+	return []models.IFrame{{Offset: 0, Length: base + dataOffset + int64(size)}}, nil
+}
+
+type box struct{ start, end int }
+
+// findBox looks for a box of the given type among the siblings starting at offset.
+func findBox(data []byte, offset int, kind string) (box, bool) {
+	for offset+8 <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+		if size < 8 || offset+size > len(data) {
+			return box{}, false
+		}
+		if string(data[offset+4:offset+8]) == kind {
+			return box{start: offset, end: offset + size}, true
+		}
+		offset += size
+	}
+	return box{}, false
+}
+
+func (f *FFMPEGProcessor) uploadIFramePlaylist(s3Prefix string, segments []models.Segment, initKey string) (string, int, error) {
+	playlist := hls.MediaPlaylist{Version: 4, IFramesOnly: true}
+	if initKey != "" {
+		playlist.Version = 7
+		playlist.Map = &hls.Map{URI: filepath.Base(initKey)}
+	}
+
+	peak := 0
+	maxDur := 0.0
+	for _, s := range segments {
+		for _, frame := range s.IFrames {
+			if frame.Duration <= 0 {
+				continue
+			}
+			playlist.Segments = append(playlist.Segments, hls.Segment{
+				Duration:  frame.Duration,
+				URI:       s.Name,
+				ByteRange: &hls.ByteRange{Length: frame.Length, Offset: frame.Offset},
+			})
+			maxDur = max(maxDur, frame.Duration)
+			peak = max(peak, int(float64(frame.Length*8)/frame.Duration))
+		}
+	}
+	if len(playlist.Segments) == 0 {
+		return "", 0, fmt.Errorf("nenhum keyframe encontrado")
+	}
+	playlist.TargetDuration = int(math.Ceil(maxDur))
+
+	// TS ranges skip the PAT/PMT, so they are mapped from the first segment
+	if initKey == "" && len(segments[0].IFrames) > 0 && segments[0].IFrames[0].Offset > 0 {
+		playlist.Version = 5
+		playlist.Map = &hls.Map{
+			URI:       segments[0].Name,
+			ByteRange: &hls.ByteRange{Length: segments[0].IFrames[0].Offset},
+		}
+	}
+
+	if err := playlist.Validate(); err != nil {
+		return "", 0, fmt.Errorf("playlist de I-frames inválida %s: %w", s3Prefix, err)
+	}
+
+	key := s3Prefix + "/" + iframePlaylistName
+	if err := f.bucket.UploadFileReader(f.processedBucketName, key, bytes.NewReader(playlist.Encode())); err != nil {
+		return "", 0, err
+	}
+	return key, peak, nil
+}
+
+// fillIFrameDurations gives the last keyframe of each segment the rest of the segment.
+func fillIFrameDurations(segments []models.Segment) {
+	for i := range segments {
+		frames := segments[i].IFrames
+		if len(frames) == 0 {
+			continue
+		}
+		rest := segments[i].Duration
+		for _, frame := range frames[:len(frames)-1] {
+			rest -= frame.Duration
+		}
+		frames[len(frames)-1].Duration = max(rest, 0)
+	}
+}
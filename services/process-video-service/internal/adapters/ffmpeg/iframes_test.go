@@ -0,0 +1,194 @@
+package ffmpeg
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"process-video-service/internal/models"
+	"process-video-service/tests/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyframeRanges(t *testing.T) {
+	var probe packetOutput
+	require.NoError(t, json.Unmarshal([]byte(`{"packets": [
+		{"pts_time": "10.000", "pos": "564", "flags": "K__"},
+		{"pts_time": "10.040", "pos": "40608", "flags": "___"},
+		{"pts_time": "10.080", "pos": "52640", "flags": "___"},
+		{"pts_time": "12.500", "pos": "812256", "flags": "K__"},
+		{"pts_time": "12.540", "pos": "N/A", "flags": "___"},
+		{"pts_time": "13.000", "pos": "N/A", "flags": "K__"},
+		{"pts_time": "14.000", "pos": "900000", "flags": "K_D"}
+	]}`), &probe))
+
+	frames := keyframeRanges(probe, 960000)
+	assert.Equal(t, []models.IFrame{
+		{Offset: 564, Length: 40044, Duration: 2.5},
+		// the next packet has no position, so the range runs to the end of the segment
+		{Offset: 812256, Length: 147744, Duration: 1.5},
+		{Offset: 900000, Length: 60000},
+	}, frames)
+}
+
+func TestFillIFrameDurations(t *testing.T) {
+	segments := []models.Segment{
+		{Name: "seg000.ts", Duration: 6, IFrames: []models.IFrame{{Duration: 2.5}, {Duration: 0}}},
+		{Name: "seg001.ts", Duration: 4},
+		{Name: "seg002.ts", Duration: 2, IFrames: []models.IFrame{{Duration: 2.2}, {}}},
+	}
+	fillIFrameDurations(segments)
+	assert.Equal(t, 3.5, segments[0].IFrames[1].Duration)
+	assert.Empty(t, segments[1].IFrames)
+	assert.Equal(t, 0.0, segments[2].IFrames[1].Duration)
+}
+
+func mp4Box(kind string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	b := binary.BigEndian.AppendUint32(nil, uint32(size))
+	b = append(b, kind...)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func uint32s(values ...uint32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+func TestFmp4Keyframe(t *testing.T) {
+	styp := mp4Box("styp", []byte("msdhmsdh"), make([]byte, 8))
+	mfhd := mp4Box("mfhd", uint32s(0, 1))
+
+	tests := []struct {
+		name    string
+		traf    []byte
+		want    int64
+		wantErr string
+	}{
+		{
+			// default-base-is-moof; trun carries a data offset and per-sample sizes
+			name: "sample sizes in trun",
+			traf: mp4Box("traf", mp4Box("tfhd", uint32s(0x020000, 1)), mp4Box("trun", uint32s(0x000201, 2, 84, 5000, 1200))),
+			want: 24 + 84 + 5000,
+		},
+		{
+			name: "default sample size in tfhd",
+			traf: mp4Box("traf", mp4Box("tfhd", uint32s(0x020010, 1, 4800)), mp4Box("trun", uint32s(0x000001, 2, 84))),
+			want: 24 + 84 + 4800,
+		},
+		{
+			name: "explicit base data offset",
+			traf: mp4Box("traf", mp4Box("tfhd", uint32s(0x000001, 1, 0, 1000)), mp4Box("trun", uint32s(0x000301, 2, 84, 40, 5000, 40, 1200))),
+			want: 1000 + 84 + 5000,
+		},
+		{
+			name:    "unknown sample size",
+			traf:    mp4Box("traf", mp4Box("tfhd", uint32s(0x020000, 1)), mp4Box("trun", uint32s(0x000001, 2, 84))),
+			wantErr: "tamanho da amostra desconhecido",
+		},
+		{
+			name:    "truncated trun",
+			traf:    mp4Box("traf", mp4Box("tfhd", uint32s(0x020000, 1)), mp4Box("trun", uint32s(0x000201, 2, 84))),
+			wantErr: "trun inválido",
+		},
+		{
+			name:    "no trun",
+			traf:    mp4Box("traf", mp4Box("tfhd", uint32s(0x020000, 1))),
+			wantErr: "trun não encontrado",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append(append([]byte{}, styp...), mp4Box("moof", mfhd, tt.traf)...)
+			data = append(data, mp4Box("mdat", make([]byte, 64))...)
+			path := filepath.Join(t.TempDir(), "seg000.m4s")
+			require.NoError(t, os.WriteFile(path, data, 0644))
+
+			frames, err := fmp4Keyframe(path)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []models.IFrame{{Offset: 0, Length: tt.want}}, frames)
+		})
+	}
+}
+
+func TestUploadIFramePlaylist(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []models.Segment
+		initKey  string
+		want     string
+		wantPeak int
+	}{
+		{
+			name: "ts ranges mapped from the first segment",
+			segments: []models.Segment{
+				{Name: "seg000.ts", IFrames: []models.IFrame{{Offset: 564, Length: 40044, Duration: 2.5}, {Offset: 812256, Length: 37224, Duration: 2.5}}},
+				{Name: "seg001.ts", IFrames: []models.IFrame{{Offset: 564, Length: 39480, Duration: 2}, {Offset: 700000, Length: 100}}},
+			},
+			want: "#EXTM3U\n#EXT-X-VERSION:5\n#EXT-X-TARGETDURATION:3\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-I-FRAMES-ONLY\n" +
+				"#EXT-X-MAP:URI=\"seg000.ts\",BYTERANGE=\"564@0\"\n" +
+				"#EXTINF:2.500,\n#EXT-X-BYTERANGE:40044@564\nseg000.ts\n" +
+				"#EXTINF:2.500,\n#EXT-X-BYTERANGE:37224@812256\nseg000.ts\n" +
+				"#EXTINF:2.000,\n#EXT-X-BYTERANGE:39480@564\nseg001.ts\n" +
+				"#EXT-X-ENDLIST\n",
+			wantPeak: 157920,
+		},
+		{
+			name: "fmp4 fragments share the init segment",
+			segments: []models.Segment{
+				{Name: "seg000.m4s", IFrames: []models.IFrame{{Length: 5108, Duration: 6}}},
+				{Name: "seg001.m4s", IFrames: []models.IFrame{{Length: 4000, Duration: 4.5}}},
+			},
+			initKey: "videos/ep123/720p/init.mp4",
+			want: "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-I-FRAMES-ONLY\n" +
+				"#EXT-X-MAP:URI=\"init.mp4\"\n" +
+				"#EXTINF:6.000,\n#EXT-X-BYTERANGE:5108@0\nseg000.m4s\n" +
+				"#EXTINF:4.500,\n#EXT-X-BYTERANGE:4000@0\nseg001.m4s\n" +
+				"#EXT-X-ENDLIST\n",
+			wantPeak: 7111,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := new(mocks.MockBucket)
+			var uploaded string
+			bucket.On("UploadFileReader", "processed", "videos/ep123/720p/iframes.m3u8", mock.Anything).
+				Run(func(args mock.Arguments) {
+					body, _ := io.ReadAll(args.Get(2).(io.Reader))
+					uploaded = string(body)
+				}).Return(nil)
+			f := NewFFMPEGProcessor(bucket, "processed", false, false)
+
+			key, peak, err := f.uploadIFramePlaylist("videos/ep123/720p", tt.segments, tt.initKey)
+			require.NoError(t, err)
+			assert.Equal(t, "videos/ep123/720p/iframes.m3u8", key)
+			assert.Equal(t, tt.wantPeak, peak)
+			assert.Equal(t, tt.want, uploaded)
+		})
+	}
+}
+
+func TestUploadIFramePlaylist_NoKeyframes(t *testing.T) {
+	f := NewFFMPEGProcessor(new(mocks.MockBucket), "processed", false, false)
+	_, _, err := f.uploadIFramePlaylist("videos/ep123/720p", []models.Segment{{Name: "seg000.ts", Duration: 6}}, "")
+	assert.EqualError(t, err, "nenhum keyframe encontrado")
+}
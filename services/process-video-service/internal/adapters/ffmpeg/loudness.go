@@ -0,0 +1,83 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+// loudnormStats is the JSON block loudnorm prints with print_format=json; every value is a string.
+type loudnormStats struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// measureLoudness returns nil for a silent track.
+func (f *FFMPEGProcessor) measureLoudness(ctx context.Context, job *models.Job, track models.AudioTrack, channels string) (*models.Loudness, loudnormStats, error) {
+	target := job.Loudness
+
+	stream, err := f.bucket.GetObjectStream(job.Event.Bucket, job.Event.Key)
+	if err != nil {
+		return nil, loudnormStats{}, err
+	}
+	defer stream.Close()
+
+	filter := fmt.Sprintf("%s,loudnorm=I=%g:TP=%g:LRA=%g:print_format=json", channels, target.Integrated, target.TruePeak, target.Range)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-i", "pipe:0",
+		"-map", fmt.Sprintf("0:a:%d", track.Index),
+		"-vn", "-sn",
+		"-af", filter,
+		"-f", "null", "-",
+	)
+	cmd.Stdin = stream
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, loudnormStats{}, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, loudnormStats{}, fmt.Errorf("erro ao medir loudness: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	// the JSON block is the last thing loudnorm prints
+	out := stderr.String()
+	start, end := strings.LastIndex(out, "{"), strings.LastIndex(out, "}")
+	if start < 0 || end < start {
+		return nil, loudnormStats{}, fmt.Errorf("loudnorm não retornou medições")
+	}
+	var stats loudnormStats
+	if err := json.Unmarshal([]byte(out[start:end+1]), &stats); err != nil {
+		return nil, loudnormStats{}, fmt.Errorf("medições do loudnorm inválidas: %w", err)
+	}
+
+	loudness := &models.Loudness{TargetIntegrated: target.Integrated, TargetTruePeak: target.TruePeak}
+	values := []*float64{&loudness.Integrated, &loudness.TruePeak, &loudness.Range, &loudness.Threshold}
+	for i, v := range []string{stats.InputI, stats.InputTP, stats.InputLRA, stats.InputThresh} {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, loudnormStats{}, nil
+		}
+		*values[i] = n
+	}
+	// linear gain is only possible while the raised true peak stays under the target
+	loudness.Linear = loudness.TruePeak+(target.Integrated-loudness.Integrated) <= target.TruePeak
+	return loudness, stats, nil
+}
+
+func loudnormFilter(target models.LoudnessTarget, stats loudnormStats) string {
+	return fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%g:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+		target.Integrated, target.TruePeak, target.Range,
+		stats.InputI, stats.InputTP, stats.InputLRA, stats.InputThresh, stats.TargetOffset)
+}
@@ -0,0 +1,137 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+)
+
+// creditsFilter samples the end of the episode once per second.
+const creditsFilter = "trim=start=%g,fps=1,scale=320:-2," +
+	"blackdetect=d=1:pic_th=0.98:pix_th=0.10," +
+	"blackframe=amount=0:threshold=32," +
+	"edgedetect=low=0.1:high=0.3,format=yuv420p,signalstats," +
+	"metadata=mode=print"
+
+var (
+	creditsTime  = regexp.MustCompile(`pts_time:([0-9.]+)`)
+	creditsDark  = regexp.MustCompile(`lavfi\.blackframe\.pblack=([0-9.]+)`)
+	creditsEdges = regexp.MustCompile(`lavfi\.signalstats\.YAVG=([0-9.]+)`)
+
+	chromaprintOnce      sync.Once
+	chromaprintAvailable bool
+)
+
+// FingerprintAudio computes the chromaprint of the default audio track up to the given
+// seconds. It returns nil when the local ffmpeg build has no chromaprint muxer.
+func (f *FFMPEGProcessor) FingerprintAudio(ctx context.Context, job *models.Job, seconds float64) (*models.AudioFingerprint, error) {
+	track, ok := defaultAudioTrack(job.Info.AudioTracks)
+	if !ok || !hasChromaprint() {
+		return nil, nil
+	}
+
+	stream, err := f.bucket.GetObjectStream(job.Event.Bucket, job.Event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-i", "pipe:0",
+		"-map", fmt.Sprintf("0:a:%d", track.Index),
+		"-vn", "-sn",
+		"-t", fmt.Sprint(seconds),
+		"-ac", "1",
+		"-f", "chromaprint", "-fp_format", "raw",
+		"pipe:1",
+	)
+	cmd.Stdin = stream
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro ao gerar fingerprint de áudio: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	// raw fingerprints are native-endian uint32 points; the service only runs on little-endian hosts
+	raw := stdout.Bytes()
+	points := make([]uint32, len(raw)/4)
+	for i := range points {
+		points[i] = binary.LittleEndian.Uint32(raw[i*4:])
+	}
+	return &models.AudioFingerprint{EpId: job.Event.EpId, Interval: helpers.FingerprintInterval, Points: points}, nil
+}
+
+// AnalyzeCredits samples the last seconds of the source for the credits heuristics.
+func (f *FFMPEGProcessor) AnalyzeCredits(ctx context.Context, job *models.Job, seconds float64) (*models.CreditsAnalysis, error) {
+	start := max(job.Info.Duration-seconds, 0)
+
+	stream, err := f.bucket.GetObjectStream(job.Event.Bucket, job.Event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-i", "pipe:0",
+		"-map", "0:v:0", "-an", "-sn",
+		"-vf", fmt.Sprintf(creditsFilter, start),
+		"-f", "null", "-",
+	)
+	cmd.Stdin = stream
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro na análise de créditos: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	return parseCredits(stderr.String()), nil
+}
+
+func parseCredits(log string) *models.CreditsAnalysis {
+	analysis := &models.CreditsAnalysis{}
+	var frame *models.CreditsFrame
+	for _, line := range strings.Split(log, "\n") {
+		if m := creditsTime.FindStringSubmatch(line); m != nil {
+			analysis.Frames = append(analysis.Frames, models.CreditsFrame{Time: parseFloat(m[1])})
+			frame = &analysis.Frames[len(analysis.Frames)-1]
+		}
+		if m := creditsDark.FindStringSubmatch(line); m != nil && frame != nil {
+			frame.Dark = parseFloat(m[1]) / 100
+		}
+		if m := creditsEdges.FindStringSubmatch(line); m != nil && frame != nil {
+			frame.Edges = parseFloat(m[1])
+		}
+		if m := qcBlack.FindStringSubmatch(line); m != nil {
+			analysis.Black = append(analysis.Black, models.QCInterval{
+				Start: parseFloat(m[1]), End: parseFloat(m[2]), Duration: parseFloat(m[3]),
+			})
+		}
+	}
+	return analysis
+}
+
+// hasChromaprint reports whether the local ffmpeg build was compiled with chromaprint.
+func hasChromaprint() bool {
+	chromaprintOnce.Do(func() {
+		out, err := exec.Command("ffmpeg", "-hide_banner", "-muxers").Output()
+		chromaprintAvailable = err == nil && bytes.Contains(out, []byte(" chromaprint "))
+	})
+	return chromaprintAvailable
+}
@@ -0,0 +1,31 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCredits(t *testing.T) {
+	log := `[Parsed_blackdetect_3 @ 0x55] black_start:1236 black_end:1238.5 black_duration:2.5
+[Parsed_metadata_8 @ 0x55] frame:0    pts:1235   pts_time:1235
+[Parsed_metadata_8 @ 0x55] lavfi.blackframe.pblack=12
+[Parsed_metadata_8 @ 0x55] lavfi.signalstats.YMIN=0
+[Parsed_metadata_8 @ 0x55] lavfi.signalstats.YAVG=9.25
+[Parsed_metadata_8 @ 0x55] frame:1    pts:1236   pts_time:1236
+[Parsed_metadata_8 @ 0x55] lavfi.blackframe.pblack=100
+[Parsed_metadata_8 @ 0x55] lavfi.signalstats.YAVG=0.1
+[Parsed_metadata_8 @ 0x55] frame:2    pts:1240   pts_time:1240
+[Parsed_metadata_8 @ 0x55] lavfi.signalstats.YAVG=4.5
+`
+	assert.Equal(t, &models.CreditsAnalysis{
+		Frames: []models.CreditsFrame{
+			{Time: 1235, Dark: 0.12, Edges: 9.25},
+			{Time: 1236, Dark: 1, Edges: 0.1},
+			{Time: 1240, Dark: 0, Edges: 4.5},
+		},
+		Black: []models.QCInterval{{Start: 1236, End: 1238.5, Duration: 2.5}},
+	}, parseCredits(log))
+}
@@ -0,0 +1,171 @@
+package ffmpeg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"process-video-service/internal/models"
+)
+
+// maxOverlayBytes keeps a wrong key (a video instead of a logo) from being downloaded.
+const maxOverlayBytes = 10 << 20
+
+// overlayCacheTTL is well past the longest job.
+const overlayCacheTTL = 24 * time.Hour
+
+// overlayCodecs are the decoders of the image formats ResolveOverlay accepts.
+var overlayCodecs = map[string]bool{"png": true, "webp": true, "mjpeg": true}
+
+// PrepareOverlay fetches the overlay image into a local cache shared by every job, so
+// the same logo is downloaded once, and checks it is a single image that fits the
+// picture with its margins.
+func (f *FFMPEGProcessor) PrepareOverlay(ctx context.Context, job *models.Job, overlay models.Overlay) (*models.OverlayImage, error) {
+	bucket, key := job.Event.Bucket, overlay.ImageKey
+
+	objects, err := f.bucket.ListObjects(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	var object *models.ObjectInfo
+	for i := range objects {
+		if objects[i].Key == key {
+			object = &objects[i]
+		}
+	}
+	switch {
+	case object == nil:
+		return nil, fmt.Errorf("imagem de overlay não encontrada: %s", key)
+	case object.Size == 0 || object.Size > maxOverlayBytes:
+		return nil, fmt.Errorf("imagem de overlay com tamanho inválido: %d bytes", object.Size)
+	}
+
+	// a replaced image has another ETag, and so another cache entry
+	cacheDir := filepath.Join(f.tmpDir, "overlays")
+	os.MkdirAll(cacheDir, 0755)
+	name := sha256.Sum256([]byte(bucket + "/" + key))
+	version := sha256.Sum256([]byte(object.ETag))
+	prefix := hex.EncodeToString(name[:8])
+	path := filepath.Join(cacheDir, fmt.Sprintf("%s-%s%s", prefix, hex.EncodeToString(version[:8]), strings.ToLower(filepath.Ext(key))))
+
+	if _, err := os.Stat(path); err != nil {
+		if err := f.downloadOverlay(bucket, key, path); err != nil {
+			return nil, fmt.Errorf("erro ao baixar overlay: %w", err)
+		}
+	}
+	// jobs touch the version they use; other versions go once no job has for a while
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	stale, _ := filepath.Glob(filepath.Join(cacheDir, prefix+"-*"))
+	for _, p := range stale {
+		if info, err := os.Stat(p); err == nil && p != path && now.Sub(info.ModTime()) > overlayCacheTTL {
+			os.Remove(p)
+		}
+	}
+
+	image := &models.OverlayImage{Overlay: overlay, Path: path}
+	image.Width, image.Height, err = probeImage(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := job.Info.Width, job.Info.Height
+	if c := job.Crop; c != nil {
+		width, height = c.Width, c.Height
+	}
+	if image.Width+2*overlay.Margin > width || image.Height+2*overlay.Margin > height {
+		return nil, fmt.Errorf("overlay %dx%d com margem %d não cabe no vídeo %dx%d",
+			image.Width, image.Height, overlay.Margin, width, height)
+	}
+	return image, nil
+}
+
+// downloadOverlay renames into place so a concurrent job never reads a partial file.
+func (f *FFMPEGProcessor) downloadOverlay(bucket, key, path string) error {
+	stream, err := f.bucket.GetObjectStream(bucket, key)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, io.LimitReader(stream, maxOverlayBytes+1))
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// probeImage returns the dimensions of a file holding a single still image.
+func probeImage(ctx context.Context, path string) (int, int, error) {
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-show_streams", "-of", "json", path).Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("imagem de overlay ilegível: %w", err)
+	}
+	var probe probeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return 0, 0, err
+	}
+	if len(probe.Streams) != 1 || !overlayCodecs[probe.Streams[0].CodecName] {
+		return 0, 0, fmt.Errorf("overlay não é uma imagem png, webp ou jpeg")
+	}
+	s := probe.Streams[0]
+	if s.Width <= 0 || s.Height <= 0 {
+		return 0, 0, fmt.Errorf("imagem de overlay sem dimensões")
+	}
+	return s.Width, s.Height, nil
+}
+
+// overlayFilter continues the chain as a graph that reads the image with movie.
+func overlayFilter(job *models.Job, height int) string {
+	if job.Overlay == nil || job.Info.Height == 0 {
+		return ""
+	}
+	return scaledOverlayFilter(job, float64(height)/float64(job.Info.Height), 0)
+}
+
+func scaledOverlayFilter(job *models.Job, factor, offset float64) string {
+	o := job.Overlay
+	if o == nil {
+		return ""
+	}
+	w := max(int(math.Round(float64(o.Width)*factor)), 1)
+	h := max(int(math.Round(float64(o.Height)*factor)), 1)
+	margin := int(math.Round(float64(o.Margin) * factor))
+
+	x, y := fmt.Sprint(margin), fmt.Sprint(margin)
+	if o.Corner == models.OverlayTopRight || o.Corner == models.OverlayBottomRight {
+		x = fmt.Sprintf("W-w-%d", margin)
+	}
+	if o.Corner == models.OverlayBottomLeft || o.Corner == models.OverlayBottomRight {
+		y = fmt.Sprintf("H-h-%d", margin)
+	}
+
+	filter := fmt.Sprintf("[base];movie=%s,format=rgba,scale=%d:%d", o.Path, w, h)
+	if o.Opacity < 1 {
+		filter += fmt.Sprintf(",colorchannelmixer=aa=%g", o.Opacity)
+	}
+	filter += fmt.Sprintf("[logo];[base][logo]overlay=%s:%s", x, y)
+	switch {
+	case o.End > 0:
+		filter += fmt.Sprintf(":enable='between(t,%g,%g)'", o.Start-offset, o.End-offset)
+	case o.Start > offset:
+		filter += fmt.Sprintf(":enable='gte(t,%g)'", o.Start-offset)
+	}
+	return filter
+}
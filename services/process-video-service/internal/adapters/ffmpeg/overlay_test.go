@@ -0,0 +1,82 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlayFilter(t *testing.T) {
+	image := func(corner string, opacity, start, end float64) *models.OverlayImage {
+		return &models.OverlayImage{
+			Overlay: models.Overlay{Corner: corner, Margin: 40, Opacity: opacity, Start: start, End: end},
+			Path:    "/tmp/overlays/logo.png",
+			Width:   200,
+			Height:  100,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		overlay *models.OverlayImage
+		height  int
+		want    string
+	}{
+		{
+			name:    "no overlay",
+			overlay: nil,
+			height:  720,
+			want:    "",
+		},
+		{
+			name:    "top right at source size",
+			overlay: image(models.OverlayTopRight, 1, 0, 0),
+			height:  1080,
+			want:    "[base];movie=/tmp/overlays/logo.png,format=rgba,scale=200:100[logo];[base][logo]overlay=W-w-40:40",
+		},
+		{
+			name:    "bottom left scaled to the rung",
+			overlay: image(models.OverlayBottomLeft, 1, 0, 0),
+			height:  540,
+			want:    "[base];movie=/tmp/overlays/logo.png,format=rgba,scale=100:50[logo];[base][logo]overlay=20:H-h-20",
+		},
+		{
+			name:    "translucent between two times",
+			overlay: image(models.OverlayBottomRight, 0.5, 10, 20),
+			height:  1080,
+			want: "[base];movie=/tmp/overlays/logo.png,format=rgba,scale=200:100,colorchannelmixer=aa=0.5" +
+				"[logo];[base][logo]overlay=W-w-40:H-h-40:enable='between(t,10,20)'",
+		},
+		{
+			name:    "from a time to the end",
+			overlay: image(models.OverlayTopLeft, 1, 30, 0),
+			height:  1080,
+			want:    "[base];movie=/tmp/overlays/logo.png,format=rgba,scale=200:100[logo];[base][logo]overlay=40:40:enable='gte(t,30)'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &models.Job{Info: &models.MediaInfo{Width: 1920, Height: 1080}, Overlay: tt.overlay}
+			assert.Equal(t, tt.want, overlayFilter(job, tt.height))
+		})
+	}
+}
+
+func TestScaledOverlayFilter_ShiftsTimesToTheCut(t *testing.T) {
+	job := &models.Job{
+		Info: &models.MediaInfo{Width: 1920, Height: 1080},
+		Overlay: &models.OverlayImage{
+			Overlay: models.Overlay{Corner: models.OverlayTopLeft, Opacity: 1, Start: 30},
+			Path:    "logo.png", Width: 200, Height: 100,
+		},
+	}
+	// a 480 wide preview cut from 25s in
+	assert.Equal(t, "[base];movie=logo.png,format=rgba,scale=50:25[logo];[base][logo]overlay=0:0:enable='gte(t,5)'",
+		scaledOverlayFilter(job, 0.25, 25))
+
+	// cut after the overlay started: it shows from the first frame
+	assert.Equal(t, "[base];movie=logo.png,format=rgba,scale=50:25[logo];[base][logo]overlay=0:0",
+		scaledOverlayFilter(job, 0.25, 40))
+}
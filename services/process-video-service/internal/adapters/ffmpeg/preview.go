@@ -0,0 +1,171 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+// 288kbps at 480 wide
+const previewBitsPerPixelWidth = 600
+
+var motionScore = regexp.MustCompile(`lavfi\.scene_score=([0-9.]+)`)
+
+// MeasureMotion scores how much the picture changes between frames sampled twice a
+// second across the whole source.
+func (f *FFMPEGProcessor) MeasureMotion(ctx context.Context, job *models.Job) ([]models.MotionSample, error) {
+	stream, err := f.bucket.GetObjectStream(job.Event.Bucket, job.Event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-i", "pipe:0",
+		"-map", "0:v:0", "-an", "-sn",
+		"-vf", "fps=2,scale=160:-2,select='gte(scene,0)',metadata=mode=print:key=lavfi.scene_score",
+		"-f", "null", "-",
+	)
+	cmd.Stdin = stream
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro ao medir movimento: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	return parseMotion(stderr.String()), nil
+}
+
+// parseMotion pairs every pts_time line of metadata=print with the score after it.
+func parseMotion(log string) []models.MotionSample {
+	var samples []models.MotionSample
+	var sample *models.MotionSample
+	for _, line := range strings.Split(log, "\n") {
+		if m := sceneTime.FindStringSubmatch(line); m != nil {
+			samples = append(samples, models.MotionSample{Time: parseFloat(m[1])})
+			sample = &samples[len(samples)-1]
+		}
+		if m := motionScore.FindStringSubmatch(line); m != nil && sample != nil {
+			sample.Score = parseFloat(m[1])
+		}
+	}
+	return samples
+}
+
+// GeneratePreview cuts the preview window out of the source once and renders it at
+// every width as a muted faststart MP4 and an animated WebP, uploaded under
+// videos/<epId>/preview/.
+func (f *FFMPEGProcessor) GeneratePreview(ctx context.Context, job *models.Job, start float64, opts models.PreviewOptions) ([]models.PreviewClip, error) {
+	event := job.Event
+
+	tmp := filepath.Join(f.tmpDir, fmt.Sprintf("%s-preview", event.Key))
+	os.MkdirAll(tmp, 0755)
+	defer os.RemoveAll(tmp)
+
+	run := func(stdin io.Reader, args ...string) error {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "ffmpeg", append([]string{"-hide_banner", "-nostats", "-y"}, args...)...)
+		cmd.Stdin = stdin
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("cancelado pelo contexto")
+			}
+			return fmt.Errorf("erro ao gerar preview: %w: %s", err, lastLines(stderr.String(), 3))
+		}
+		return nil
+	}
+
+	widths := stillWidths(opts.Widths, job.Info.Width)
+
+	// a near-lossless intermediate at the largest width, so the source is read once
+	clip := filepath.Join(tmp, "clip.mp4")
+	filter := sourceFilter(job) + fmt.Sprintf("scale=%d:-2", widths[0])
+	if job.Info.HDR() {
+		filter += toneMapFilter
+	}
+	// the cut starts at zero, the overlay's times are on the source's clock
+	pictureWidth := job.Info.Width
+	if c := job.Crop; c != nil {
+		pictureWidth = c.Width
+	}
+	if pictureWidth > 0 {
+		filter += scaledOverlayFilter(job, float64(widths[0])/float64(pictureWidth), start)
+	}
+	stream, err := f.bucket.GetObjectStream(event.Bucket, event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	if err := run(stream,
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-i", "pipe:0",
+		"-t", strconv.FormatFloat(opts.Seconds, 'f', 3, 64),
+		"-map", "0:v:0", "-an", "-sn",
+		"-vf", filter,
+		"-c:v", "libx264", "-preset", "fast", "-crf", "16", "-pix_fmt", "yuv420p",
+		clip,
+	); err != nil {
+		return nil, err
+	}
+
+	s3Prefix := fmt.Sprintf("videos/%s/preview", event.EpId)
+	var clips []models.PreviewClip
+	for _, w := range widths {
+		maxrate := w * previewBitsPerPixelWidth
+		mp4 := filepath.Join(tmp, fmt.Sprintf("%d.mp4", w))
+		if err := run(nil,
+			"-i", clip, "-an",
+			"-vf", fmt.Sprintf("scale=%d:-2", w),
+			"-c:v", "libx264", "-preset", "slow", "-profile:v", "main", "-crf", "28",
+			"-maxrate", strconv.Itoa(maxrate), "-bufsize", strconv.Itoa(maxrate*2),
+			"-pix_fmt", "yuv420p", "-movflags", "+faststart",
+			mp4,
+		); err != nil {
+			return nil, err
+		}
+
+		webp := filepath.Join(tmp, fmt.Sprintf("%d.webp", w))
+		if err := run(nil,
+			"-i", clip, "-an",
+			"-vf", fmt.Sprintf("fps=12,scale=%d:-2", w),
+			"-c:v", "libwebp", "-loop", "0", "-quality", "60", "-compression_level", "4",
+			webp,
+		); err != nil {
+			return nil, err
+		}
+
+		preview := models.PreviewClip{Width: w}
+		for _, out := range []struct {
+			path string
+			key  *string
+		}{{mp4, &preview.MP4Key}, {webp, &preview.WebPKey}} {
+			file, err := os.Open(out.path)
+			if err != nil {
+				return nil, err
+			}
+			key := s3Prefix + "/" + filepath.Base(out.path)
+			err = f.bucket.UploadFileReader(f.processedBucketName, key, file)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+			*out.key = key
+		}
+		clips = append(clips, preview)
+	}
+	return clips, nil
+}
@@ -0,0 +1,372 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+// titleReplacer keeps stream titles writable as a quoted NAME in the master playlist.
+var titleReplacer = strings.NewReplacer(`"`, "'", "\r", " ", "\n", " ")
+
+var textSubtitleCodecs = map[string]bool{
+	"mov_text": true,
+	"subrip":   true,
+	"srt":      true,
+	"ass":      true,
+	"ssa":      true,
+	"webvtt":   true,
+	"text":     true,
+}
+
+type probeStream struct {
+	CodecType   string            `json:"codec_type"`
+	CodecName   string            `json:"codec_name"`
+	Profile     string            `json:"profile"`
+	Level       int               `json:"level"`
+	PixFmt      string            `json:"pix_fmt"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	FrameRate   string            `json:"avg_frame_rate"`
+	ColorTrc    string            `json:"color_transfer"`
+	ColorPrim   string            `json:"color_primaries"`
+	Channels    int               `json:"channels"`
+	Layout      string            `json:"channel_layout"`
+	Tags        map[string]string `json:"tags"`
+	Disposition map[string]int    `json:"disposition"`
+}
+
+type probeChapter struct {
+	StartTime string            `json:"start_time"`
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags"`
+}
+
+type probeOutput struct {
+	Streams  []probeStream  `json:"streams"`
+	Chapters []probeChapter `json:"chapters"`
+	Format   struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+func (f *FFMPEGProcessor) Probe(ctx context.Context, bucket, key string) (*models.MediaInfo, error) {
+	info, err := f.probePartial(ctx, bucket, key, "bytes=0-2097152")
+	if err != nil {
+		if info, err = f.probeFull(ctx, bucket, key); err != nil {
+			return nil, err
+		}
+	}
+
+	// over a pipe ffprobe can only estimate the length of formats without an index
+	// (MPEG-TS) from the bytes it read, so the duration is measured on the whole source
+	duration, err := f.measureDuration(ctx, bucket, key)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("cancelado pelo contexto")
+	}
+	if err != nil || duration <= 0 {
+		info.DurationEstimated = true
+		return info, nil
+	}
+	info.Duration = duration
+	return info, nil
+}
+
+func (f *FFMPEGProcessor) measureDuration(ctx context.Context, bucket, key string) (float64, error) {
+	stream, err := f.bucket.GetObjectStream(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-progress", "pipe:1",
+		"-i", "pipe:0",
+		"-map", "0:V:0",
+		"-c", "copy",
+		"-f", "null", "-",
+	)
+	cmd.Stdin = stream
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("erro ao medir duração: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+	return progressDuration(stdout.String()), nil
+}
+
+// progressDuration reads the last out_time_us ffmpeg's -progress reported.
+func progressDuration(progress string) float64 {
+	duration := 0.0
+	for _, line := range strings.Split(progress, "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "out_time_us=")
+		if !ok {
+			continue
+		}
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil {
+			duration = float64(us) / 1e6
+		}
+	}
+	return duration
+}
+
+func (f *FFMPEGProcessor) probePartial(ctx context.Context, bucket, key, byteRange string) (*models.MediaInfo, error) {
+	stream, err := f.bucket.GetPartOfObjectStream(bucket, key, byteRange)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	return probeStreamInfo(ctx, stream)
+}
+
+func (f *FFMPEGProcessor) probeFull(ctx context.Context, bucket, key string) (*models.MediaInfo, error) {
+	stream, err := f.bucket.GetObjectStream(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	return probeStreamInfo(ctx, stream)
+}
+
+func probeStreamInfo(ctx context.Context, stream io.Reader) (*models.MediaInfo, error) {
+	cmd := exec.CommandContext(ctx,
+		"ffprobe",
+		"-v", "error",
+		"-show_streams",
+		"-show_format",
+		"-show_chapters",
+		"-of", "json",
+		"pipe:0",
+	)
+	cmd.Stdin = stream
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var probe probeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, err
+	}
+	return parseProbe(probe)
+}
+
+func parseProbe(probe probeOutput) (*models.MediaInfo, error) {
+	info := &models.MediaInfo{Container: probe.Format.FormatName}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	info.Bitrate, _ = strconv.Atoi(probe.Format.BitRate)
+
+	hasVideo := false
+	subtitleStreams := 0
+	for _, s := range probe.Streams {
+		switch s.CodecType {
+		case "video":
+			if hasVideo || s.Disposition["attached_pic"] == 1 {
+				continue
+			}
+			hasVideo = true
+			info.VideoCodec = s.CodecName
+			info.Width = s.Width
+			info.Height = s.Height
+			info.FrameRate = parseRational(s.FrameRate)
+			info.ColorTransfer = s.ColorTrc
+			info.ColorPrimaries = s.ColorPrim
+			info.VideoRange = videoRange(s.ColorTrc)
+		case "audio":
+			info.AudioTracks = append(info.AudioTracks, audioTrackFromStream(len(info.AudioTracks), s))
+		case "subtitle":
+			if textSubtitleCodecs[s.CodecName] {
+				info.SubtitleTracks = append(info.SubtitleTracks, subtitleTrackFromStream(len(info.SubtitleTracks), subtitleStreams, s))
+			}
+			subtitleStreams++
+		}
+	}
+
+	if info.Height == 0 {
+		return nil, fmt.Errorf("nenhum stream de vídeo encontrado")
+	}
+
+	for i, c := range probe.Chapters {
+		chapter := models.Chapter{Start: parseFloat(c.StartTime), End: parseFloat(c.EndTime), Title: c.Tags["title"]}
+		if chapter.Title == "" {
+			chapter.Title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		if chapter.End > chapter.Start {
+			info.Chapters = append(info.Chapters, chapter)
+		}
+	}
+
+	// a rendition group may have a single DEFAULT=YES entry
+	defaultIdx := 0
+	for i := len(info.AudioTracks) - 1; i >= 0; i-- {
+		if info.AudioTracks[i].Default {
+			defaultIdx = i
+		}
+	}
+	for i := range info.AudioTracks {
+		info.AudioTracks[i].Default = i == defaultIdx
+	}
+
+	return info, nil
+}
+
+func audioTrackFromStream(index int, s probeStream) models.AudioTrack {
+	track := models.AudioTrack{
+		Index:         index,
+		Language:      s.Tags["language"],
+		Name:          strings.TrimSpace(titleReplacer.Replace(s.Tags["title"])),
+		Default:       s.Disposition["default"] == 1,
+		Channels:      s.Channels,
+		ChannelLayout: s.Layout,
+	}
+	if track.Language == "und" {
+		track.Language = ""
+	}
+	if track.Name == "" {
+		track.Name = track.Language
+	}
+	if track.Name == "" {
+		track.Name = fmt.Sprintf("Audio %d", index+1)
+	}
+	return track
+}
+
+func subtitleTrackFromStream(index, streamIndex int, s probeStream) models.SubtitleTrack {
+	track := models.SubtitleTrack{
+		Index:       index,
+		StreamIndex: streamIndex,
+		Language:    s.Tags["language"],
+		Name:        strings.TrimSpace(titleReplacer.Replace(s.Tags["title"])),
+		Forced:      s.Disposition["forced"] == 1,
+	}
+	if track.Language == "und" {
+		track.Language = ""
+	}
+	if track.Name == "" {
+		track.Name = track.Language
+	}
+	if track.Name == "" {
+		track.Name = fmt.Sprintf("Subtitle %d", index+1)
+	}
+	return track
+}
+
+type segmentStreams struct {
+	Codecs string
+	Width  int
+	Height int
+}
+
+// probeSegment reads the codec strings and dimensions of a packaged segment.
+func probeSegment(path string) (segmentStreams, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-show_streams", "-of", "json", path).Output()
+	if err != nil {
+		return segmentStreams{}, err
+	}
+
+	var probe probeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return segmentStreams{}, err
+	}
+
+	var result segmentStreams
+	var codecs []string
+	for _, s := range probe.Streams {
+		if s.CodecType == "video" {
+			result.Width = s.Width
+			result.Height = s.Height
+		}
+		if c := codecString(s); c != "" {
+			codecs = append(codecs, c)
+		}
+	}
+	result.Codecs = strings.Join(codecs, ",")
+	return result, nil
+}
+
+var avcProfiles = map[string]string{
+	"Constrained Baseline": "42e0",
+	"Baseline":             "4200",
+	"Main":                 "4d00",
+	"High":                 "6400",
+	"High 10":              "6e00",
+	"High 4:2:2":           "7a00",
+}
+
+var aacProfiles = map[string]string{
+	"LC":       "mp4a.40.2",
+	"HE-AAC":   "mp4a.40.5",
+	"HE-AACv2": "mp4a.40.29",
+}
+
+// videoRange maps the stream's transfer characteristics to an HLS VIDEO-RANGE.
+func videoRange(transfer string) string {
+	switch transfer {
+	case "smpte2084":
+		return models.VideoRangePQ
+	case "arib-std-b67":
+		return models.VideoRangeHLG
+	}
+	return models.VideoRangeSDR
+}
+
+// codecString builds the RFC 6381 codec identifier HLS expects in CODECS.
+func codecString(s probeStream) string {
+	switch s.CodecName {
+	case "h264":
+		profile, ok := avcProfiles[s.Profile]
+		if !ok {
+			profile = avcProfiles["High"]
+		}
+		return fmt.Sprintf("avc1.%s%02x", profile, s.Level)
+	case "hevc":
+		// general_profile_space/idc, compatibility flags, tier+level, constraint flags
+		if s.Profile == "Main 10" {
+			return fmt.Sprintf("hvc1.2.4.L%d.B0", s.Level)
+		}
+		return fmt.Sprintf("hvc1.1.6.L%d.B0", s.Level)
+	case "av1":
+		depth := 8
+		if strings.Contains(s.PixFmt, "10") {
+			depth = 10
+		}
+		return fmt.Sprintf("av01.0.%02dM.%02d", s.Level, depth)
+	case "aac":
+		if c, ok := aacProfiles[s.Profile]; ok {
+			return c
+		}
+		return aacProfiles["LC"]
+	case "ac3":
+		return "ac-3"
+	case "eac3":
+		return "ec-3"
+	}
+	return ""
+}
+
+func parseRational(v string) float64 {
+	num, den, found := strings.Cut(v, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
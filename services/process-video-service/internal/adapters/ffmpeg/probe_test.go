@@ -0,0 +1,84 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProbe_TrackNames(t *testing.T) {
+	probe := probeOutput{Streams: []probeStream{
+		{CodecType: "video", CodecName: "h264", Width: 1920, Height: 1080},
+		{CodecType: "audio", Tags: map[string]string{"language": "por", "title": `Português "original"`}},
+		{CodecType: "audio", Tags: map[string]string{"language": "und"}},
+		{CodecType: "audio", Tags: map[string]string{"language": "eng"}},
+		{CodecType: "subtitle", CodecName: "subrip", Tags: map[string]string{"title": "Português\r\nforçada"}},
+		{CodecType: "subtitle", CodecName: "hdmv_pgs_subtitle"},
+		{CodecType: "subtitle", CodecName: "ass"},
+	}}
+	probe.Format.Duration = "1320.5"
+
+	info, err := parseProbe(probe)
+	require.NoError(t, err)
+
+	require.Len(t, info.AudioTracks, 3)
+	assert.Equal(t, "Português 'original'", info.AudioTracks[0].Name)
+	assert.Equal(t, "Audio 2", info.AudioTracks[1].Name)
+	assert.Empty(t, info.AudioTracks[1].Language)
+	assert.Equal(t, "eng", info.AudioTracks[2].Name)
+
+	require.Len(t, info.SubtitleTracks, 2)
+	assert.Equal(t, "Português  forçada", info.SubtitleTracks[0].Name)
+	assert.Equal(t, "Subtitle 2", info.SubtitleTracks[1].Name)
+	assert.Equal(t, 2, info.SubtitleTracks[1].StreamIndex)
+}
+
+func TestParseProbe_DefaultAudioTrack(t *testing.T) {
+	video := probeStream{CodecType: "video", CodecName: "h264", Width: 1920, Height: 1080}
+	audio := func(language string, isDefault bool) probeStream {
+		s := probeStream{CodecType: "audio", Channels: 2, Tags: map[string]string{"language": language}}
+		if isDefault {
+			s.Disposition = map[string]int{"default": 1}
+		}
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		streams []probeStream
+		want    []bool
+	}{
+		{name: "flagged track kept", streams: []probeStream{video, audio("eng", false), audio("por", true)}, want: []bool{false, true}},
+		{name: "first of several flagged", streams: []probeStream{video, audio("eng", false), audio("por", true), audio("spa", true)}, want: []bool{false, true, false}},
+		{name: "none flagged picks the first", streams: []probeStream{video, audio("por", false), audio("eng", false)}, want: []bool{true, false}},
+		{name: "single track", streams: []probeStream{video, audio("por", false)}, want: []bool{true}},
+		{name: "no audio", streams: []probeStream{video}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseProbe(probeOutput{Streams: tt.streams})
+			require.NoError(t, err)
+
+			var defaults []bool
+			for i, track := range info.AudioTracks {
+				assert.Equal(t, i, track.Index)
+				defaults = append(defaults, track.Default)
+			}
+			assert.Equal(t, tt.want, defaults)
+		})
+	}
+}
+
+func TestParseProbe_NoVideo(t *testing.T) {
+	_, err := parseProbe(probeOutput{Streams: []probeStream{{CodecType: "audio"}}})
+	assert.EqualError(t, err, "nenhum stream de vídeo encontrado")
+}
+
+func TestProgressDuration(t *testing.T) {
+	progress := "frame=250\nout_time_us=10000000\nprogress=continue\n" +
+		"frame=36000\nout_time_us=N/A\nprogress=continue\n" +
+		"frame=36012\nout_time_us=1440480000\nout_time=00:24:00.480000\nprogress=end\n"
+	assert.InDelta(t, 1440.48, progressDuration(progress), 1e-9)
+	assert.Zero(t, progressDuration("progress=end\n"))
+}
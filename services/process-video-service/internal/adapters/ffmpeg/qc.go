@@ -0,0 +1,121 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+const (
+	// blackdetect: at least 2s where 98% of the pixels are under 10% luma
+	qcBlackFilter = "blackdetect=d=2:pic_th=0.98:pix_th=0.10"
+	// freezedetect: 5s without a visible change
+	qcFreezeFilter = "freezedetect=n=-60dB:d=5"
+	// silencedetect: 5s under -50 dBFS
+	qcSilenceFilter = "silencedetect=noise=-50dB:d=5"
+)
+
+var (
+	qcBlack        = regexp.MustCompile(`black_start:\s*([0-9.]+)\s+black_end:\s*([0-9.]+)\s+black_duration:\s*([0-9.]+)`)
+	qcSilenceStart = regexp.MustCompile(`silence_start:\s*(-?[0-9.]+)`)
+	qcSilenceEnd   = regexp.MustCompile(`silence_end:\s*([0-9.]+)\s*\|\s*silence_duration:\s*([0-9.]+)`)
+	qcFreezeStart  = regexp.MustCompile(`freeze_start:\s*([0-9.]+)`)
+	qcFreezeEnd    = regexp.MustCompile(`freeze_end:\s*([0-9.]+)`)
+	qcIdet         = regexp.MustCompile(`Multi frame detection:\s*TFF:\s*(\d+)\s+BFF:\s*(\d+)\s+Progressive:\s*(\d+)\s+Undetermined:\s*(\d+)`)
+)
+
+// AnalyzeQC decodes the whole source once through idet, blackdetect and freezedetect
+// (and silencedetect on the first audio track) and reports what they found. Deciding
+// what the findings mean is left to the caller.
+func (f *FFMPEGProcessor) AnalyzeQC(ctx context.Context, job *models.Job) (*models.QCReport, error) {
+	event, info := job.Event, job.Info
+
+	filter := fmt.Sprintf("[0:v:0]idet,%s,%s[v]", qcBlackFilter, qcFreezeFilter)
+	args := []string{"-hide_banner", "-nostats", "-i", "pipe:0"}
+	if len(info.AudioTracks) > 0 {
+		filter += fmt.Sprintf(";[0:a:%d]%s[a]", info.AudioTracks[0].Index, qcSilenceFilter)
+		args = append(args, "-filter_complex", filter, "-map", "[v]", "-map", "[a]")
+	} else {
+		args = append(args, "-filter_complex", filter, "-map", "[v]")
+	}
+	args = append(args, "-f", "null", "-")
+
+	stream, err := f.bucket.GetObjectStream(event.Bucket, event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = stream
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro na análise de QC: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	return parseQC(stderr.String(), info.Duration), nil
+}
+
+// parseQC closes intervals still open at the end of the input at duration.
+func parseQC(log string, duration float64) *models.QCReport {
+	report := &models.QCReport{
+		Black:   []models.QCInterval{},
+		Silence: []models.QCInterval{},
+		Freeze:  []models.QCInterval{},
+	}
+
+	silenceStart, freezeStart := -1.0, -1.0
+	for _, line := range strings.Split(log, "\n") {
+		if m := qcBlack.FindStringSubmatch(line); m != nil {
+			report.Black = append(report.Black, models.QCInterval{
+				Start: parseFloat(m[1]), End: parseFloat(m[2]), Duration: parseFloat(m[3]),
+			})
+		}
+		if m := qcSilenceStart.FindStringSubmatch(line); m != nil {
+			silenceStart = max(parseFloat(m[1]), 0)
+		}
+		if m := qcSilenceEnd.FindStringSubmatch(line); m != nil && silenceStart >= 0 {
+			report.Silence = append(report.Silence, models.QCInterval{
+				Start: silenceStart, End: parseFloat(m[1]), Duration: parseFloat(m[2]),
+			})
+			silenceStart = -1
+		}
+		if m := qcFreezeStart.FindStringSubmatch(line); m != nil {
+			freezeStart = parseFloat(m[1])
+		}
+		if m := qcFreezeEnd.FindStringSubmatch(line); m != nil && freezeStart >= 0 {
+			end := parseFloat(m[1])
+			report.Freeze = append(report.Freeze, models.QCInterval{Start: freezeStart, End: end, Duration: end - freezeStart})
+			freezeStart = -1
+		}
+		if m := qcIdet.FindStringSubmatch(line); m != nil {
+			report.Interlace.TFF, _ = strconv.Atoi(m[1])
+			report.Interlace.BFF, _ = strconv.Atoi(m[2])
+			report.Interlace.Progressive, _ = strconv.Atoi(m[3])
+			report.Interlace.Undetermined, _ = strconv.Atoi(m[4])
+		}
+	}
+
+	if silenceStart >= 0 && duration > silenceStart {
+		report.Silence = append(report.Silence, models.QCInterval{Start: silenceStart, End: duration, Duration: duration - silenceStart})
+	}
+	if freezeStart >= 0 && duration > freezeStart {
+		report.Freeze = append(report.Freeze, models.QCInterval{Start: freezeStart, End: duration, Duration: duration - freezeStart})
+	}
+	return report
+}
+
+func parseFloat(v string) float64 {
+	n, _ := strconv.ParseFloat(v, 64)
+	return n
+}
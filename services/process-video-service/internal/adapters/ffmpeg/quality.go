@@ -0,0 +1,189 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"process-video-service/internal/models"
+)
+
+var (
+	psnrAverage = regexp.MustCompile(`PSNR .*average:([0-9.]+|inf)`)
+	ssimAll     = regexp.MustCompile(`SSIM .*All:([0-9.]+)`)
+	vmafScore   = regexp.MustCompile(`VMAF score: ([0-9.]+)`)
+
+	libvmafOnce      sync.Once
+	libvmafAvailable bool
+)
+
+// MeasureQuality compares a published video rendition with the source put through the
+// same filters as the encode. The rendition is streamed back from the bucket (decrypted with the
+// job key) into a second ffmpeg input, so nothing is staged on disk.
+func (f *FFMPEGProcessor) MeasureQuality(ctx context.Context, job *models.Job, rendition models.Rendition, opts models.QualityOptions) (*models.QualityScores, error) {
+	if len(rendition.Segments) == 0 {
+		return nil, fmt.Errorf("rendição sem segmentos")
+	}
+
+	source, err := f.bucket.GetObjectStream(job.Event.Bucket, job.Event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	vmaf := opts.VMAF && hasLibvmaf()
+	metrics := 2
+	if vmaf {
+		metrics = 3
+	}
+	// both inputs start from zero so the frames line up whatever the container offsets
+	scale := videoFilter(job, "scale", rendition.Height, rendition.VideoRange)
+	if rendition.Width > 0 {
+		scale = sourceFilter(job) + fmt.Sprintf("scale=%d:%d", rendition.Width, rendition.Height)
+		if job.Info.HDR() && rendition.VideoRange == models.VideoRangeSDR {
+			scale += toneMapFilter
+		}
+		scale += overlayFilter(job, rendition.Height)
+	}
+	filter := fmt.Sprintf("[0:v:0]%s,setpts=PTS-STARTPTS,split=%d", scale, metrics)
+	filter += "[ref0][ref1]"
+	if vmaf {
+		filter += "[ref2]"
+	}
+	filter += fmt.Sprintf(";[1:v:0]setpts=PTS-STARTPTS,split=%d[dist0][dist1]", metrics)
+	if vmaf {
+		filter += "[dist2]"
+	}
+	filter += ";[dist0][ref0]psnr;[dist1][ref1]ssim"
+	if vmaf {
+		filter += ";[dist2][ref2]libvmaf"
+	}
+
+	args := []string{"-hide_banner", "-i", "pipe:0"}
+	if job.Encryption != nil && job.Encryption.Scheme == models.EncryptionCENC {
+		args = append(args, "-decryption_key", hex.EncodeToString(job.Encryption.Key))
+	}
+	args = append(args, "-i", "pipe:3", "-filter_complex", filter, "-f", "null", "-")
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = source
+	cmd.ExtraFiles = []*os.File{reader}
+	cmd.Stderr = &stderr
+	err = cmd.Start()
+	// the child holds its own copy; once it exits, writes fail instead of blocking
+	reader.Close()
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	feed := make(chan error, 1)
+	go func() {
+		err := f.writeRendition(writer, job, rendition)
+		writer.Close()
+		feed <- err
+	}()
+
+	err = cmd.Wait()
+	feedErr := <-feed
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("cancelado pelo contexto")
+		}
+		return nil, fmt.Errorf("erro ao medir qualidade: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+	if feedErr != nil {
+		return nil, feedErr
+	}
+
+	return parseQuality(stderr.String(), vmaf)
+}
+
+func (f *FFMPEGProcessor) writeRendition(w io.Writer, job *models.Job, rendition models.Rendition) error {
+	dir := path.Dir(rendition.PlaylistKey)
+	keys := []string{}
+	if rendition.InitKey != "" {
+		keys = append(keys, rendition.InitKey)
+	}
+	for _, s := range rendition.Segments {
+		keys = append(keys, dir+"/"+s.Name)
+	}
+
+	for _, key := range keys {
+		data, err := f.readObject(key)
+		if err != nil {
+			return err
+		}
+		if job.Encryption != nil && job.Encryption.Scheme == models.EncryptionAES128 {
+			if data, err = decryptAES128(data, job.Encryption); err != nil {
+				return err
+			}
+		}
+		// ffmpeg stops reading once the shorter input ends; its exit status tells if that's a problem
+		if _, err := w.Write(data); err != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+func parseQuality(log string, vmaf bool) (*models.QualityScores, error) {
+	scores := &models.QualityScores{}
+
+	m := psnrAverage.FindStringSubmatch(log)
+	if m == nil {
+		return nil, fmt.Errorf("PSNR não encontrado na saída do ffmpeg")
+	}
+	// identical pictures report an infinite PSNR; 100 dB is how libvmaf caps it too
+	scores.PSNR = 100
+	if m[1] != "inf" {
+		scores.PSNR, _ = strconv.ParseFloat(m[1], 64)
+	}
+
+	m = ssimAll.FindStringSubmatch(log)
+	if m == nil {
+		return nil, fmt.Errorf("SSIM não encontrado na saída do ffmpeg")
+	}
+	scores.SSIM, _ = strconv.ParseFloat(m[1], 64)
+
+	if vmaf {
+		m = vmafScore.FindStringSubmatch(log)
+		if m == nil {
+			return nil, fmt.Errorf("VMAF não encontrado na saída do ffmpeg")
+		}
+		scores.VMAF, _ = strconv.ParseFloat(m[1], 64)
+	}
+	return scores, nil
+}
+
+// hasLibvmaf reports whether the local ffmpeg build was compiled with libvmaf.
+func hasLibvmaf() bool {
+	libvmafOnce.Do(func() {
+		out, err := exec.Command("ffmpeg", "-hide_banner", "-filters").Output()
+		libvmafAvailable = err == nil && bytes.Contains(out, []byte(" libvmaf "))
+	})
+	return libvmafAvailable
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
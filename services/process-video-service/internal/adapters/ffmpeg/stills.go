@@ -0,0 +1,228 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"process-video-service/internal/models"
+)
+
+type frameScore struct {
+	Path     string
+	Time     float64
+	Score    float64
+	Rejected bool
+}
+
+// GenerateStills samples frames across the video, scores them and uploads the best
+// candidates at every configured width under videos/<epId>/stills/.
+func (f *FFMPEGProcessor) GenerateStills(ctx context.Context, job *models.Job, opts models.StillOptions) ([]models.Still, error) {
+	event, info := job.Event, job.Info
+
+	tmp := filepath.Join(f.tmpDir, fmt.Sprintf("%s-stills", event.Key))
+	framesDir := filepath.Join(tmp, "frames")
+	os.MkdirAll(framesDir, 0755)
+	defer os.RemoveAll(tmp)
+
+	interval := 10.0
+	if info.Duration > 0 {
+		interval = info.Duration / float64(opts.Samples)
+	}
+
+	sourceWidth, _ := sourceSize(job)
+	widths := stillWidths(opts.Widths, sourceWidth)
+	filter := fmt.Sprintf("fps=1/%g,", interval) + sourceFilter(job) + fmt.Sprintf("scale=%d:-2", widths[0])
+	if info.HDR() {
+		filter += toneMapFilter
+	}
+
+	stream, err := f.bucket.GetObjectStream(event.Bucket, event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-i", "pipe:0",
+		"-map", "0:v:0",
+		"-an", "-sn",
+		"-vf", filter,
+		"-q:v", "2",
+		filepath.Join(framesDir, "frame%05d.jpg"),
+	)
+	cmd.Stdin = stream
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	frames, err := os.ReadDir(framesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var scores []frameScore
+	for i, fr := range frames {
+		t := float64(i) * interval
+		// opening titles and end credits rarely make good artwork
+		if info.Duration > 0 && (t < info.Duration*0.05 || t > info.Duration*0.9) {
+			continue
+		}
+		path := filepath.Join(framesDir, fr.Name())
+		score, err := scoreFrame(path)
+		if err != nil {
+			continue
+		}
+		score.Time = t
+		scores = append(scores, score)
+	}
+
+	picked := pickStills(scores, opts.Candidates, interval*2)
+	if len(picked) == 0 {
+		return nil, fmt.Errorf("nenhum frame adequado para capa")
+	}
+
+	s3Prefix := fmt.Sprintf("videos/%s/stills", event.EpId)
+	var stills []models.Still
+	for n, frame := range picked {
+		still := models.Still{Time: frame.Time, Score: frame.Score}
+		for _, w := range widths {
+			out := filepath.Join(tmp, fmt.Sprintf("%d-%d.jpg", n, w))
+			cmd := exec.CommandContext(ctx, "ffmpeg", "-i", frame.Path, "-vf", fmt.Sprintf("scale=%d:-2", w), "-q:v", "2", out)
+			if err := cmd.Run(); err != nil {
+				return nil, err
+			}
+
+			file, err := os.Open(out)
+			if err != nil {
+				return nil, err
+			}
+			key := s3Prefix + "/" + filepath.Base(out)
+			err = f.bucket.UploadFileReader(f.processedBucketName, key, file)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+			still.Images = append(still.Images, models.StillImage{Width: w, Key: key})
+		}
+		stills = append(stills, still)
+	}
+
+	return stills, nil
+}
+
+// stillWidths returns the requested widths, largest first, never upscaling the source.
+func stillWidths(requested []int, sourceWidth int) []int {
+	seen := map[int]bool{}
+	var widths []int
+	for _, w := range requested {
+		w = min(w, sourceWidth)
+		if w > 0 && !seen[w] {
+			seen[w] = true
+			widths = append(widths, w)
+		}
+	}
+	if len(widths) == 0 {
+		widths = append(widths, sourceWidth)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(widths)))
+	return widths
+}
+
+// pickStills keeps minGap seconds between candidates so they come from different shots.
+func pickStills(scores []frameScore, count int, minGap float64) []frameScore {
+	sorted := make([]frameScore, 0, len(scores))
+	for _, s := range scores {
+		if !s.Rejected {
+			sorted = append(sorted, s)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+
+	var picked []frameScore
+	for _, s := range sorted {
+		if len(picked) == count {
+			break
+		}
+		tooClose := false
+		for _, p := range picked {
+			tooClose = tooClose || math.Abs(p.Time-s.Time) < minGap
+		}
+		if !tooClose {
+			picked = append(picked, s)
+		}
+	}
+	return picked
+}
+
+// scoreFrame rejects black, white and flat frames and favours detail and skin tones.
+func scoreFrame(path string) (frameScore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return frameScore{}, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return frameScore{}, err
+	}
+
+	b := img.Bounds()
+	var sum, sumSq, gradient float64
+	var pixels, centerPixels, skinPixels int
+	for y := b.Min.Y; y < b.Max.Y-1; y += 2 {
+		for x := b.Min.X; x < b.Max.X-1; x += 2 {
+			l, cb, cr := ycbcrAt(img, x, y)
+			right, _, _ := ycbcrAt(img, x+1, y)
+			below, _, _ := ycbcrAt(img, x, y+1)
+
+			sum += l
+			sumSq += l * l
+			gradient += (math.Abs(l-right) + math.Abs(l-below)) / 2
+			pixels++
+
+			inCenter := x > b.Min.X+b.Dx()/4 && x < b.Max.X-b.Dx()/4 && y < b.Min.Y+b.Dy()*2/3
+			if inCenter {
+				centerPixels++
+				if cb >= 77 && cb <= 127 && cr >= 133 && cr <= 173 {
+					skinPixels++
+				}
+			}
+		}
+	}
+	if pixels == 0 {
+		return frameScore{}, fmt.Errorf("frame vazio: %s", path)
+	}
+
+	mean := sum / float64(pixels)
+	stddev := math.Sqrt(math.Max(0, sumSq/float64(pixels)-mean*mean))
+	detail := gradient / float64(pixels) / 255
+	skin := 0.0
+	if centerPixels > 0 {
+		skin = float64(skinPixels) / float64(centerPixels)
+	}
+
+	score := frameScore{
+		Path:     path,
+		Rejected: mean < 20 || mean > 235 || stddev < 18,
+		Score:    detail*10 + math.Min(skin, 0.3)*2 + stddev/255,
+	}
+	return score, nil
+}
+
+func ycbcrAt(img image.Image, x, y int) (float64, float64, float64) {
+	if c, ok := img.At(x, y).(color.YCbCr); ok {
+		return float64(c.Y), float64(c.Cb), float64(c.Cr)
+	}
+	r, g, b, _ := img.At(x, y).RGBA()
+	l, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+	return float64(l), float64(cb), float64(cr)
+}
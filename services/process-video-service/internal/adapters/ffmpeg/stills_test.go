@@ -0,0 +1,102 @@
+package ffmpeg
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFrame(t *testing.T, name string, fill func(x, y int) color.Color) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 36))
+	for y := 0; y < 36; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, fill(x, y))
+		}
+	}
+	path := filepath.Join(t.TempDir(), name)
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, jpeg.Encode(file, img, &jpeg.Options{Quality: 95}))
+	return path
+}
+
+func TestScoreFrame(t *testing.T) {
+	gray := func(v uint8) func(x, y int) color.Color {
+		return func(x, y int) color.Color { return color.Gray{Y: v} }
+	}
+	gradient := func(x, y int) color.Color { return color.Gray{Y: uint8(20 + x*3)} }
+	stripes := func(x, y int) color.Color {
+		if (x/4)%2 == 0 {
+			return color.Gray{Y: 40}
+		}
+		return color.Gray{Y: 210}
+	}
+
+	black, err := scoreFrame(writeFrame(t, "black.jpg", gray(5)))
+	require.NoError(t, err)
+	assert.True(t, black.Rejected, "black frame")
+
+	white, err := scoreFrame(writeFrame(t, "white.jpg", gray(250)))
+	require.NoError(t, err)
+	assert.True(t, white.Rejected, "white flash")
+
+	fade, err := scoreFrame(writeFrame(t, "fade.jpg", gray(128)))
+	require.NoError(t, err)
+	assert.True(t, fade.Rejected, "low contrast")
+
+	smooth, err := scoreFrame(writeFrame(t, "smooth.jpg", gradient))
+	require.NoError(t, err)
+	detailed, err := scoreFrame(writeFrame(t, "detailed.jpg", stripes))
+	require.NoError(t, err)
+	assert.False(t, smooth.Rejected)
+	assert.False(t, detailed.Rejected)
+	assert.Greater(t, detailed.Score, smooth.Score)
+
+	_, err = scoreFrame(filepath.Join(t.TempDir(), "missing.jpg"))
+	assert.Error(t, err)
+}
+
+func TestPickStills(t *testing.T) {
+	scores := []frameScore{
+		{Time: 100, Score: 0.9},
+		{Time: 110, Score: 0.95},
+		{Time: 300, Score: 0.5},
+		{Time: 500, Score: 2, Rejected: true},
+		{Time: 700, Score: 0.7},
+		{Time: 900, Score: 0.1},
+	}
+
+	tests := []struct {
+		name   string
+		count  int
+		minGap float64
+		want   []float64
+	}{
+		{name: "best first, neighbours of a pick skipped", count: 3, minGap: 20, want: []float64{110, 700, 300}},
+		{name: "small gap keeps the same shot", count: 2, minGap: 5, want: []float64{110, 100}},
+		{name: "fewer candidates than asked", count: 10, minGap: 250, want: []float64{110, 700}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var times []float64
+			for _, s := range pickStills(scores, tt.count, tt.minGap) {
+				times = append(times, s.Time)
+			}
+			assert.Equal(t, tt.want, times)
+		})
+	}
+}
+
+func TestStillWidths(t *testing.T) {
+	assert.Equal(t, []int{1280, 640, 320}, stillWidths([]int{320, 1280, 640}, 1920))
+	assert.Equal(t, []int{1280, 640}, stillWidths([]int{1920, 640, 1280}, 1280))
+	assert.Equal(t, []int{854}, stillWidths(nil, 854))
+}
@@ -0,0 +1,91 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+func (f *FFMPEGProcessor) ProcessSubtitle(ctx context.Context, job *models.Job, track models.SubtitleTrack) (*models.SubtitleRendition, error) {
+	event := job.Event
+
+	tmp := filepath.Join(f.tmpDir, fmt.Sprintf("%s-sub-%d", event.Key, track.Index))
+	os.MkdirAll(tmp, 0755)
+	defer os.RemoveAll(tmp)
+
+	var stream io.ReadCloser
+	var err error
+	var args []string
+	if track.SidecarKey != "" {
+		stream, err = f.bucket.GetObjectStream(event.Bucket, track.SidecarKey)
+		args = []string{"-f", sidecarFormat(track.SidecarKey), "-i", "pipe:0"}
+	} else {
+		stream, err = f.bucket.GetObjectStream(event.Bucket, event.Key)
+		args = []string{"-i", "pipe:0", "-map", fmt.Sprintf("0:s:%d", track.StreamIndex)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	vttPath := filepath.Join(tmp, "full.vtt")
+	args = append(args, "-c:s", "webvtt", "-f", "webvtt", vttPath)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = stream
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(vttPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s3Prefix := fmt.Sprintf("videos/%s/subtitles/%d", event.EpId, track.Index)
+	// fMP4 fragments start at zero while ffmpeg's TS segments carry the mux delay
+	startPTS := mpegtsStartPTS
+	if job.Packaging.SegmentType == models.SegmentTypeFMP4 {
+		startPTS = 0
+	}
+	vttSegments, durations := segmentWebVTT(parseWebVTT(data), job.Info.Duration, hlsTime, startPTS)
+
+	var segments []models.Segment
+	for i, seg := range vttSegments {
+		name := fmt.Sprintf("seg%03d.vtt", i)
+		if err := f.bucket.UploadFileReader(f.processedBucketName, s3Prefix+"/"+name, bytes.NewReader(seg)); err != nil {
+			return nil, err
+		}
+		segments = append(segments, models.Segment{Name: name, Duration: durations[i], Size: int64(len(seg))})
+	}
+
+	if err := f.uploadMediaPlaylist(s3Prefix, segments, "", nil); err != nil {
+		return nil, err
+	}
+
+	rendition := &models.SubtitleRendition{SubtitleTrack: track, PlaylistKey: s3Prefix + "/index.m3u8"}
+
+	// DASH players take the track as a single file
+	if job.Packaging.Dash {
+		rendition.FileKey = s3Prefix + "/full.vtt"
+		if err := f.bucket.UploadFileReader(f.processedBucketName, rendition.FileKey, bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	return rendition, nil
+}
+
+func sidecarFormat(key string) string {
+	if strings.HasSuffix(strings.ToLower(key), ".vtt") {
+		return "webvtt"
+	}
+	return "srt"
+}
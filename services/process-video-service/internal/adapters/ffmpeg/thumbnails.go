@@ -0,0 +1,158 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+func (f *FFMPEGProcessor) GenerateThumbnails(ctx context.Context, job *models.Job, opts models.ThumbnailOptions) (*models.ThumbnailAssets, error) {
+	event := job.Event
+
+	tmp := filepath.Join(f.tmpDir, fmt.Sprintf("%s-thumbs", event.Key))
+	framesDir := filepath.Join(tmp, "frames")
+	os.MkdirAll(framesDir, 0755)
+	defer os.RemoveAll(tmp)
+
+	filter, height := thumbnailFilter(job, opts)
+
+	stream, err := f.bucket.GetObjectStream(event.Bucket, event.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	// extracting single frames first gives the exact thumbnail count for the VTT cues
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-i", "pipe:0",
+		"-map", "0:v:0",
+		"-an", "-sn",
+		"-vf", filter,
+		"-q:v", "3",
+		filepath.Join(framesDir, "frame%05d.jpg"),
+	)
+	var stderr bytes.Buffer
+	cmd.Stdin = stream
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("erro ao extrair frames das thumbnails: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	frames, err := os.ReadDir(framesDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("nenhum frame extraído para thumbnails")
+	}
+
+	// -q:v is mjpeg's scale (lower is better); libwebp takes -quality 0-100
+	codec := []string{"-c:v", "mjpeg", "-q:v", "3"}
+	if opts.Format == "webp" {
+		codec = []string{"-c:v", "libwebp", "-quality", "75"}
+	}
+	spritePattern := filepath.Join(tmp, "sprite%03d."+opts.Format)
+
+	args := []string{
+		"-framerate", "1",
+		"-i", filepath.Join(framesDir, "frame%05d.jpg"),
+		"-vf", fmt.Sprintf("tile=%dx%d", opts.Columns, opts.Rows),
+	}
+	args = append(args, codec...)
+	args = append(args, "-start_number", "0", spritePattern)
+
+	stderr.Reset()
+	cmd = exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("erro ao montar sprites: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	s3Prefix := fmt.Sprintf("videos/%s/thumbs", event.EpId)
+	assets := &models.ThumbnailAssets{
+		VTTKey:   s3Prefix + "/thumbnails.vtt",
+		Interval: opts.Interval,
+		Width:    opts.Width,
+		Height:   height,
+	}
+
+	sprites, err := os.ReadDir(tmp)
+	if err != nil {
+		return nil, err
+	}
+	for _, sp := range sprites {
+		if !strings.HasPrefix(sp.Name(), "sprite") {
+			continue
+		}
+		file, err := os.Open(filepath.Join(tmp, sp.Name()))
+		if err != nil {
+			return nil, err
+		}
+		key := s3Prefix + "/" + sp.Name()
+		err = f.bucket.UploadFileReader(f.processedBucketName, key, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		assets.SpriteKeys = append(assets.SpriteKeys, key)
+	}
+
+	vtt := thumbnailsVTT(len(frames), job.Info.Duration, opts, height)
+	if err := f.bucket.UploadFileReader(f.processedBucketName, assets.VTTKey, bytes.NewReader(vtt)); err != nil {
+		return nil, err
+	}
+
+	return assets, nil
+}
+
+// thumbnailFilter samples the cropped, deinterlaced SDR picture the renditions show.
+func thumbnailFilter(job *models.Job, opts models.ThumbnailOptions) (string, int) {
+	width, height := sourceSize(job)
+	height = evenHeight(opts.Width, width, height)
+	filter := fmt.Sprintf("fps=1/%g,", opts.Interval) + sourceFilter(job) + fmt.Sprintf("scale=%d:%d", opts.Width, height)
+	if job.Info.HDR() {
+		filter += toneMapFilter
+	}
+	return filter, height
+}
+
+func thumbnailsVTT(count int, duration float64, opts models.ThumbnailOptions, height int) []byte {
+	perSprite := opts.Columns * opts.Rows
+
+	var vtt bytes.Buffer
+	vtt.WriteString("WEBVTT\n\n")
+	for i := 0; i < count; i++ {
+		start := float64(i) * opts.Interval
+		end := start + opts.Interval
+		if duration > 0 {
+			end = math.Min(end, duration)
+		}
+		if end <= start {
+			break
+		}
+
+		tile := i % perSprite
+		x := (tile % opts.Columns) * opts.Width
+		y := (tile / opts.Columns) * height
+
+		vtt.WriteString(fmt.Sprintf("%s --> %s\n", formatVTTTimestamp(start), formatVTTTimestamp(end)))
+		vtt.WriteString(fmt.Sprintf("sprite%03d.%s#xywh=%d,%d,%d,%d\n\n", i/perSprite, opts.Format, x, y, opts.Width, height))
+	}
+	return vtt.Bytes()
+}
+
+// evenHeight keeps the source aspect ratio for the given width, rounded to an even value.
+func evenHeight(width, srcWidth, srcHeight int) int {
+	ratio := 9.0 / 16.0
+	if srcWidth > 0 && srcHeight > 0 {
+		ratio = float64(srcHeight) / float64(srcWidth)
+	}
+	return int(math.Max(1, math.Round(float64(width)*ratio/2))) * 2
+}
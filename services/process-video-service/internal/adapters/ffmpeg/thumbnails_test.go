@@ -0,0 +1,48 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThumbnailsVTT(t *testing.T) {
+	opts := models.ThumbnailOptions{Interval: 10, Width: 160, Columns: 2, Rows: 2, Format: "webp"}
+
+	// five frames over 45s: a 2x2 sheet fills up, the fifth starts the next one and
+	// its cue stops at the end of the video
+	want := "WEBVTT\n\n" +
+		"00:00:00.000 --> 00:00:10.000\nsprite000.webp#xywh=0,0,160,90\n\n" +
+		"00:00:10.000 --> 00:00:20.000\nsprite000.webp#xywh=160,0,160,90\n\n" +
+		"00:00:20.000 --> 00:00:30.000\nsprite000.webp#xywh=0,90,160,90\n\n" +
+		"00:00:30.000 --> 00:00:40.000\nsprite000.webp#xywh=160,90,160,90\n\n" +
+		"00:00:40.000 --> 00:00:45.000\nsprite001.webp#xywh=0,0,160,90\n\n"
+	assert.Equal(t, want, string(thumbnailsVTT(5, 45, opts, 90)))
+
+	// a frame sampled past the end gets no cue
+	got := string(thumbnailsVTT(3, 20, opts, 90))
+	assert.NotContains(t, got, "00:00:20.000 -->")
+}
+
+func TestThumbnailFilter(t *testing.T) {
+	opts := models.ThumbnailOptions{Interval: 10, Width: 160}
+	job := &models.Job{Info: &models.MediaInfo{Width: 1920, Height: 1080}}
+
+	filter, height := thumbnailFilter(job, opts)
+	assert.Equal(t, "fps=1/10,scale=160:90", filter)
+	assert.Equal(t, 90, height)
+
+	// a letterboxed 2.40:1 picture gives shorter tiles
+	job.Crop = &models.Crop{Width: 1920, Height: 800, X: 0, Y: 140}
+	job.Deinterlace = models.DeinterlaceBwdif
+	filter, height = thumbnailFilter(job, opts)
+	assert.Equal(t, "fps=1/10,bwdif,crop=1920:800:0:140,scale=160:66", filter)
+	assert.Equal(t, 66, height)
+
+	// an HDR source is tone mapped, JPEG and WebP tiles are SDR
+	job.Info.VideoRange = models.VideoRangePQ
+	filter, _ = thumbnailFilter(job, opts)
+	assert.Equal(t, "fps=1/10,bwdif,crop=1920:800:0:140,scale=160:66"+toneMapFilter, filter)
+}
@@ -0,0 +1,62 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+// VerifySegment downloads a published segment (behind its init segment for fMP4) and
+// decodes it completely, failing on the first decoding error. Encrypted segments are
+// decoded with the job key, the way a licensed player would.
+func (f *FFMPEGProcessor) VerifySegment(ctx context.Context, job *models.Job, key, initKey string) error {
+	var data []byte
+	for _, k := range []string{initKey, key} {
+		if k == "" {
+			continue
+		}
+		part, err := f.readObject(k)
+		if err != nil {
+			return err
+		}
+		data = append(data, part...)
+	}
+
+	args := []string{"-v", "error", "-xerror"}
+	if enc := job.Encryption; enc != nil {
+		switch enc.Scheme {
+		case models.EncryptionAES128:
+			var err error
+			if data, err = decryptAES128(data, enc); err != nil {
+				return err
+			}
+		case models.EncryptionCENC:
+			args = append(args, "-decryption_key", hex.EncodeToString(enc.Key))
+		}
+	}
+	args = append(args, "-i", "pipe:0", "-f", "null", "-")
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s não decodifica: %w: %s", key, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (f *FFMPEGProcessor) readObject(key string) ([]byte, error) {
+	stream, err := f.bucket.GetObjectStream(f.processedBucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	return io.ReadAll(stream)
+}
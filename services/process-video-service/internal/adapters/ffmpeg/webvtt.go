@@ -0,0 +1,116 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// mpegtsStartPTS is the 1.4s the mpegts muxer delays timestamps by, on the 90kHz clock.
+const mpegtsStartPTS = 126000
+
+type vttCue struct {
+	Start    float64
+	End      float64
+	Settings string
+	Payload  string
+}
+
+func parseWebVTT(data []byte) []vttCue {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	var cues []vttCue
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		for i, line := range lines {
+			if !strings.Contains(line, "-->") {
+				continue
+			}
+			cue, ok := parseCueTiming(line)
+			if ok {
+				cue.Payload = strings.Join(lines[i+1:], "\n")
+				cues = append(cues, cue)
+			}
+			break
+		}
+	}
+	return cues
+}
+
+func parseCueTiming(line string) (vttCue, bool) {
+	parts := strings.SplitN(line, "-->", 2)
+	fields := strings.Fields(parts[1])
+	if len(fields) == 0 {
+		return vttCue{}, false
+	}
+	start, err := parseVTTTimestamp(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return vttCue{}, false
+	}
+	end, err := parseVTTTimestamp(fields[0])
+	if err != nil {
+		return vttCue{}, false
+	}
+	return vttCue{Start: start, End: end, Settings: strings.Join(fields[1:], " ")}, true
+}
+
+func parseVTTTimestamp(ts string) (float64, error) {
+	parts := strings.Split(strings.Replace(ts, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	total := 0.0
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		total = total*60 + v
+	}
+	return total, nil
+}
+
+func formatVTTTimestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// segmentWebVTT repeats a cue in every segment it overlaps.
+func segmentWebVTT(cues []vttCue, mediaDuration, segmentDuration float64, startPTS int) ([][]byte, []float64) {
+	total := mediaDuration
+	for _, c := range cues {
+		total = math.Max(total, c.End)
+	}
+	// without a duration or cues, a single empty segment still needs a real length
+	if total <= 0 {
+		total = segmentDuration
+	}
+	count := int(math.Ceil(total / segmentDuration))
+
+	segments := make([][]byte, 0, count)
+	durations := make([]float64, 0, count)
+	for i := 0; i < count; i++ {
+		start := float64(i) * segmentDuration
+		end := start + segmentDuration
+
+		var seg bytes.Buffer
+		seg.WriteString("WEBVTT\n")
+		seg.WriteString(fmt.Sprintf("X-TIMESTAMP-MAP=MPEGTS:%d,LOCAL:00:00:00.000\n\n", startPTS))
+		for _, c := range cues {
+			if c.Start >= end || c.End <= start {
+				continue
+			}
+			seg.WriteString(formatVTTTimestamp(c.Start) + " --> " + formatVTTTimestamp(c.End))
+			if c.Settings != "" {
+				seg.WriteString(" " + c.Settings)
+			}
+			seg.WriteString("\n" + c.Payload + "\n\n")
+		}
+
+		segments = append(segments, seg.Bytes())
+		durations = append(durations, math.Min(segmentDuration, total-start))
+	}
+	return segments, durations
+}
@@ -0,0 +1,60 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWebVTT(t *testing.T) {
+	data := "WEBVTT\r\n\r\n" +
+		"NOTE converted from srt\r\n\r\n" +
+		"1\r\n00:00:01.000 --> 00:00:04.500 line:90% align:center\r\nOlá\r\nmundo\r\n\r\n" +
+		"00:01.5 --> 00:02,250\r\n<i>sem hora</i>\r\n\r\n" +
+		"3\r\n00:00:05.000 --> depois\r\ninválida\r\n"
+
+	assert.Equal(t, []vttCue{
+		{Start: 1, End: 4.5, Settings: "line:90% align:center", Payload: "Olá\nmundo"},
+		{Start: 1.5, End: 2.25, Payload: "<i>sem hora</i>"},
+	}, parseWebVTT([]byte(data)))
+}
+
+func TestFormatVTTTimestamp(t *testing.T) {
+	assert.Equal(t, "00:00:00.000", formatVTTTimestamp(0))
+	assert.Equal(t, "00:01:01.500", formatVTTTimestamp(61.5))
+	assert.Equal(t, "01:02:05.250", formatVTTTimestamp(3725.25))
+	assert.Equal(t, "00:00:02.000", formatVTTTimestamp(1.9996))
+}
+
+func TestSegmentWebVTT(t *testing.T) {
+	header := "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:126000,LOCAL:00:00:00.000\n\n"
+	cues := []vttCue{
+		{Start: 1, End: 3, Payload: "primeira"},
+		{Start: 5, End: 7, Settings: "align:start", Payload: "atravessa"},
+	}
+
+	segments, durations := segmentWebVTT(cues, 13, 6, mpegtsStartPTS)
+	assert.Equal(t, []float64{6, 6, 1}, durations)
+	assert.Equal(t, []string{
+		header + "00:00:01.000 --> 00:00:03.000\nprimeira\n\n00:00:05.000 --> 00:00:07.000 align:start\natravessa\n\n",
+		header + "00:00:05.000 --> 00:00:07.000 align:start\natravessa\n\n",
+		header,
+	}, toStrings(segments))
+
+	// a cue past the end of the media extends the track
+	_, durations = segmentWebVTT([]vttCue{{Start: 10, End: 14, Payload: "créditos"}}, 12, 6, 0)
+	assert.Equal(t, []float64{6, 6, 2}, durations)
+
+	// nothing to go on: one empty segment, never a zero length one
+	segments, durations = segmentWebVTT(nil, 0, 6, mpegtsStartPTS)
+	assert.Equal(t, []float64{6}, durations)
+	assert.Equal(t, []string{header}, toStrings(segments))
+}
+
+func toStrings(segments [][]byte) []string {
+	out := make([]string, len(segments))
+	for i, s := range segments {
+		out[i] = string(s)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package keystore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+// FileStore keeps one JSON file per episode key under dir; meant for single-node setups.
+type FileStore struct {
+	dir string
+}
+
+type fileKey struct {
+	KID []byte `json:"kid,omitempty"`
+	Key []byte `json:"key"`
+	IV  []byte `json:"iv"`
+}
+
+func NewFileStore(dir string) *FileStore {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Fatal("Erro criando diretório de chaves:", err)
+	}
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) Save(ctx context.Context, epId, keyId string, key models.ContentKey) error {
+	path, err := s.path(epId, keyId)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(fileKey{KID: key.KID, Key: key.Key, IV: key.IV})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	// write then rename so the key server never reads a half-written file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) Get(ctx context.Context, epId, keyId string) (*models.ContentKey, error) {
+	path, err := s.path(epId, keyId)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, models.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored fileKey
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	return &models.ContentKey{KID: stored.KID, Key: stored.Key, IV: stored.IV}, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, epId, keyId string) error {
+	path, err := s.path(epId, keyId)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// keys saved before key ids existed live in <epId>.json, the others in <epId>/<keyId>.json
+func (s *FileStore) path(epId, keyId string) (string, error) {
+	if !validName(epId) {
+		return "", fmt.Errorf("invalid episode id %q", epId)
+	}
+	if keyId == "" {
+		return filepath.Join(s.dir, epId+".json"), nil
+	}
+	if !validName(keyId) {
+		return "", fmt.Errorf("invalid key id %q", keyId)
+	}
+	return filepath.Join(s.dir, epId, keyId+".json"), nil
+}
+
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, ".")
+}
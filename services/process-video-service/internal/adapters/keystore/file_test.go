@@ -0,0 +1,80 @@
+package keystore_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"process-video-service/internal/adapters/keystore"
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := keystore.NewFileStore(dir)
+	ctx := context.Background()
+
+	_, err := store.Get(ctx, "ep123", "k1")
+	assert.ErrorIs(t, err, models.ErrKeyNotFound)
+
+	key := models.ContentKey{KID: []byte("0123456789abcdef"), Key: []byte("fedcba9876543210"), IV: []byte("aaaaaaaaaaaaaaaa")}
+	require.NoError(t, store.Save(ctx, "ep123", "k1", key))
+
+	got, err := store.Get(ctx, "ep123", "k1")
+	require.NoError(t, err)
+	assert.Equal(t, key, *got)
+
+	// a second key for the episode leaves the first one alone and no temporary file behind
+	rotated := models.ContentKey{Key: []byte("1111111111111111"), IV: []byte("2222222222222222")}
+	require.NoError(t, store.Save(ctx, "ep123", "k2", rotated))
+	got, err = store.Get(ctx, "ep123", "k2")
+	require.NoError(t, err)
+	assert.Equal(t, rotated, *got)
+	got, err = store.Get(ctx, "ep123", "k1")
+	require.NoError(t, err)
+	assert.Equal(t, key, *got)
+
+	entries, err := os.ReadDir(filepath.Join(dir, "ep123"))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	info, err := entries[0].Info()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	require.NoError(t, store.Delete(ctx, "ep123", "k1"))
+	require.NoError(t, store.Delete(ctx, "ep123", "k1"))
+	_, err = store.Get(ctx, "ep123", "k1")
+	assert.ErrorIs(t, err, models.ErrKeyNotFound)
+	_, err = store.Get(ctx, "ep123", "k2")
+	assert.NoError(t, err)
+}
+
+func TestFileStore_ReadsKeysSavedWithoutId(t *testing.T) {
+	dir := t.TempDir()
+	store := keystore.NewFileStore(dir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ep123.json"), []byte(`{"key":"MTExMTExMTExMTExMTExMQ==","iv":"MjIyMjIyMjIyMjIyMjIyMg=="}`), 0600))
+
+	got, err := store.Get(context.Background(), "ep123", "")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1111111111111111"), got.Key)
+	assert.Equal(t, []byte("2222222222222222"), got.IV)
+}
+
+func TestFileStore_RejectsPathsOutsideDir(t *testing.T) {
+	dir := t.TempDir()
+	store := keystore.NewFileStore(filepath.Join(dir, "keys"))
+
+	for _, epId := range []string{"", "../ep123", "a/b", `a\b`, ".hidden"} {
+		assert.Error(t, store.Save(context.Background(), epId, "k1", models.ContentKey{}), epId)
+		_, err := store.Get(context.Background(), epId, "k1")
+		assert.Error(t, err, epId)
+	}
+	for _, keyId := range []string{"../k1", "a/b", `a\b`, ".hidden"} {
+		assert.Error(t, store.Save(context.Background(), "ep123", keyId, models.ContentKey{}), keyId)
+		assert.Error(t, store.Delete(context.Background(), "ep123", keyId), keyId)
+	}
+}
@@ -0,0 +1,62 @@
+package keystore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"process-video-service/internal/models"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgresStore keeps keys in the content_keys table (see migrations/).
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(databaseURL string) *PostgresStore {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		log.Fatal("Erro conectando ao banco de chaves:", err)
+	}
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Close() {
+	s.db.Close()
+}
+
+func (s *PostgresStore) Save(ctx context.Context, epId, keyId string, key models.ContentKey) error {
+	query := `
+		INSERT INTO content_keys (episode_id, key_id, kid, key, iv)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (episode_id, key_id) DO UPDATE
+		SET kid = EXCLUDED.kid, key = EXCLUDED.key, iv = EXCLUDED.iv, updated_at = NOW();
+	`
+	_, err := s.db.ExecContext(ctx, query, epId, keyId, key.KID, key.Key, key.IV)
+	return err
+}
+
+func (s *PostgresStore) Get(ctx context.Context, epId, keyId string) (*models.ContentKey, error) {
+	var key models.ContentKey
+	query := `SELECT kid, key, iv FROM content_keys WHERE episode_id = $1 AND key_id = $2;`
+	err := s.db.QueryRowContext(ctx, query, epId, keyId).Scan(&key.KID, &key.Key, &key.IV)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, epId, keyId string) error {
+	query := `DELETE FROM content_keys WHERE episode_id = $1 AND key_id = $2;`
+	_, err := s.db.ExecContext(ctx, query, epId, keyId)
+	return err
+}
@@ -31,6 +31,15 @@ func (r *RabbitMQ) Close() {
 }
 
 func (r *RabbitMQ) Consume(queue string, handler func(event models.UploadEvent, ack func(), nack func(requeue bool))) {
+	consume(r, queue, handler)
+}
+
+func (r *RabbitMQ) ConsumeClips(queue string, handler func(event models.ClipRequestEvent, ack func(), nack func(requeue bool))) {
+	consume(r, queue, handler)
+}
+
+// consume drops messages that don't decode as T.
+func consume[T any](r *RabbitMQ, queue string, handler func(event T, ack func(), nack func(requeue bool))) {
 	_, err := r.channel.QueueDeclare(
 		queue,
 		true,
@@ -58,7 +67,7 @@ func (r *RabbitMQ) Consume(queue string, handler func(event models.UploadEvent,
 
 	go func() {
 		for d := range msgs {
-			var event models.UploadEvent
+			var event T
 			if err := json.Unmarshal(d.Body, &event); err != nil {
 				_ = d.Nack(false, false)
 				continue
@@ -3,14 +3,19 @@ package s3
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime"
+	"net/http"
 	"path/filepath"
 	"strings"
 
+	"process-video-service/internal/models"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -79,6 +84,12 @@ func guessContentType(key string) *string {
 			mt = "application/vnd.apple.mpegurl"
 		case ".ts":
 			mt = "video/MP2T"
+		case ".vtt":
+			mt = "text/vtt"
+		case ".m4s":
+			mt = "video/iso.segment"
+		case ".mpd":
+			mt = "application/dash+xml"
 		default:
 			mt = "application/octet-stream"
 		}
@@ -101,16 +112,100 @@ func (s *S3Client) UploadFileReader(bucket, key string, body io.Reader) error {
 	return err
 }
 
-func (s *S3Client) UploadStream(bucket, key string, body io.Reader) error {
+func (s *S3Client) CreateObject(bucket, key string, body io.Reader) error {
+	buf := &bytes.Buffer{}
+	if _, err := io.Copy(buf, body); err != nil {
+		return fmt.Errorf("erro ao ler o reader: %w", err)
+	}
+
 	_, err := s.client.PutObject(context.Background(), &s3.PutObjectInput{
 		Bucket:      &bucket,
 		Key:         &key,
-		Body:        body,
+		Body:        bytes.NewReader(buf.Bytes()),
 		ContentType: guessContentType(key),
+		IfNoneMatch: aws.String("*"),
 	})
+	// 409 is a concurrent conditional write to the same key that is still in flight
+	var resp *awshttp.ResponseError
+	if errors.As(err, &resp) && (resp.HTTPStatusCode() == http.StatusPreconditionFailed || resp.HTTPStatusCode() == http.StatusConflict) {
+		return models.ErrObjectExists
+	}
 	return err
 }
 
+// S3 needs at least 5MiB for every part but the last
+const streamPartSize = 16 << 20
+
+// UploadStream sends body as a multipart upload, one part at a time, so large files
+// are never held in memory whole. Bodies smaller than a part go out as a plain put.
+func (s *S3Client) UploadStream(bucket, key string, body io.Reader) error {
+	ctx := context.Background()
+	buf := make([]byte, streamPartSize)
+
+	n, err := io.ReadFull(body, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      &bucket,
+			Key:         &key,
+			Body:        bytes.NewReader(buf[:n]),
+			ContentType: guessContentType(key),
+		})
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao ler o reader: %w", err)
+	}
+
+	upload, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      &bucket,
+		Key:         &key,
+		ContentType: guessContentType(key),
+	})
+	if err != nil {
+		return err
+	}
+	abort := func(err error) error {
+		_, _ = s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   &bucket,
+			Key:      &key,
+			UploadId: upload.UploadId,
+		})
+		return err
+	}
+
+	var parts []s3types.CompletedPart
+	for n > 0 {
+		number := int32(len(parts) + 1)
+		part, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     &bucket,
+			Key:        &key,
+			UploadId:   upload.UploadId,
+			PartNumber: &number,
+			Body:       bytes.NewReader(buf[:n]),
+		})
+		if err != nil {
+			return abort(err)
+		}
+		parts = append(parts, s3types.CompletedPart{ETag: part.ETag, PartNumber: &number})
+
+		n, err = io.ReadFull(body, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return abort(fmt.Errorf("erro ao ler o reader: %w", err))
+		}
+	}
+
+	_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          &bucket,
+		Key:             &key,
+		UploadId:        upload.UploadId,
+		MultipartUpload: &s3types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return abort(err)
+	}
+	return nil
+}
+
 func (c *S3Client) DeleteObject(bucket, key string) error {
 	_, err := c.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
 		Bucket: &bucket,
@@ -151,6 +246,29 @@ func (c *S3Client) DeletePrefix(bucket, prefix string) error {
 	return nil
 }
 
+func (c *S3Client) ListObjects(bucket, prefix string) ([]models.ObjectInfo, error) {
+	paginator := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket: &bucket,
+		Prefix: &prefix,
+	})
+
+	var objects []models.ObjectInfo
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, models.ObjectInfo{
+				Key:  aws.ToString(obj.Key),
+				Size: aws.ToInt64(obj.Size),
+				ETag: aws.ToString(obj.ETag),
+			})
+		}
+	}
+	return objects, nil
+}
+
 func (c *S3Client) EnsureBucketExists(bucket string) error {
 	_, err := c.client.CreateBucket(context.Background(), &s3.CreateBucketInput{
 		Bucket: &bucket,
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"context"
+
+	helpers "process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+)
+
+type chapterOptions struct {
+	enabled bool
+	models.ChapterOptions
+}
+
+// BuildChapters keeps the chapters embedded in the source and, when there are none,
+// derives them from scene cuts merged to the configured minimum length.
+func (p *Processor) BuildChapters(ctx context.Context, job *models.Job) (*models.ChapterAssets, error) {
+	chapters, source := job.Info.Chapters, models.ChapterSourceEmbedded
+	if len(chapters) == 0 {
+		cuts, err := p.video.DetectScenes(ctx, job, p.chapters.SceneThreshold)
+		if err != nil {
+			return nil, err
+		}
+		chapters, source = helpers.ChaptersFromCuts(cuts, job.Info.Duration, p.chapters.MinSeconds), models.ChapterSourceScenes
+	}
+	if len(chapters) == 0 {
+		return nil, nil
+	}
+
+	assets, err := p.video.GenerateChapters(ctx, job, chapters, p.chapters.ChapterOptions)
+	if err != nil {
+		return nil, err
+	}
+	assets.Source = source
+	return assets, nil
+}
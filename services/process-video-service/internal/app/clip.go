@@ -0,0 +1,216 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	helpers "process-video-service/internal/helpers"
+	"process-video-service/internal/hls"
+	"process-video-service/internal/models"
+)
+
+func clipSourceKey(epId string) string {
+	return fmt.Sprintf("clips/%s.mkv", epId)
+}
+
+func clipClaimKey(epId string) string {
+	return fmt.Sprintf("clips/%s.claim", epId)
+}
+
+// ProcessClip cuts the requested range out of the source episode's published renditions
+// and packages the cut like an upload. In reencode mode the ladder is encoded again from
+// the tallest SDR rendition: renditions don't share keyframes, so their segments can't
+// be cut at the same point. Copy mode only publishes that rendition, cut at its own
+// segment boundaries. Subtitles, markers and chapters aren't carried over.
+func (p *Processor) ProcessClip(event models.ClipRequestEvent) (*models.UploadSuccessEvent, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	event, err := helpers.ResolveClip(event)
+	if err != nil {
+		return nil, fmt.Errorf("corte inválido: %w", err)
+	}
+
+	// a clip only ever creates an episode, so a failure has nothing published to lose
+	manifestKey := fmt.Sprintf("videos/%s/manifest.json", event.EpId)
+	existing, err := p.bucket.ListObjects(p.processBucketName, manifestKey)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao verificar destino: %w", err)
+	}
+	for _, obj := range existing {
+		if obj.Key == manifestKey {
+			return nil, fmt.Errorf("episódio %s já existe", event.EpId)
+		}
+	}
+	// two requests for the same episode both pass the check above, only one gets the claim;
+	// a worker that dies mid-job leaves it behind and the episode can't be clipped until
+	// it is deleted
+	claimKey := clipClaimKey(event.EpId)
+	if err := p.bucket.CreateObject(p.processBucketName, claimKey, strings.NewReader(event.SourceEpId)); err != nil {
+		if errors.Is(err, models.ErrObjectExists) {
+			return nil, fmt.Errorf("episódio %s já está sendo criado", event.EpId)
+		}
+		return nil, fmt.Errorf("erro ao reservar destino: %w", err)
+	}
+	defer p.bucket.DeleteObject(p.processBucketName, claimKey)
+
+	source, err := p.loadManifest(event.SourceEpId)
+	if err != nil {
+		return nil, err
+	}
+	// segments would have to be decrypted to be cut
+	if source.Packaging.Encryption != "" && source.Packaging.Encryption != models.EncryptionNone {
+		return nil, fmt.Errorf("episódio %s é criptografado e não pode ser cortado", event.SourceEpId)
+	}
+	if event.In >= source.Duration {
+		return nil, fmt.Errorf("corte começa em %g, depois do fim do episódio (%g)", event.In, source.Duration)
+	}
+	out := source.Duration
+	if event.Out > 0 {
+		out = min(event.Out, out)
+	}
+
+	plan, err := p.PlanClip(source, event.In, out, event.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := models.UploadEvent{
+		Bucket: p.processBucketName,
+		Key:    clipSourceKey(event.EpId),
+		EpId:   event.EpId,
+		Packaging: &models.PackagingOverride{
+			SegmentType: source.Packaging.SegmentType,
+			Dash:        &source.Packaging.Dash,
+			Encryption:  source.Packaging.Encryption,
+		},
+	}
+
+	successEvent, err := p.packageClip(ctx, plan, upload, event.SourceEpId)
+	if err != nil {
+		_ = p.bucket.DeleteObject(upload.Bucket, upload.Key)
+		_ = p.bucket.DeletePrefix(p.processBucketName, fmt.Sprintf("videos/%s/", event.EpId))
+		return nil, err
+	}
+	return successEvent, nil
+}
+
+func (p *Processor) packageClip(ctx context.Context, plan models.ClipPlan, upload models.UploadEvent, sourceEpId string) (*models.UploadSuccessEvent, error) {
+	cut, err := p.video.ExtractClip(ctx, plan, upload.Bucket, upload.Key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao extrair corte: %w", err)
+	}
+	cut.SourceEpId = sourceEpId
+	upload.Clip = cut
+	return p.ProcessVideo(upload)
+}
+
+// loadManifest reads the manifest.json an episode was published with.
+func (p *Processor) loadManifest(epId string) (*models.UploadSuccessEvent, error) {
+	key := fmt.Sprintf("videos/%s/manifest.json", epId)
+	stream, err := p.bucket.GetObjectStream(p.processBucketName, key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler %s: %w", key, err)
+	}
+	defer stream.Close()
+
+	var manifest models.UploadSuccessEvent
+	if err := json.NewDecoder(stream).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return &manifest, nil
+}
+
+// PlanClip picks what the clip is cut from: the tallest SDR video rendition and, for
+// every source audio track, its surround rendition when there is one so the clip gets
+// both layouts again. Each is limited to the segments covering in to out. Copy mode
+// moves in and out to the video segments' boundaries, where its keyframes are.
+func (p *Processor) PlanClip(source *models.UploadSuccessEvent, in, out float64, mode string) (models.ClipPlan, error) {
+	plan := models.ClipPlan{In: in, Out: out, Mode: mode}
+
+	var video *models.Rendition
+	for i, r := range source.Renditions {
+		if r.VideoRange != "" && r.VideoRange != models.VideoRangeSDR {
+			continue
+		}
+		if video == nil || r.Height > video.Height || r.Height == video.Height && r.Bitrate > video.Bitrate {
+			video = &source.Renditions[i]
+		}
+	}
+	if video == nil {
+		return plan, fmt.Errorf("episódio %s sem rendição de vídeo SDR", source.EpId)
+	}
+	var err error
+	if plan.Video, err = p.clipInput(video.PlaylistKey, in, out); err != nil {
+		return plan, err
+	}
+	if mode == models.ClipModeCopy {
+		in, out = plan.Video.Start, plan.Video.End
+		plan.In, plan.Out = in, out
+	}
+
+	// the stereo rendition carries the source track's name and DEFAULT flag
+	stereo, surround := map[int]models.AudioRendition{}, map[int]models.AudioRendition{}
+	for _, a := range source.AudioTracks {
+		if a.Surround {
+			surround[a.Index] = a
+		} else {
+			stereo[a.Index] = a
+		}
+	}
+	for index, a := range stereo {
+		track := a.AudioTrack
+		if multi, ok := surround[index]; ok {
+			a = multi
+			track.Channels = multi.Channels
+		}
+		input, err := p.clipInput(a.PlaylistKey, in, out)
+		if err != nil {
+			return plan, err
+		}
+		input.Track = track
+		plan.Audio = append(plan.Audio, input)
+	}
+	sort.Slice(plan.Audio, func(i, j int) bool { return plan.Audio[i].Track.Index < plan.Audio[j].Track.Index })
+	return plan, nil
+}
+
+func (p *Processor) clipInput(playlistKey string, in, out float64) (models.ClipInput, error) {
+	var input models.ClipInput
+	playlist, err := p.readMedia(playlistKey)
+	if err != nil {
+		return input, err
+	}
+
+	if playlist.Map != nil {
+		key, err := hls.ResolveURI(playlistKey, playlist.Map.URI)
+		if err != nil {
+			return input, err
+		}
+		input.Keys = append(input.Keys, key)
+	}
+	start, found := 0.0, false
+	for _, s := range playlist.Segments {
+		end := start + s.Duration
+		if end > in && start < out {
+			key, err := hls.ResolveURI(playlistKey, s.URI)
+			if err != nil {
+				return input, err
+			}
+			if !found {
+				input.Start, found = start, true
+			}
+			input.End = end
+			input.Keys = append(input.Keys, key)
+		}
+		start = end
+	}
+	if !found {
+		return input, fmt.Errorf("%s não tem segmentos entre %g e %g", playlistKey, in, out)
+	}
+	return input, nil
+}
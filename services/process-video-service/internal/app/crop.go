@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"process-video-service/internal/models"
+)
+
+type cropOptions struct {
+	enabled bool
+	models.CropOptions
+	// minConfidence is the share of sampled frames that must agree on the rectangle
+	minConfidence float64
+}
+
+// ResolveCrop decides whether the job crops the source. The upload's override wins;
+// otherwise a detected rectangle is applied when enough sampled frames agree on it.
+// It returns nil when cropping is neither requested nor enabled.
+func (p *Processor) ResolveCrop(ctx context.Context, job *models.Job) (*models.CropDetection, error) {
+	info := job.Info
+
+	if override := job.Event.Crop; override != nil {
+		switch {
+		case override.Off:
+			return &models.CropDetection{Source: models.CropSourceOverride, Reason: "disabled for this upload"}, nil
+		case override.Rect != nil:
+			if err := validCrop(*override.Rect, info); err != nil {
+				return nil, err
+			}
+			job.Crop = override.Rect
+			return &models.CropDetection{Rect: *override.Rect, Source: models.CropSourceOverride, Applied: true}, nil
+		}
+	}
+
+	if !p.crop.enabled {
+		return nil, nil
+	}
+
+	detection, err := p.video.DetectCrop(ctx, job, p.crop.CropOptions)
+	if err != nil {
+		return nil, err
+	}
+	full := models.Crop{Width: info.Width, Height: info.Height}
+	invalid := validCrop(detection.Rect, info)
+	switch {
+	case detection.Rect == full:
+		detection.Reason = "no black bars"
+	case detection.Confidence < p.crop.minConfidence:
+		detection.Reason = fmt.Sprintf("confidence %.2f below %.2f", detection.Confidence, p.crop.minConfidence)
+	case invalid != nil:
+		detection.Reason = invalid.Error()
+	default:
+		detection.Applied = true
+		job.Crop = &detection.Rect
+	}
+	return detection, nil
+}
+
+// validCrop checks the rectangle fits the source and can be encoded as 4:2:0.
+func validCrop(c models.Crop, info *models.MediaInfo) error {
+	if c.Width <= 0 || c.Height <= 0 || c.X < 0 || c.Y < 0 {
+		return fmt.Errorf("crop inválido: %dx%d+%d+%d", c.Width, c.Height, c.X, c.Y)
+	}
+	if c.Width%2 != 0 || c.Height%2 != 0 {
+		return fmt.Errorf("crop inválido: dimensões ímpares %dx%d", c.Width, c.Height)
+	}
+	if info.Width > 0 && info.Height > 0 && (c.X+c.Width > info.Width || c.Y+c.Height > info.Height) {
+		return fmt.Errorf("crop inválido: %dx%d+%d+%d fora de %dx%d", c.Width, c.Height, c.X, c.Y, info.Width, info.Height)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"golang.org/x/sync/errgroup"
+
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+)
+
+// BuildDownloads remuxes the published renditions of the download rungs, with the
+// default stereo audio, into single MP4s. When there are none the reason is returned
+// instead: an encrypted episode gets no clear MP4 of what its package protects.
+func (p *Processor) BuildDownloads(ctx context.Context, job *models.Job, renditions []models.Rendition, audio []models.AudioRendition) ([]models.Download, string, error) {
+	if len(p.downloadHeights) == 0 {
+		return nil, "", nil
+	}
+	if job.Encryption != nil {
+		return nil, models.DownloadsOmittedEncrypted, nil
+	}
+
+	var audioKeys []string
+	if track, ok := downloadAudio(audio); ok {
+		input, err := p.clipInput(track.PlaylistKey, 0, math.Inf(1))
+		if err != nil {
+			return nil, "", err
+		}
+		audioKeys = input.Keys
+	}
+
+	var plans []models.DownloadPlan
+	for i, rung := range job.Ladder {
+		if !helpers.IsDownloadRung(rung, p.downloadHeights) {
+			continue
+		}
+		input, err := p.clipInput(renditions[i].PlaylistKey, 0, math.Inf(1))
+		if err != nil {
+			return nil, "", err
+		}
+		plans = append(plans, models.DownloadPlan{Name: helpers.RenditionName(rung), VideoKeys: input.Keys, AudioKeys: audioKeys})
+	}
+	if len(plans) == 0 {
+		return nil, models.DownloadsOmittedNoRung, nil
+	}
+
+	group, ctx := errgroup.WithContext(ctx)
+	downloads := make([]models.Download, len(plans))
+	for i, plan := range plans {
+		group.Go(func() error {
+			download, err := p.video.ProcessDownload(ctx, job, plan)
+			if err != nil {
+				return fmt.Errorf("falha download %s: %w", plan.Name, err)
+			}
+			downloads[i] = *download
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, "", err
+	}
+	return downloads, "", nil
+}
+
+// downloadAudio is the stereo rendition of the default track, or of the first one.
+func downloadAudio(audio []models.AudioRendition) (models.AudioRendition, bool) {
+	var found *models.AudioRendition
+	for i, a := range audio {
+		if a.Surround {
+			continue
+		}
+		if found == nil || a.Default && !found.Default {
+			found = &audio[i]
+		}
+	}
+	if found == nil {
+		return models.AudioRendition{}, false
+	}
+	return *found, true
+}
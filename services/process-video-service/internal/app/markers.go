@@ -0,0 +1,212 @@
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+
+	helpers "process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+)
+
+const (
+	// a credits frame is mostly dark...
+	creditsMinDark = 0.7
+	// ...with enough edges to be text rather than a fade
+	creditsMinEdges = 2.0
+	// longest gap inside a credits run, and between the run and its fade to black
+	creditsMaxGap = 5.0
+	// shortest recap clip and shortest recap; the clips must cover half of it
+	recapMinClip    = 3.0
+	recapMinSeconds = 10.0
+)
+
+type markerOptions struct {
+	enabled bool
+	models.MarkerOptions
+	minIntro   float64
+	maxIntro   float64
+	minCredits float64
+}
+
+// DetectMarkers looks for the intro, by matching the start of the episode against the
+// other episodes of its season, for the recap before it and for the start of the end
+// credits. Episodes without a season only get the credits marker. A failed search
+// doesn't stop the others: the markers found are returned along with the errors.
+func (p *Processor) DetectMarkers(ctx context.Context, job *models.Job) (*models.Markers, error) {
+	markers := &models.Markers{}
+	var errs []error
+
+	if job.Event.SeasonId != "" && len(job.Info.AudioTracks) > 0 {
+		if err := p.findIntro(ctx, job, markers); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	analysis, err := p.video.AnalyzeCredits(ctx, job, p.markers.CreditsSeconds)
+	if err != nil {
+		errs = append(errs, err)
+	} else if start, ok := creditsStart(analysis, p.markers.minCredits); ok {
+		markers.CreditsStart = &start
+	}
+	return markers, errors.Join(errs...)
+}
+
+// findIntro fingerprints the whole episode, not just its start, so later recaps can be found in it.
+func (p *Processor) findIntro(ctx context.Context, job *models.Job, markers *models.Markers) error {
+	event := job.Event
+	fingerprint, err := p.video.FingerprintAudio(ctx, job, max(job.Info.Duration, p.markers.IntroSeconds))
+	if err != nil || fingerprint == nil {
+		return err
+	}
+	introPoints := int(p.markers.IntroSeconds / fingerprint.Interval)
+
+	prefix := fmt.Sprintf("fingerprints/%s/", event.SeasonId)
+	objects, err := p.bucket.ListObjects(p.processBucketName, prefix)
+	if err != nil {
+		return fmt.Errorf("erro ao listar fingerprints: %w", err)
+	}
+
+	var others []*models.AudioFingerprint
+	var best helpers.IntroMatch
+	found := false
+	for _, obj := range objects {
+		if path.Base(obj.Key) == event.EpId+".json" {
+			continue
+		}
+		other, err := p.loadFingerprint(obj.Key)
+		if err != nil {
+			// one unreadable fingerprint shouldn't cost the episode its markers
+			p.logger.Warnf("Fingerprint ignored: key=%s: %v", obj.Key, err)
+			continue
+		}
+		others = append(others, other)
+		match, ok := helpers.MatchIntro(head(fingerprint.Points, introPoints), head(other.Points, introPoints),
+			fingerprint.Interval, p.markers.minIntro, p.markers.maxIntro)
+		if ok && (!found || match.End-match.Start > best.End-best.Start) {
+			best, found = match, true
+			markers.IntroMatchedEpId = other.EpId
+		}
+	}
+	if found {
+		markers.IntroStart, markers.IntroEnd = &best.Start, &best.End
+		if start, end, ok := findRecap(fingerprint, others, best.Start); ok {
+			markers.RecapStart, markers.RecapEnd = &start, &end
+		}
+		p.backfillIntro(markers.IntroMatchedEpId, event.EpId, best)
+	}
+
+	body, err := json.Marshal(fingerprint)
+	if err != nil {
+		return err
+	}
+	if err := p.bucket.UploadFileReader(p.processBucketName, prefix+event.EpId+".json", bytes.NewReader(body)); err != nil {
+		return fmt.Errorf("erro ao salvar fingerprint: %w", err)
+	}
+	return nil
+}
+
+func findRecap(fingerprint *models.AudioFingerprint, others []*models.AudioFingerprint, introStart float64) (float64, float64, bool) {
+	before := head(fingerprint.Points, int(introStart/fingerprint.Interval))
+
+	var clips []helpers.IntroMatch
+	for _, other := range others {
+		clips = append(clips, helpers.MatchClips(before, other.Points, fingerprint.Interval, recapMinClip)...)
+	}
+	if len(clips) == 0 {
+		return 0, 0, false
+	}
+	slices.SortFunc(clips, func(a, b helpers.IntroMatch) int { return cmp.Compare(a.Start, b.Start) })
+
+	start, end, covered := clips[0].Start, clips[0].Start, 0.0
+	for _, clip := range clips {
+		covered += max(clip.End-max(clip.Start, end), 0)
+		end = max(end, clip.End)
+	}
+	if covered < recapMinSeconds || covered < (end-start)/2 {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// backfillIntro announces the intro of the matched episode when its manifest has none, so
+// every later episode that matches it announces it again; failures are only logged.
+func (p *Processor) backfillIntro(otherEpId, epId string, match helpers.IntroMatch) {
+	if p.markersUpdatedQueueName == "" {
+		return
+	}
+	manifest, err := p.loadManifest(otherEpId)
+	if err != nil {
+		// still processing, or not processed with this service
+		p.logger.Warnf("Intro not back-filled: episodeId=%s: %v", otherEpId, err)
+		return
+	}
+	if manifest.Markers != nil && manifest.Markers.IntroStart != nil {
+		return
+	}
+
+	event := models.MarkersUpdatedEvent{
+		EpId:    otherEpId,
+		Markers: &models.Markers{IntroStart: &match.OtherStart, IntroEnd: &match.OtherEnd, IntroMatchedEpId: epId},
+	}
+	if err := p.queue.Publish(p.markersUpdatedQueueName, event); err != nil {
+		p.logger.Warnf("Intro not back-filled: episodeId=%s: %v", otherEpId, err)
+	}
+}
+
+func head(points []uint32, n int) []uint32 {
+	return points[:min(len(points), n)]
+}
+
+func (p *Processor) loadFingerprint(key string) (*models.AudioFingerprint, error) {
+	stream, err := p.bucket.GetObjectStream(p.processBucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var fingerprint models.AudioFingerprint
+	if err := json.NewDecoder(stream).Decode(&fingerprint); err != nil {
+		return nil, err
+	}
+	return &fingerprint, nil
+}
+
+func creditsStart(analysis *models.CreditsAnalysis, minSeconds float64) (float64, bool) {
+	credits, found := 0.0, false
+	start, last := -1.0, -1.0
+	closeRun := func() {
+		if start >= 0 && last-start >= minSeconds {
+			credits, found = start, true
+		}
+	}
+	for _, frame := range analysis.Frames {
+		if frame.Dark < creditsMinDark || frame.Edges < creditsMinEdges {
+			continue
+		}
+		if start >= 0 && frame.Time-last > creditsMaxGap {
+			closeRun()
+			start = -1
+		}
+		if start < 0 {
+			start = frame.Time
+		}
+		last = frame.Time
+	}
+	closeRun()
+	if !found {
+		return 0, false
+	}
+
+	for _, black := range analysis.Black {
+		if black.Start < credits && credits-black.End <= creditsMaxGap {
+			return black.Start, true
+		}
+	}
+	return credits, true
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"testing"
+
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreditsStart(t *testing.T) {
+	frames := func(from, to int, dark, edges float64) []models.CreditsFrame {
+		var f []models.CreditsFrame
+		for sec := from; sec < to; sec++ {
+			f = append(f, models.CreditsFrame{Time: float64(sec), Dark: dark, Edges: edges})
+		}
+		return f
+	}
+	concat := func(parts ...[]models.CreditsFrame) []models.CreditsFrame {
+		var all []models.CreditsFrame
+		for _, p := range parts {
+			all = append(all, p...)
+		}
+		return all
+	}
+
+	tests := []struct {
+		name     string
+		analysis models.CreditsAnalysis
+		want     float64
+		wantOK   bool
+	}{
+		{
+			name:     "credits to the end",
+			analysis: models.CreditsAnalysis{Frames: concat(frames(1000, 1240, 0.2, 9), frames(1240, 1300, 0.9, 4))},
+			want:     1240,
+			wantOK:   true,
+		},
+		{
+			name: "moved back to the fade to black",
+			analysis: models.CreditsAnalysis{
+				Frames: concat(frames(1000, 1240, 0.2, 9), frames(1240, 1300, 0.9, 4)),
+				Black:  []models.QCInterval{{Start: 1234, End: 1237, Duration: 3}},
+			},
+			want:   1234,
+			wantOK: true,
+		},
+		{
+			name: "fade too long before the credits",
+			analysis: models.CreditsAnalysis{
+				Frames: concat(frames(1000, 1240, 0.2, 9), frames(1240, 1300, 0.9, 4)),
+				Black:  []models.QCInterval{{Start: 1200, End: 1210, Duration: 10}},
+			},
+			want:   1240,
+			wantOK: true,
+		},
+		{
+			name: "a short gap doesn't split the run",
+			analysis: models.CreditsAnalysis{Frames: concat(
+				frames(1000, 1240, 0.2, 9), frames(1240, 1255, 0.9, 4), frames(1255, 1258, 0.3, 9), frames(1258, 1270, 0.9, 4),
+			)},
+			want:   1240,
+			wantOK: true,
+		},
+		{
+			name: "last long enough run wins",
+			analysis: models.CreditsAnalysis{Frames: concat(
+				frames(1000, 1030, 0.9, 4), frames(1030, 1200, 0.2, 9), frames(1200, 1205, 0.9, 4), frames(1205, 1250, 0.2, 9),
+			)},
+			want:   1000,
+			wantOK: true,
+		},
+		{
+			name:     "black without text is a fade, not credits",
+			analysis: models.CreditsAnalysis{Frames: concat(frames(1000, 1240, 0.2, 9), frames(1240, 1300, 0.99, 0.1))},
+		},
+		{
+			name:     "run too short",
+			analysis: models.CreditsAnalysis{Frames: concat(frames(1000, 1280, 0.2, 9), frames(1280, 1290, 0.9, 4))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := creditsStart(&tt.analysis, 20)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+
+	helpers "process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+)
+
+type previewOptions struct {
+	enabled bool
+	models.PreviewOptions
+}
+
+// BuildPreview renders the hover preview from the operator's timestamp when the upload
+// has one, otherwise from the window of the episode with the most motion.
+func (p *Processor) BuildPreview(ctx context.Context, job *models.Job) (*models.PreviewAssets, error) {
+	duration, seconds := job.Info.Duration, p.preview.Seconds
+	if duration > 0 {
+		seconds = min(seconds, duration)
+	}
+
+	preview := &models.PreviewAssets{Source: models.PreviewSourceOperator, Duration: seconds}
+	if at := job.Event.PreviewAt; at != nil {
+		preview.Start = max(*at, 0)
+		// a timestamp too close to the end still gets a full-length preview
+		if duration > 0 {
+			preview.Start = min(preview.Start, duration-seconds)
+		}
+	} else {
+		samples, err := p.video.MeasureMotion(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		preview.Source = models.PreviewSourceMotion
+		preview.Start = helpers.PreviewWindow(samples, duration, seconds)
+	}
+
+	clips, err := p.video.GeneratePreview(ctx, job, preview.Start, models.PreviewOptions{Seconds: seconds, Widths: p.preview.Widths})
+	if err != nil {
+		return nil, err
+	}
+	preview.Clips = clips
+	return preview, nil
+}
@@ -3,37 +3,170 @@ package app
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 
 	"process-video-service/internal/config"
+	"process-video-service/internal/dash"
 	helpers "process-video-service/internal/helpers"
+	"process-video-service/internal/hls"
 	"process-video-service/internal/interfaces"
 	"process-video-service/internal/models"
 )
 
+const (
+	audioGroupID    = "audio"
+	subtitleGroupID = "subs"
+)
+
 type Processor struct {
 	queue                     interfaces.Queue
 	bucket                    interfaces.Bucket
 	video                     interfaces.VideoProcessor
+	keys                      interfaces.KeyStore
 	uploadQueueName           string
 	processedVideoQueueName   string
 	failProcessVideoQueueName string
+	clipQueueName             string
+	markersUpdatedQueueName   string
 	processBucketName         string
+	thumbnailOptions          models.ThumbnailOptions
+	stillOptions              models.StillOptions
+	packaging                 models.Packaging
+	videoCodecs               []string
+	keyServerURL              string
+	servesKeys                bool
+	iframeHeights             []int
+	downloadHeights           []int
+	hdrLadder                 bool
+	loudness                  *models.LoudnessTarget
+	surroundCodec             string
+	perTitle                  bool
+	complexityOptions         models.ComplexityOptions
+	quality                   qualityOptions
+	qc                        qcOptions
+	crop                      cropOptions
+	markers                   markerOptions
+	chapters                  chapterOptions
+	preview                   previewOptions
+	verify                    verifyOptions
 	logger                    config.Logger
 }
 
-func NewProcessor(cfg *config.Config, queue interfaces.Queue, bucket interfaces.Bucket, video interfaces.VideoProcessor, processBucketName string) *Processor {
-	return &Processor{
+func NewProcessor(cfg *config.Config, queue interfaces.Queue, bucket interfaces.Bucket, video interfaces.VideoProcessor, keys interfaces.KeyStore, processBucketName string) *Processor {
+	p := &Processor{
 		queue:                     queue,
 		bucket:                    bucket,
 		video:                     video,
+		keys:                      keys,
 		uploadQueueName:           cfg.UploadVideoQueue,
 		processedVideoQueueName:   cfg.ProcessedVideoQueue,
 		failProcessVideoQueueName: cfg.FailProcessVideoQueue,
+		clipQueueName:             cfg.ClipQueue,
+		markersUpdatedQueueName:   cfg.MarkersUpdatedQueue,
 		processBucketName:         processBucketName,
-		logger:                    *config.NewLogger("Processor"),
+		thumbnailOptions: models.ThumbnailOptions{
+			Interval: cfg.ThumbnailInterval,
+			Width:    cfg.ThumbnailWidth,
+			Columns:  cfg.ThumbnailColumns,
+			Rows:     cfg.ThumbnailRows,
+			Format:   cfg.ThumbnailFormat,
+		},
+		stillOptions: models.StillOptions{
+			Samples:    cfg.StillSamples,
+			Candidates: cfg.StillCandidates,
+			Widths:     cfg.StillWidths,
+		},
+		packaging: models.Packaging{
+			SegmentType: cfg.HLSSegmentType,
+			Dash:        cfg.EnableDash,
+			Encryption:  cfg.HLSEncryption,
+		},
+		videoCodecs:     cfg.VideoCodecs,
+		keyServerURL:    cfg.KeyServerURL,
+		servesKeys:      cfg.PlaybackTokenSecret != "",
+		iframeHeights:   cfg.IFramePlaylistHeights,
+		downloadHeights: cfg.DownloadHeights,
+		hdrLadder:       cfg.HDRLadder,
+		surroundCodec:   cfg.SurroundAudioCodec,
+		perTitle:        cfg.PerTitleEncoding,
+		complexityOptions: models.ComplexityOptions{
+			Samples:       cfg.PerTitleSamples,
+			SampleSeconds: cfg.PerTitleSampleSeconds,
+			CRF:           cfg.PerTitleCRF,
+		},
+		quality: qualityOptions{
+			enabled:        cfg.QualityMetrics,
+			QualityOptions: models.QualityOptions{VMAF: cfg.QualityVMAF},
+			minPSNR:        cfg.QualityMinPSNR,
+			minSSIM:        cfg.QualityMinSSIM,
+			minVMAF:        cfg.QualityMinVMAF,
+			failBelow:      cfg.QualityFailBelowThreshold,
+		},
+		qc: qcOptions{
+			enabled:            cfg.QCAnalysis,
+			deinterlace:        cfg.QCDeinterlace,
+			interlaceThreshold: cfg.QCInterlaceThreshold,
+			maxBlackRatio:      cfg.QCMaxBlackRatio,
+			maxSilenceRatio:    cfg.QCMaxSilenceRatio,
+		},
+		crop: cropOptions{
+			enabled: cfg.CropDetection,
+			CropOptions: models.CropOptions{
+				Samples:       cfg.CropSamples,
+				SampleSeconds: cfg.CropSampleSeconds,
+			},
+			minConfidence: cfg.CropMinConfidence,
+		},
+		markers: markerOptions{
+			enabled: cfg.Markers,
+			MarkerOptions: models.MarkerOptions{
+				IntroSeconds:   cfg.MarkersIntroSeconds,
+				CreditsSeconds: cfg.MarkersCreditsSeconds,
+			},
+			minIntro:   cfg.MarkersMinIntro,
+			maxIntro:   cfg.MarkersMaxIntro,
+			minCredits: cfg.MarkersMinCredits,
+		},
+		chapters: chapterOptions{
+			enabled: cfg.Chapters,
+			ChapterOptions: models.ChapterOptions{
+				MinSeconds:     cfg.ChapterMinSeconds,
+				SceneThreshold: cfg.ChapterSceneThreshold,
+				ThumbnailWidth: cfg.ChapterThumbnailWidth,
+			},
+		},
+		preview: previewOptions{
+			enabled: cfg.Preview,
+			PreviewOptions: models.PreviewOptions{
+				Seconds: cfg.PreviewSeconds,
+				Widths:  cfg.PreviewWidths,
+			},
+		},
+		verify: verifyOptions{
+			enabled:           cfg.VerifyOutputs,
+			durationTolerance: cfg.VerifyDurationTolerance,
+			sampleSegments:    cfg.VerifySampleSegments,
+		},
+		logger: *config.NewLogger("Processor"),
 	}
+	if cfg.Loudnorm {
+		p.loudness = &models.LoudnessTarget{
+			Integrated: cfg.LoudnormIntegrated,
+			TruePeak:   cfg.LoudnormTruePeak,
+			Range:      cfg.LoudnormRange,
+		}
+	}
+	return p
 }
 
 func (p *Processor) Listen(ctx context.Context) {
@@ -41,12 +174,17 @@ func (p *Processor) Listen(ctx context.Context) {
 
 		p.logger.Infof("Event recived: key=%s episodeId=%s bucket=%s", event.Key, event.EpId, event.Bucket)
 
-		if err := p.ProcessVideo(event); err != nil {
+		sucessEvent, err := p.ProcessVideo(event)
+		if err != nil {
 			p.logger.Error("Erro on process", err)
 
 			p.logger.Info("Cleannig: ", event.Key)
 			_ = p.bucket.DeletePrefix(p.processBucketName, fmt.Sprintf("videos/%s/", event.EpId))
-			_ = p.bucket.DeleteObject(event.Bucket, event.Key)
+			// outputs that failed verification or scored too low say nothing about the upload, keep it for a retry
+			if !errors.Is(err, ErrVerificationFailed) && !errors.Is(err, ErrQualityBelowThreshold) {
+				_ = p.bucket.DeleteObject(event.Bucket, event.Key)
+				_ = p.bucket.DeletePrefix(event.Bucket, event.Key+".")
+			}
 
 			failEvent := models.UploadFailedEvent{
 				Key:    event.Key,
@@ -61,12 +199,7 @@ func (p *Processor) Listen(ctx context.Context) {
 			return
 		}
 
-		sucessEvent := models.UploadSuccessEvent{
-			Key:    event.Key,
-			EpId:   event.EpId,
-			Bucket: p.processBucketName,
-		}
-		err := p.queue.Publish(p.processedVideoQueueName, sucessEvent)
+		err = p.queue.Publish(p.processedVideoQueueName, sucessEvent)
 
 		if err != nil {
 			nack(true)
@@ -77,81 +210,628 @@ func (p *Processor) Listen(ctx context.Context) {
 		p.logger.Info("Processed video:", event.Key)
 	})
 
+	// clip jobs are only taken when a queue is configured for them
+	if p.clipQueueName != "" {
+		p.queue.ConsumeClips(p.clipQueueName, func(event models.ClipRequestEvent, ack func(), nack func(requeue bool)) {
+			p.logger.Infof("Clip requested: source=%s episodeId=%s in=%g out=%g mode=%s", event.SourceEpId, event.EpId, event.In, event.Out, event.Mode)
+
+			// ProcessClip cleans up what it created
+			sucessEvent, err := p.ProcessClip(event)
+			if err != nil {
+				p.logger.Error("Erro on clip", err)
+
+				p.queue.Publish(p.failProcessVideoQueueName, models.UploadFailedEvent{
+					Key:    clipSourceKey(event.EpId),
+					EpId:   event.EpId,
+					Bucket: p.processBucketName,
+					Reason: err.Error(),
+				})
+				nack(false)
+				return
+			}
+
+			if err := p.queue.Publish(p.processedVideoQueueName, sucessEvent); err != nil {
+				nack(true)
+				return
+			}
+
+			ack()
+			p.logger.Info("Processed clip:", event.EpId)
+		})
+	}
+
 	p.logger.Info("app listening queues")
 
 	<-ctx.Done()
 }
 
-func (p *Processor) ProcessVideo(event models.UploadEvent) error {
+func (p *Processor) ProcessVideo(event models.UploadEvent) (*models.UploadSuccessEvent, error) {
+	startedAt := time.Now()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	originalHeight, err := p.video.GetHeight(ctx, event.Bucket, event.Key)
+	packaging, err := helpers.ResolvePackaging(p.packaging, event.Packaging)
+	if err == nil {
+		err = helpers.ValidateCodecs(p.videoCodecs, packaging)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("empacotamento inválido: %w", err)
+	}
+	// without the key handler nobody could play the encrypted package
+	if packaging.Encryption != "" && packaging.Encryption != models.EncryptionNone && !p.servesKeys {
+		return nil, fmt.Errorf("empacotamento inválido: criptografia %s exige PLAYBACK_TOKEN_SECRET", packaging.Encryption)
+	}
+
+	info, err := p.video.Probe(ctx, event.Bucket, event.Key)
 	if err != nil {
-		return fmt.Errorf("erro ao detectar resolução original: %w", err)
+		return nil, fmt.Errorf("erro ao detectar resolução original: %w", err)
+	}
+
+	// a copied clip keeps the one rendition it was cut from; nothing is decoded, so
+	// there's nothing to check, crop or pick bitrates for either
+	copyClip := event.Clip != nil && event.Clip.Mode == models.ClipModeCopy
+
+	ladder := helpers.Ladder(info.Height, p.videoCodecs)
+	if copyClip {
+		ladder = []models.Rung{{Height: info.Height, Codec: info.VideoCodec, Copy: true}}
+	}
+	// HEVC only goes out as CMAF, so an HDR ladder needs fMP4 packaging
+	if !copyClip && p.hdrLadder && info.HDR() && packaging.SegmentType == models.SegmentTypeFMP4 {
+		ladder = append(ladder, helpers.HDRLadder(info.Height)...)
+	}
+	// byte ranges can't be cut out of whole-segment AES-128 (CBC chains across the segment)
+	if packaging.Encryption != models.EncryptionAES128 {
+		for i := range ladder {
+			ladder[i].IFrames = slices.Contains(p.iframeHeights, ladder[i].Height)
+		}
+	}
+
+	sidecarObjects, err := p.bucket.ListObjects(event.Bucket, event.Key+".")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar legendas: %w", err)
+	}
+	sidecars := helpers.SidecarSubtitles(event.Key, sidecarObjects, len(info.SubtitleTracks))
+	subtitleTracks := append(info.SubtitleTracks, sidecars...)
+
+	job := &models.Job{
+		Event:         event,
+		Info:          info,
+		Packaging:     packaging,
+		Ladder:        ladder,
+		Loudness:      p.loudness,
+		SurroundCodec: p.surroundCodec,
+	}
+
+	var qc *models.QCReport
+	if p.qc.enabled && !copyClip {
+		qc, err = p.CheckSource(ctx, job)
+		if err != nil {
+			return nil, fmt.Errorf("erro no QC da fonte: %w", err)
+		}
+	}
+
+	var crop *models.CropDetection
+	if !copyClip {
+		crop, err = p.ResolveCrop(ctx, job)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao detectar bordas: %w", err)
+		}
+	}
+
+	var overlay *models.Overlay
+	if event.Overlay != nil {
+		resolved, err := helpers.ResolveOverlay(*event.Overlay, info.Duration)
+		if err == nil {
+			job.Overlay, err = p.video.PrepareOverlay(ctx, job, resolved)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("overlay inválido: %w", err)
+		}
+		overlay = &resolved
+	}
+
+	decision := helpers.StaticLadderDecision(ladder)
+	if p.perTitle && !copyClip {
+		analysis, err := p.video.AnalyzeComplexity(ctx, job, p.complexityOptions)
+		if err != nil {
+			return nil, fmt.Errorf("erro na análise de complexidade: %w", err)
+		}
+		ladder, decision = helpers.PerTitleLadder(ladder, analysis)
+		job.Ladder = ladder
+	}
+
+	published := false
+	if packaging.Encryption != "" && packaging.Encryption != models.EncryptionNone {
+		job.Encryption, err = p.createContentKey(ctx, event.EpId, packaging.Encryption)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao gerar chave: %w", err)
+		}
+		// the live playlists keep pointing at the previous key until this job's playlists replace them
+		defer func() {
+			if !published {
+				_ = p.keys.Delete(context.Background(), event.EpId, job.Encryption.KeyID)
+			}
+		}()
 	}
 
-	resolutions := helpers.FilterResolutions(originalHeight)
+	group, groupCtx := errgroup.WithContext(ctx)
 
-	errCh := make(chan error, len(resolutions))
-	var wg sync.WaitGroup
-	wg.Add(len(resolutions))
+	renditions := make([]models.Rendition, len(ladder))
+	for i, rung := range ladder {
+		group.Go(func() error {
+			rendition, err := p.video.Process(groupCtx, job, rung)
+			if err != nil {
+				return fmt.Errorf("falha %s: %w", helpers.RenditionName(rung), err)
+			}
+			if rendition.Height == 0 {
+				rendition.Height = rung.Height
+			}
+			renditions[i] = *rendition
+			return nil
+		})
+	}
 
-	for _, res := range resolutions {
-		go func(res int) {
-			defer wg.Done()
-			if err := p.video.Process(ctx, event, res); err != nil {
-				errCh <- fmt.Errorf("falha %dp: %w", res, err)
-				cancel()
+	audioTracks := helpers.AudioOutputs(info.AudioTracks, p.surroundCodec != models.SurroundCodecNone)
+	audio := make([]models.AudioRendition, len(audioTracks))
+	for i, track := range audioTracks {
+		group.Go(func() error {
+			rendition, err := p.video.ProcessAudio(groupCtx, job, track)
+			if err != nil {
+				return fmt.Errorf("falha áudio %d: %w", track.Index, err)
 			}
-		}(res)
+			audio[i] = *rendition
+			return nil
+		})
 	}
 
-	go func() {
-		wg.Wait()
-		close(errCh)
-	}()
+	subtitles := make([]models.SubtitleRendition, len(subtitleTracks))
+	for i, track := range subtitleTracks {
+		group.Go(func() error {
+			rendition, err := p.video.ProcessSubtitle(groupCtx, job, track)
+			if err != nil {
+				return fmt.Errorf("falha legenda %d: %w", track.Index, err)
+			}
+			subtitles[i] = *rendition
+			return nil
+		})
+	}
 
-	var finalErr error
-	for err := range errCh {
-		if finalErr == nil {
-			finalErr = err
+	var thumbnails *models.ThumbnailAssets
+	group.Go(func() error {
+		assets, err := p.video.GenerateThumbnails(groupCtx, job, p.thumbnailOptions)
+		if err != nil {
+			return fmt.Errorf("falha thumbnails: %w", err)
+		}
+		thumbnails = assets
+		return nil
+	})
+
+	var stills []models.Still
+	group.Go(func() error {
+		candidates, err := p.video.GenerateStills(groupCtx, job, p.stillOptions)
+		if err != nil {
+			return fmt.Errorf("falha stills: %w", err)
 		}
+		stills = candidates
+		return nil
+	})
+
+	var markers *models.Markers
+	if p.markers.enabled {
+		group.Go(func() error {
+			// markers are optional, the episode is published with the ones that were found
+			found, err := p.DetectMarkers(groupCtx, job)
+			if err != nil {
+				p.logger.Warnf("Markers incomplete: episodeId=%s: %v", event.EpId, err)
+			}
+			markers = found
+			return nil
+		})
 	}
 
-	if finalErr != nil {
-		return finalErr
+	var chapters *models.ChapterAssets
+	if p.chapters.enabled {
+		group.Go(func() error {
+			assets, err := p.BuildChapters(groupCtx, job)
+			if err != nil {
+				return fmt.Errorf("falha capítulos: %w", err)
+			}
+			chapters = assets
+			return nil
+		})
 	}
 
-	if err := p.UploadMasterPlaylist(event, resolutions); err != nil {
-		return err
+	var preview *models.PreviewAssets
+	if p.preview.enabled {
+		group.Go(func() error {
+			assets, err := p.BuildPreview(groupCtx, job)
+			if err != nil {
+				return fmt.Errorf("falha preview: %w", err)
+			}
+			preview = assets
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	// downloads are remuxed from the published segments, so they wait for them
+	downloads, downloadsOmitted, err := p.BuildDownloads(ctx, job, renditions, audio)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.quality.enabled {
+		if err := p.MeasureQuality(ctx, job, renditions); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.UploadMasterPlaylist(job, renditions, audio, subtitles); err != nil {
+		return nil, err
+	}
+
+	duration := info.Duration
+	for _, r := range renditions {
+		duration = max(duration, r.Duration)
+	}
+
+	dashManifestKey := ""
+	if packaging.Dash {
+		dashManifestKey = fmt.Sprintf("videos/%s/manifest.mpd", event.EpId)
+		if err := p.UploadDashManifest(job, duration, renditions, audio, subtitles); err != nil {
+			return nil, err
+		}
 	}
 
-	return p.bucket.DeleteObject(event.Bucket, event.Key)
+	if p.verify.enabled {
+		if err := p.VerifyOutputs(ctx, job); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+		}
+	}
+
+	successEvent := &models.UploadSuccessEvent{
+		Version:           models.SuccessEventVersion,
+		Key:               event.Key,
+		EpId:              event.EpId,
+		Bucket:            p.processBucketName,
+		MasterPlaylistKey: fmt.Sprintf("videos/%s/master.m3u8", event.EpId),
+		DashManifestKey:   dashManifestKey,
+		ManifestKey:       fmt.Sprintf("videos/%s/manifest.json", event.EpId),
+		Packaging:         packaging,
+		Duration:          duration,
+		Source: models.SourceSummary{
+			Container:       info.Container,
+			VideoCodec:      info.VideoCodec,
+			Width:           info.Width,
+			Height:          info.Height,
+			FrameRate:       info.FrameRate,
+			Bitrate:         info.Bitrate,
+			Duration:        info.Duration,
+			ColorTransfer:   info.ColorTransfer,
+			ColorPrimaries:  info.ColorPrimaries,
+			VideoRange:      info.VideoRange,
+			AudioStreams:    len(info.AudioTracks),
+			SubtitleStreams: len(info.SubtitleTracks),
+		},
+		Renditions:       renditions,
+		AudioTracks:      audio,
+		Subtitles:        subtitles,
+		Thumbnails:       thumbnails,
+		Stills:           stills,
+		Ladder:           &decision,
+		QC:               qc,
+		Crop:             crop,
+		Markers:          markers,
+		Chapters:         chapters,
+		Downloads:        downloads,
+		DownloadsOmitted: downloadsOmitted,
+		Preview:          preview,
+		Overlay:          overlay,
+		Clip:             event.Clip,
+	}
+
+	if err := p.UploadManifest(successEvent, startedAt); err != nil {
+		return nil, err
+	}
+
+	for _, track := range sidecars {
+		if err := p.bucket.DeleteObject(event.Bucket, track.SidecarKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.bucket.DeleteObject(event.Bucket, event.Key); err != nil {
+		return nil, err
+	}
+
+	published = true
+	return successEvent, nil
 }
 
-func (p *Processor) UploadMasterPlaylist(event models.UploadEvent, resolutions []int) error {
-	var master bytes.Buffer
-	master.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+func (p *Processor) createContentKey(ctx context.Context, epId, scheme string) (*models.Encryption, error) {
+	if p.keys == nil || p.keyServerURL == "" {
+		return nil, fmt.Errorf("criptografia não configurada")
+	}
+	key, err := helpers.NewContentKey()
+	if err != nil {
+		return nil, err
+	}
+	keyId := hex.EncodeToString(key.KID)
+	if err := p.keys.Save(ctx, epId, keyId, key); err != nil {
+		return nil, err
+	}
+	keyURI := strings.TrimRight(p.keyServerURL, "/") + "/" + epId + "/" + keyId
+	return &models.Encryption{
+		ContentKey: key,
+		Scheme:     scheme,
+		KeyID:      keyId,
+		KeyURI:     keyURI,
+		LicenseURL: keyURI + "/license",
+	}, nil
+}
 
-	for _, res := range resolutions {
-		bandwidth := 1500000
-		width := 854
-		switch res {
-		case 720:
-			bandwidth = 3000000
-			width = 1280
-		case 1080:
-			bandwidth = 5000000
-			width = 1920
+func (p *Processor) UploadMasterPlaylist(job *models.Job, renditions []models.Rendition, audio []models.AudioRendition, subtitles []models.SubtitleRendition) error {
+	prefix := fmt.Sprintf("videos/%s/", job.Event.EpId)
+
+	master := hls.MasterPlaylist{Version: 3}
+	if job.Packaging.SegmentType == models.SegmentTypeFMP4 {
+		master.Version = 7
+	}
+
+	var audioPeak, audioAverage int
+	// CODECS must list every format a variant's audio group may play
+	var audioCodecs []string
+	for _, track := range audio {
+		media := hls.Media{
+			Type:       hls.MediaTypeAudio,
+			GroupID:    audioGroupID,
+			Name:       track.Name,
+			Language:   track.Language,
+			Default:    track.Default,
+			AutoSelect: true,
+			URI:        strings.TrimPrefix(track.PlaylistKey, prefix),
 		}
-		master.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d\n%d/index.m3u8\n",
-			bandwidth, width, res, res))
+		if track.Channels > 0 {
+			media.Channels = strconv.Itoa(track.Channels)
+		}
+		master.Media = append(master.Media, media)
+
+		audioPeak = max(audioPeak, track.PeakBitrate)
+		audioAverage = max(audioAverage, track.Bitrate)
+		if track.Codecs != "" && !slices.Contains(audioCodecs, track.Codecs) {
+			audioCodecs = append(audioCodecs, track.Codecs)
+		}
+	}
+
+	for _, track := range subtitles {
+		master.Media = append(master.Media, hls.Media{
+			Type:       hls.MediaTypeSubtitles,
+			GroupID:    subtitleGroupID,
+			Name:       track.Name,
+			Language:   track.Language,
+			AutoSelect: true,
+			Forced:     track.Forced,
+			URI:        strings.TrimPrefix(track.PlaylistKey, prefix),
+		})
+	}
+
+	for _, r := range renditions {
+		bandwidth, width := defaultBandwidth(r.Height)
+		if r.PeakBitrate > 0 {
+			bandwidth = r.PeakBitrate + audioPeak
+		}
+		if r.Width > 0 {
+			width = r.Width
+		}
+
+		variant := hls.Variant{
+			Bandwidth:  bandwidth,
+			Width:      width,
+			Height:     r.Height,
+			VideoRange: r.VideoRange,
+			URI:        strings.TrimPrefix(r.PlaylistKey, prefix),
+		}
+		if r.Bitrate > 0 {
+			variant.AverageBandwidth = r.Bitrate + audioAverage
+		}
+		// a CODECS list without the video codec would make players reject the variant
+		if r.Codecs != "" {
+			variant.Codecs = joinCodecs(append([]string{r.Codecs}, audioCodecs...)...)
+		}
+		if len(audio) > 0 {
+			variant.Audio = audioGroupID
+		}
+		if len(subtitles) > 0 {
+			variant.Subtitles = subtitleGroupID
+		}
+		master.Variants = append(master.Variants, variant)
+
+		if r.IFramePlaylistKey != "" {
+			master.IFrameVariants = append(master.IFrameVariants, hls.IFrameVariant{
+				Bandwidth:  r.IFrameBitrate,
+				Width:      width,
+				Height:     r.Height,
+				Codecs:     r.Codecs,
+				VideoRange: r.VideoRange,
+				URI:        strings.TrimPrefix(r.IFramePlaylistKey, prefix),
+			})
+		}
+	}
+
+	if len(master.IFrameVariants) > 0 && master.Version < 4 {
+		master.Version = 4
+	}
+
+	if err := master.Validate(); err != nil {
+		return fmt.Errorf("master playlist inválida: %w", err)
 	}
 
 	return p.bucket.UploadFileReader(
 		p.processBucketName,
-		fmt.Sprintf("videos/%s/master.m3u8", event.EpId),
-		bytes.NewReader(master.Bytes()),
+		prefix+"master.m3u8",
+		bytes.NewReader(master.Encode()),
 	)
 }
+
+// UploadDashManifest writes manifest.mpd next to master.m3u8, pointing at the same
+// CMAF segments the HLS media playlists use.
+func (p *Processor) UploadDashManifest(job *models.Job, duration float64, renditions []models.Rendition, audio []models.AudioRendition, subtitles []models.SubtitleRendition) error {
+	prefix := fmt.Sprintf("videos/%s/", job.Event.EpId)
+	mpd := dash.NewStatic(duration)
+	period := dash.Period{ID: "0", Start: "PT0S"}
+
+	// text tracks stay in the clear; only the CMAF audio/video sets are protected
+	var protection []dash.ContentProtection
+	if job.Encryption != nil && job.Encryption.Scheme == models.EncryptionCENC {
+		mpd.EnableCENC()
+		protection = dash.ClearKeyProtection(job.Encryption.KID, job.Encryption.LicenseURL)
+	}
+
+	// players can't switch codecs or dynamic ranges inside an adaptation set, so each
+	// ladder codec and range gets its own
+	var videoSets []*dash.AdaptationSet
+	setByCodec := map[string]*dash.AdaptationSet{}
+	for _, r := range renditions {
+		setKey := r.VideoCodec + "/" + r.VideoRange
+		set, ok := setByCodec[setKey]
+		if !ok {
+			set = &dash.AdaptationSet{
+				ID:                 len(videoSets),
+				ContentType:        "video",
+				MimeType:           "video/mp4",
+				SegmentAlignment:   true,
+				StartWithSAP:       1,
+				ContentProtections: protection,
+			}
+			set.EssentialProps, set.SupplementalProps = dash.HDRProperties(r.VideoRange)
+			setByCodec[setKey] = set
+			videoSets = append(videoSets, set)
+		}
+		set.Representations = append(set.Representations, dashRepresentation(prefix, path.Dir(strings.TrimPrefix(r.PlaylistKey, prefix)), r))
+	}
+	for _, set := range videoSets {
+		period.AdaptationSets = append(period.AdaptationSets, *set)
+	}
+
+	for _, track := range audio {
+		role := "alternate"
+		if track.Default {
+			role = "main"
+		}
+		// audio0, audio0-surround
+		representation := dashRepresentation(prefix, "audio"+path.Base(path.Dir(track.PlaylistKey)), track.Rendition)
+		representation.AudioChannels = dash.AudioChannelConfiguration(track.Channels)
+		period.AdaptationSets = append(period.AdaptationSets, dash.AdaptationSet{
+			ID:                 len(period.AdaptationSets),
+			ContentType:        "audio",
+			MimeType:           "audio/mp4",
+			Lang:               track.Language,
+			SegmentAlignment:   true,
+			StartWithSAP:       1,
+			ContentProtections: protection,
+			Roles:              []dash.Descriptor{{SchemeIDURI: "urn:mpeg:dash:role:2011", Value: role}},
+			Representations:    []dash.Representation{representation},
+		})
+	}
+
+	for _, track := range subtitles {
+		if track.FileKey == "" {
+			continue
+		}
+		period.AdaptationSets = append(period.AdaptationSets, dash.AdaptationSet{
+			ID:          len(period.AdaptationSets),
+			ContentType: "text",
+			MimeType:    "text/vtt",
+			Lang:        track.Language,
+			Roles:       []dash.Descriptor{{SchemeIDURI: "urn:mpeg:dash:role:2011", Value: "subtitle"}},
+			Representations: []dash.Representation{{
+				ID:        fmt.Sprintf("sub%d", track.Index),
+				Bandwidth: 256,
+				BaseURL:   strings.TrimPrefix(track.FileKey, prefix),
+			}},
+		})
+	}
+
+	mpd.Periods = append(mpd.Periods, period)
+
+	body, err := mpd.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return p.bucket.UploadFileReader(p.processBucketName, prefix+"manifest.mpd", bytes.NewReader(body))
+}
+
+func dashRepresentation(prefix, id string, r models.Rendition) dash.Representation {
+	durations := make([]float64, len(r.Segments))
+	for i, s := range r.Segments {
+		durations[i] = s.Duration
+	}
+
+	bandwidth := r.PeakBitrate
+	if bandwidth == 0 {
+		bandwidth = r.Bitrate
+	}
+
+	return dash.Representation{
+		ID:        id,
+		Bandwidth: bandwidth,
+		Codecs:    r.Codecs,
+		Width:     r.Width,
+		Height:    r.Height,
+		BaseURL:   path.Dir(strings.TrimPrefix(r.PlaylistKey, prefix)) + "/",
+		SegmentTemplate: &dash.SegmentTemplate{
+			Timescale:      dash.Timescale,
+			Initialization: path.Base(r.InitKey),
+			Media:          "seg$Number%03d$.m4s",
+			StartNumber:    0,
+			Timeline:       dash.NewTimeline(durations),
+		},
+	}
+}
+
+// UploadManifest stores the success payload as manifest.json, after filling in the
+// total size of everything published for the episode and the processing time.
+func (p *Processor) UploadManifest(successEvent *models.UploadSuccessEvent, startedAt time.Time) error {
+	objects, err := p.bucket.ListObjects(p.processBucketName, fmt.Sprintf("videos/%s/", successEvent.EpId))
+	if err != nil {
+		return fmt.Errorf("erro ao listar arquivos processados: %w", err)
+	}
+	successEvent.TotalBytes = 0
+	for _, obj := range objects {
+		successEvent.TotalBytes += obj.Size
+	}
+	successEvent.ProcessingTimeSeconds = time.Since(startedAt).Seconds()
+
+	manifest, err := json.MarshalIndent(successEvent, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return p.bucket.UploadFileReader(p.processBucketName, successEvent.ManifestKey, bytes.NewReader(manifest))
+}
+
+// defaultBandwidth is used when a rendition could not be measured.
+func defaultBandwidth(height int) (int, int) {
+	switch height {
+	case 720:
+		return 3000000, 1280
+	case 1080:
+		return 5000000, 1920
+	}
+	return 1500000, 854
+}
+
+func joinCodecs(codecs ...string) string {
+	var parts []string
+	for _, c := range codecs {
+		if c != "" {
+			parts = append(parts, c)
+		}
+	}
+	return strings.Join(parts, ",")
+}
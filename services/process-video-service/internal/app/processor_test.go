@@ -2,12 +2,21 @@ package app_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math"
+	"math/rand"
+	"strings"
 	"testing"
+	"time"
 
 	"process-video-service/internal/app"
 	"process-video-service/internal/config"
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/hls"
 	"process-video-service/internal/models"
 
 	mocks "process-video-service/tests/mocks"
@@ -21,6 +30,8 @@ var configMock = &config.Config{
 	ProcessedVideoQueue:   "processed_videos",
 	FailProcessVideoQueue: "failed_videos",
 	BucketProcessedName:   "test-bucket-2",
+	HLSSegmentType:        "mpegts",
+	VideoCodecs:           []string{"h264"},
 }
 
 func TestProcessVideo_Success(t *testing.T) {
@@ -33,28 +44,89 @@ func TestProcessVideo_Success(t *testing.T) {
 		Bucket: "test-bucket",
 	}
 
-	mockVideo.On("GetHeight", mock.Anything, "test-bucket", "video.mp4").
-		Return(1080, nil)
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(&models.MediaInfo{
+			Height:      1080,
+			AudioTracks: []models.AudioTrack{{Index: 0, Language: "por", Name: "Português", Default: true}},
+		}, nil)
 
-	mockVideo.On("Process", mock.Anything, event, mock.AnythingOfType("int")).
-		Return(nil)
+	for _, res := range []int{1080, 720, 480} {
+		mockVideo.On("Process", mock.Anything, mock.Anything, models.Rung{Height: res, Codec: models.CodecH264}).
+			Return(&models.Rendition{
+				Height:      res,
+				Codecs:      "avc1.640028",
+				Bitrate:     res * 2000,
+				Duration:    60,
+				PlaylistKey: fmt.Sprintf("videos/ep123/%dp/index.m3u8", res),
+			}, nil)
+	}
+
+	mockVideo.On("ProcessAudio", mock.Anything, mock.Anything, mock.AnythingOfType("models.AudioTrack")).
+		Return(&models.AudioRendition{Rendition: models.Rendition{PlaylistKey: "videos/ep123/audio/0/index.m3u8"}}, nil)
+
+	mockBucket.On("ListObjects", "test-bucket", "video.mp4.").
+		Return([]models.ObjectInfo{{Key: "video.mp4.en.srt"}}, nil)
+
+	sidecar := models.SubtitleTrack{Index: 0, SidecarKey: "video.mp4.en.srt", Language: "en", Name: "en"}
+	mockVideo.On("ProcessSubtitle", mock.Anything, mock.Anything, sidecar).
+		Return(&models.SubtitleRendition{SubtitleTrack: sidecar, PlaylistKey: "videos/ep123/subtitles/0/index.m3u8"}, nil)
+
+	thumbnails := &models.ThumbnailAssets{
+		VTTKey:     "videos/ep123/thumbs/thumbnails.vtt",
+		SpriteKeys: []string{"videos/ep123/thumbs/sprite000.jpg"},
+	}
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.AnythingOfType("models.ThumbnailOptions")).
+		Return(thumbnails, nil)
+
+	stills := []models.Still{{
+		Time:   42,
+		Images: []models.StillImage{{Width: 1280, Key: "videos/ep123/stills/0-1280.jpg"}},
+	}}
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.AnythingOfType("models.StillOptions")).
+		Return(stills, nil)
 
 	mockBucket.On("UploadFileReader", "test-bucket-2", "videos/ep123/master.m3u8", mock.Anything).
 		Return(nil)
 
+	mockBucket.On("ListObjects", "test-bucket-2", "videos/ep123/").
+		Return([]models.ObjectInfo{{Key: "videos/ep123/master.m3u8", Size: 100}, {Key: "videos/ep123/720p/seg000.ts", Size: 2000}}, nil)
+
+	var manifest models.UploadSuccessEvent
+	mockBucket.On("UploadFileReader", "test-bucket-2", "videos/ep123/manifest.json", mock.Anything).
+		Run(func(args mock.Arguments) {
+			_ = json.NewDecoder(args.Get(2).(io.Reader)).Decode(&manifest)
+		}).Return(nil)
+
+	mockBucket.On("DeleteObject", "test-bucket", "video.mp4.en.srt").
+		Return(nil)
+
 	mockBucket.On("DeleteObject", "test-bucket", "video.mp4").
 		Return(nil)
 
-	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, configMock.BucketProcessedName)
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
 
-	err := processor.ProcessVideo(event)
+	successEvent, err := processor.ProcessVideo(event)
 	assert.NoError(t, err)
+	assert.Equal(t, "ep123", successEvent.EpId)
+	assert.Equal(t, "test-bucket-2", successEvent.Bucket)
+	assert.Equal(t, thumbnails, successEvent.Thumbnails)
+	assert.Equal(t, stills, successEvent.Stills)
+	assert.Equal(t, models.SuccessEventVersion, successEvent.Version)
+	assert.Equal(t, "videos/ep123/master.m3u8", successEvent.MasterPlaylistKey)
+	assert.Equal(t, float64(60), successEvent.Duration)
+	assert.Equal(t, int64(2100), successEvent.TotalBytes)
+	assert.Len(t, successEvent.Renditions, 3)
+	assert.Equal(t, 1080, successEvent.Renditions[0].Height)
+	assert.Equal(t, "videos/ep123/1080p/index.m3u8", manifest.Renditions[0].PlaylistKey)
+	assert.Equal(t, successEvent.TotalBytes, manifest.TotalBytes)
+	assert.False(t, manifest.Ladder.PerTitle)
+	assert.Len(t, manifest.Ladder.Rungs, 3)
 
 	mockVideo.AssertExpectations(t)
 	mockBucket.AssertExpectations(t)
 }
 
-func TestProcessVideo_ErrorOnGetHeight(t *testing.T) {
+func TestProcessVideo_ErrorOnProbe(t *testing.T) {
 	mockBucket := new(mocks.MockBucket)
 	mockVideo := new(mocks.MockVideo)
 
@@ -64,12 +136,12 @@ func TestProcessVideo_ErrorOnGetHeight(t *testing.T) {
 		Bucket: "test-bucket",
 	}
 
-	mockVideo.On("GetHeight", mock.Anything, "test-bucket", "video.mp4").
-		Return(0, errors.New("ffprobe failed"))
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(nil, errors.New("ffprobe failed"))
 
-	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, configMock.BucketProcessedName)
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
 
-	err := processor.ProcessVideo(event)
+	_, err := processor.ProcessVideo(event)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "ffprobe failed")
 }
@@ -84,19 +156,862 @@ func TestProcessVideo_ErrorOnProcessResolution(t *testing.T) {
 		Bucket: "test-bucket",
 	}
 
-	mockVideo.On("GetHeight", mock.Anything, "test-bucket", "video.mp4").
-		Return(720, nil)
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(&models.MediaInfo{Height: 720}, nil)
+
+	mockBucket.On("ListObjects", "test-bucket", "video.mp4.").
+		Return(nil, nil)
 
-	mockVideo.On("Process", mock.Anything, event, mock.AnythingOfType("int")).
-		Return(errors.New("encoder crash"))
+	mockVideo.On("Process", mock.Anything, mock.Anything, mock.AnythingOfType("models.Rung")).
+		Return(nil, errors.New("encoder crash"))
 
-	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, configMock.BucketProcessedName)
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.ThumbnailAssets{}, nil).Maybe()
 
-	err := processor.ProcessVideo(event)
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, nil).Maybe()
+
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
+
+	_, err := processor.ProcessVideo(event)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "encoder crash")
 }
 
+func TestProcessVideo_EncryptionStoresKeyBeforeEncoding(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+	mockKeys := new(mocks.MockKeyStore)
+
+	cfg := *configMock
+	cfg.HLSEncryption = models.EncryptionAES128
+	cfg.KeyServerURL = "https://keys.test/keys/"
+	cfg.PlaybackTokenSecret = "secret"
+
+	event := models.UploadEvent{
+		Key:    "video.mp4",
+		EpId:   "ep123",
+		Bucket: "test-bucket",
+	}
+
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(&models.MediaInfo{Height: 480}, nil)
+
+	mockBucket.On("ListObjects", "test-bucket", "video.mp4.").
+		Return(nil, nil)
+
+	var saved models.ContentKey
+	var keyId string
+	mockKeys.On("Save", mock.Anything, "ep123", mock.AnythingOfType("string"), mock.AnythingOfType("models.ContentKey")).
+		Run(func(args mock.Arguments) {
+			keyId = args.String(2)
+			saved = args.Get(3).(models.ContentKey)
+		}).Return(nil).Once()
+
+	mockVideo.On("Process", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
+		return job.Encryption != nil &&
+			job.Encryption.KeyURI == "https://keys.test/keys/ep123/"+keyId &&
+			bytes.Equal(job.Encryption.Key, saved.Key)
+	}), mock.AnythingOfType("models.Rung")).
+		Return(nil, errors.New("encoder crash"))
+
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.ThumbnailAssets{}, nil).Maybe()
+
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, nil).Maybe()
+
+	// the failed job's key is dropped, nothing points at it
+	mockKeys.On("Delete", mock.Anything, "ep123", mock.AnythingOfType("string")).
+		Return(nil).Once()
+
+	processor := app.NewProcessor(&cfg, nil, mockBucket, mockVideo, mockKeys, cfg.BucketProcessedName)
+
+	_, err := processor.ProcessVideo(event)
+	assert.ErrorContains(t, err, "encoder crash")
+	assert.Len(t, saved.Key, 16)
+	assert.Len(t, saved.IV, 16)
+	assert.NotEmpty(t, keyId)
+	mockKeys.AssertCalled(t, "Delete", mock.Anything, "ep123", keyId)
+	mockKeys.AssertExpectations(t)
+	mockVideo.AssertExpectations(t)
+}
+
+func TestProcessVideo_ReprocessingEncryptedEpisodeKeepsLiveKey(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+	mockKeys := new(mocks.MockKeyStore)
+
+	cfg := *configMock
+	cfg.HLSEncryption = models.EncryptionAES128
+	cfg.KeyServerURL = "https://keys.test/keys"
+	cfg.PlaybackTokenSecret = "secret"
+
+	event := models.UploadEvent{
+		Key:    "video.mp4",
+		EpId:   "ep123",
+		Bucket: "test-bucket",
+	}
+
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(&models.MediaInfo{Height: 480}, nil)
+
+	mockBucket.On("ListObjects", "test-bucket", "video.mp4.").
+		Return(nil, nil)
+
+	var keyIds []string
+	mockKeys.On("Save", mock.Anything, "ep123", mock.AnythingOfType("string"), mock.AnythingOfType("models.ContentKey")).
+		Run(func(args mock.Arguments) {
+			keyIds = append(keyIds, args.String(2))
+		}).Return(nil)
+
+	var keyURIs []string
+	mockVideo.On("Process", mock.Anything, mock.Anything, mock.AnythingOfType("models.Rung")).
+		Run(func(args mock.Arguments) {
+			keyURIs = append(keyURIs, args.Get(1).(*models.Job).Encryption.KeyURI)
+		}).Return(&models.Rendition{Height: 480, Duration: 60, PlaylistKey: "videos/ep123/480p/index.m3u8"}, nil)
+
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.ThumbnailAssets{}, nil)
+
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, nil)
+
+	mockBucket.On("UploadFileReader", "test-bucket-2", mock.Anything, mock.Anything).
+		Return(nil)
+
+	mockBucket.On("ListObjects", "test-bucket-2", "videos/ep123/").
+		Return(nil, nil)
+
+	mockBucket.On("DeleteObject", "test-bucket", "video.mp4").
+		Return(nil)
+
+	processor := app.NewProcessor(&cfg, nil, mockBucket, mockVideo, mockKeys, cfg.BucketProcessedName)
+
+	// the first run publishes the episode encrypted, the second reprocesses it
+	_, err := processor.ProcessVideo(event)
+	assert.NoError(t, err)
+	_, err = processor.ProcessVideo(event)
+	assert.NoError(t, err)
+	assert.Len(t, keyIds, 2)
+	assert.NotEqual(t, keyIds[0], keyIds[1])
+	assert.Equal(t, []string{"https://keys.test/keys/ep123/" + keyIds[0], "https://keys.test/keys/ep123/" + keyIds[1]}, keyURIs)
+	// successful jobs keep their keys: players still on the old playlists need the first one
+	mockKeys.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessVideo_EncryptionOverrideNeedsKeyHandler(t *testing.T) {
+	mockVideo := new(mocks.MockVideo)
+	mockKeys := new(mocks.MockKeyStore)
+
+	cfg := *configMock
+	cfg.KeyServerURL = "https://keys.test/keys/"
+
+	event := models.UploadEvent{
+		Key:       "video.mp4",
+		EpId:      "ep123",
+		Bucket:    "test-bucket",
+		Packaging: &models.PackagingOverride{Encryption: models.EncryptionAES128},
+	}
+
+	processor := app.NewProcessor(&cfg, nil, nil, mockVideo, mockKeys, cfg.BucketProcessedName)
+
+	_, err := processor.ProcessVideo(event)
+	assert.ErrorContains(t, err, "PLAYBACK_TOKEN_SECRET")
+	mockVideo.AssertNotCalled(t, "Probe", mock.Anything, mock.Anything, mock.Anything)
+	mockKeys.AssertNotCalled(t, "Save", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessVideo_PerTitleDropsRedundantRung(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.PerTitleEncoding = true
+	cfg.PerTitleSamples = 6
+	cfg.PerTitleSampleSeconds = 4
+	cfg.PerTitleCRF = 23
+
+	event := models.UploadEvent{
+		Key:    "video.mp4",
+		EpId:   "ep123",
+		Bucket: "test-bucket",
+	}
+
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(&models.MediaInfo{Height: 1080, Duration: 600}, nil)
+
+	mockBucket.On("ListObjects", "test-bucket", "video.mp4.").
+		Return(nil, nil)
+
+	// 720p barely saves anything over 1080p on this source, 480p does
+	mockVideo.On("AnalyzeComplexity", mock.Anything, mock.Anything, models.ComplexityOptions{Samples: 6, SampleSeconds: 4, CRF: 23}).
+		Return(&models.ComplexityAnalysis{CRF: 23, SampledSeconds: 24, Probes: []models.ComplexityProbe{
+			{Height: 1080, Bitrate: 2000000},
+			{Height: 720, Bitrate: 1800000},
+			{Height: 480, Bitrate: 900000},
+		}}, nil)
+
+	mockVideo.On("Process", mock.Anything, mock.Anything, models.Rung{Height: 1080, Codec: models.CodecH264, MaxBitrate: 2400000}).
+		Return(nil, errors.New("encoder crash"))
+	mockVideo.On("Process", mock.Anything, mock.Anything, models.Rung{Height: 480, Codec: models.CodecH264, MaxBitrate: 1080000}).
+		Return(nil, errors.New("encoder crash"))
+
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.ThumbnailAssets{}, nil).Maybe()
+
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, nil).Maybe()
+
+	processor := app.NewProcessor(&cfg, nil, mockBucket, mockVideo, nil, cfg.BucketProcessedName)
+
+	_, err := processor.ProcessVideo(event)
+	assert.ErrorContains(t, err, "encoder crash")
+	mockVideo.AssertNotCalled(t, "Process", mock.Anything, mock.Anything, mock.MatchedBy(func(rung models.Rung) bool {
+		return rung.Height == 720
+	}))
+}
+
+func TestMeasureQuality_BelowThreshold(t *testing.T) {
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.QualityVMAF = true
+	cfg.QualityMinVMAF = 80
+	cfg.QualityMinSSIM = 0.9
+
+	job := &models.Job{Event: models.UploadEvent{Key: "video.mp4", EpId: "ep123", Bucket: "test-bucket"}}
+
+	mockVideo.On("MeasureQuality", mock.Anything, job, mock.MatchedBy(func(r models.Rendition) bool { return r.Height == 1080 }), models.QualityOptions{VMAF: true}).
+		Return(&models.QualityScores{PSNR: 42.1, SSIM: 0.98, VMAF: 95.3}, nil)
+	mockVideo.On("MeasureQuality", mock.Anything, job, mock.MatchedBy(func(r models.Rendition) bool { return r.Height == 480 }), models.QualityOptions{VMAF: true}).
+		Return(&models.QualityScores{PSNR: 31.4, SSIM: 0.93, VMAF: 61.2}, nil)
+
+	renditions := []models.Rendition{{Height: 1080, VideoCodec: models.CodecH264}, {Height: 480, VideoCodec: models.CodecH264}}
+
+	processor := app.NewProcessor(&cfg, nil, nil, mockVideo, nil, cfg.BucketProcessedName)
+	assert.NoError(t, processor.MeasureQuality(context.Background(), job, renditions))
+	assert.False(t, renditions[0].Quality.Flagged)
+	assert.True(t, renditions[1].Quality.Flagged)
+	assert.Equal(t, []string{"vmaf 61.20 < 80"}, renditions[1].Quality.Reasons)
+
+	cfg.QualityFailBelowThreshold = true
+	processor = app.NewProcessor(&cfg, nil, nil, mockVideo, nil, cfg.BucketProcessedName)
+	err := processor.MeasureQuality(context.Background(), job, renditions)
+	assert.ErrorIs(t, err, app.ErrQualityBelowThreshold)
+	assert.ErrorContains(t, err, "480p (vmaf 61.20 < 80)")
+}
+
+func TestCheckSource_DeinterlacesInterlacedSource(t *testing.T) {
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.QCDeinterlace = models.DeinterlaceBwdif
+	cfg.QCInterlaceThreshold = 0.3
+	cfg.QCMaxBlackRatio = 0.9
+
+	job := &models.Job{
+		Event: models.UploadEvent{Key: "video.mp4", EpId: "ep123", Bucket: "test-bucket"},
+		Info:  &models.MediaInfo{Height: 1080, Duration: 100},
+	}
+
+	mockVideo.On("AnalyzeQC", mock.Anything, job).
+		Return(&models.QCReport{
+			Black:     []models.QCInterval{{Start: 0, End: 4, Duration: 4}},
+			Freeze:    []models.QCInterval{{Start: 50, End: 58, Duration: 8}},
+			Interlace: models.QCInterlace{TFF: 900, Progressive: 100, Undetermined: 20},
+		}, nil)
+
+	processor := app.NewProcessor(&cfg, nil, nil, mockVideo, nil, cfg.BucketProcessedName)
+
+	report, err := processor.CheckSource(context.Background(), job)
+	assert.NoError(t, err)
+	assert.True(t, report.Interlace.Interlaced)
+	assert.Equal(t, models.DeinterlaceBwdif, job.Deinterlace)
+	assert.Equal(t, models.DeinterlaceBwdif, report.Deinterlace)
+	assert.InDelta(t, 0.04, report.BlackRatio, 1e-9)
+	assert.Equal(t, []string{"source has no audio", "video frozen for 8.0s at 50.0s"}, report.Warnings)
+}
+
+func TestCheckSource_FailsMostlyBlackSource(t *testing.T) {
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.QCDeinterlace = models.DeinterlaceBwdif
+	cfg.QCInterlaceThreshold = 0.3
+	cfg.QCMaxBlackRatio = 0.9
+
+	job := &models.Job{
+		Event: models.UploadEvent{Key: "video.mp4", EpId: "ep123", Bucket: "test-bucket"},
+		Info:  &models.MediaInfo{Height: 1080, Duration: 100},
+	}
+
+	mockVideo.On("AnalyzeQC", mock.Anything, job).
+		Return(&models.QCReport{
+			Black:     []models.QCInterval{{Start: 0, End: 97, Duration: 97}},
+			Interlace: models.QCInterlace{Progressive: 1000},
+		}, nil)
+
+	processor := app.NewProcessor(&cfg, nil, nil, mockVideo, nil, cfg.BucketProcessedName)
+
+	_, err := processor.CheckSource(context.Background(), job)
+	assert.ErrorContains(t, err, "97%")
+	assert.Empty(t, job.Deinterlace)
+}
+
+func TestResolveCrop_AppliesStableDetection(t *testing.T) {
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.CropDetection = true
+	cfg.CropSamples = 10
+	cfg.CropSampleSeconds = 2
+	cfg.CropMinConfidence = 0.8
+
+	job := &models.Job{
+		Event: models.UploadEvent{Key: "video.mp4", EpId: "ep123", Bucket: "test-bucket"},
+		Info:  &models.MediaInfo{Width: 1920, Height: 1080, Duration: 600},
+	}
+
+	letterbox := models.Crop{Width: 1920, Height: 800, X: 0, Y: 140}
+	mockVideo.On("DetectCrop", mock.Anything, job, models.CropOptions{Samples: 10, SampleSeconds: 2}).
+		Return(&models.CropDetection{Rect: letterbox, Confidence: 0.92, Source: models.CropSourceDetected}, nil).Once()
+
+	processor := app.NewProcessor(&cfg, nil, nil, mockVideo, nil, cfg.BucketProcessedName)
+
+	detection, err := processor.ResolveCrop(context.Background(), job)
+	assert.NoError(t, err)
+	assert.True(t, detection.Applied)
+	assert.Equal(t, &letterbox, job.Crop)
+
+	// an unsure detection is recorded but the full frame is kept
+	job.Crop = nil
+	mockVideo.On("DetectCrop", mock.Anything, job, mock.Anything).
+		Return(&models.CropDetection{Rect: letterbox, Confidence: 0.55, Source: models.CropSourceDetected}, nil).Once()
+
+	detection, err = processor.ResolveCrop(context.Background(), job)
+	assert.NoError(t, err)
+	assert.False(t, detection.Applied)
+	assert.Equal(t, "confidence 0.55 below 0.80", detection.Reason)
+	assert.Nil(t, job.Crop)
+}
+
+func TestResolveCrop_OverrideSkipsDetection(t *testing.T) {
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.CropDetection = true
+
+	info := &models.MediaInfo{Width: 1920, Height: 1080}
+	processor := app.NewProcessor(&cfg, nil, nil, mockVideo, nil, cfg.BucketProcessedName)
+
+	off := &models.Job{Event: models.UploadEvent{Crop: &models.CropOverride{Off: true}}, Info: info}
+	detection, err := processor.ResolveCrop(context.Background(), off)
+	assert.NoError(t, err)
+	assert.False(t, detection.Applied)
+	assert.Nil(t, off.Crop)
+
+	rect := &models.Crop{Width: 1440, Height: 1080, X: 240}
+	manual := &models.Job{Event: models.UploadEvent{Crop: &models.CropOverride{Rect: rect}}, Info: info}
+	detection, err = processor.ResolveCrop(context.Background(), manual)
+	assert.NoError(t, err)
+	assert.Equal(t, models.CropSourceOverride, detection.Source)
+	assert.Equal(t, rect, manual.Crop)
+
+	outside := &models.Job{Event: models.UploadEvent{Crop: &models.CropOverride{Rect: &models.Crop{Width: 1920, Height: 1080, X: 2}}}, Info: info}
+	_, err = processor.ResolveCrop(context.Background(), outside)
+	assert.ErrorContains(t, err, "crop inválido")
+
+	mockVideo.AssertNotCalled(t, "DetectCrop", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDetectMarkers_MatchesSeasonIntroAndFindsCredits(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.Markers = true
+	cfg.MarkersIntroSeconds = 600
+	cfg.MarkersMinIntro = 15
+	cfg.MarkersMaxIntro = 120
+	cfg.MarkersCreditsSeconds = 300
+	cfg.MarkersMinCredits = 20
+	cfg.MarkersUpdatedQueue = "markers_updated"
+
+	// both episodes play the same ~25s theme, 12.4s into this one and 6.2s into the other
+	rng := rand.New(rand.NewSource(1))
+	points := func(n int) []uint32 {
+		p := make([]uint32, n)
+		for i := range p {
+			p[i] = rng.Uint32()
+		}
+		return p
+	}
+	theme := points(200)
+	current, previous := points(600), points(600)
+	copy(current[100:], theme)
+	copy(previous[50:], theme)
+	// before its intro, this one recaps two scenes of the other
+	copy(current[0:40], previous[350:390])
+	copy(current[45:95], previous[450:500])
+
+	job := &models.Job{
+		Event: models.UploadEvent{Bucket: "test-bucket", Key: "video.mp4", EpId: "ep123", SeasonId: "s1"},
+		Info:  &models.MediaInfo{Duration: 1300, AudioTracks: []models.AudioTrack{{Index: 0, Default: true}}},
+	}
+	mockVideo.On("FingerprintAudio", mock.Anything, job, float64(1300)).
+		Return(&models.AudioFingerprint{EpId: "ep123", Interval: helpers.FingerprintInterval, Points: current}, nil)
+
+	stored, _ := json.Marshal(models.AudioFingerprint{EpId: "ep100", Interval: helpers.FingerprintInterval, Points: previous})
+	mockBucket.On("ListObjects", "test-bucket-2", "fingerprints/s1/").
+		Return([]models.ObjectInfo{{Key: "fingerprints/s1/ep100.json"}, {Key: "fingerprints/s1/ep123.json"}}, nil)
+	mockBucket.On("GetObjectStream", "test-bucket-2", "fingerprints/s1/ep100.json").
+		Return(io.NopCloser(bytes.NewReader(stored)), nil)
+	mockBucket.On("UploadFileReader", "test-bucket-2", "fingerprints/s1/ep123.json", mock.Anything).
+		Return(nil).Once()
+
+	// the other episode was the first of the season and had nothing to match, it gets the
+	// intro now; its published manifest isn't touched
+	published, _ := json.Marshal(models.UploadSuccessEvent{EpId: "ep100", ManifestKey: "videos/ep100/manifest.json"})
+	mockBucket.On("GetObjectStream", "test-bucket-2", "videos/ep100/manifest.json").
+		Return(io.NopCloser(bytes.NewReader(published)), nil)
+	backfilled := func(event models.MarkersUpdatedEvent) bool {
+		m := event.Markers
+		return event.EpId == "ep100" && m != nil && m.IntroMatchedEpId == "ep123" &&
+			math.Abs(*m.IntroStart-50*helpers.FingerprintInterval) < 0.01 &&
+			math.Abs(*m.IntroEnd-250*helpers.FingerprintInterval) < 0.01
+	}
+	mockQueue := new(mocks.MockQueue)
+	mockQueue.On("Publish", "markers_updated", mock.MatchedBy(backfilled)).Return(nil).Once()
+
+	// a textless fade at 1100s, then a fade to black into 60s of white-on-black credits
+	analysis := &models.CreditsAnalysis{Black: []models.QCInterval{{Start: 1236, End: 1238.5, Duration: 2.5}}}
+	for sec := 1000; sec < 1300; sec++ {
+		frame := models.CreditsFrame{Time: float64(sec), Dark: 0.2, Edges: 9}
+		switch {
+		case sec >= 1100 && sec < 1130:
+			frame.Dark, frame.Edges = 0.99, 0.1
+		case sec >= 1240:
+			frame.Dark, frame.Edges = 0.9, 4
+		}
+		analysis.Frames = append(analysis.Frames, frame)
+	}
+	mockVideo.On("AnalyzeCredits", mock.Anything, job, float64(300)).
+		Return(analysis, nil)
+
+	processor := app.NewProcessor(&cfg, mockQueue, mockBucket, mockVideo, nil, cfg.BucketProcessedName)
+
+	markers, err := processor.DetectMarkers(context.Background(), job)
+	assert.NoError(t, err)
+	if assert.NotNil(t, markers.RecapStart) && assert.NotNil(t, markers.RecapEnd) {
+		assert.InDelta(t, 0, *markers.RecapStart, 0.01)
+		assert.InDelta(t, 95*helpers.FingerprintInterval, *markers.RecapEnd, 0.01)
+	}
+	assert.Equal(t, "ep100", markers.IntroMatchedEpId)
+	if assert.NotNil(t, markers.IntroStart) && assert.NotNil(t, markers.IntroEnd) {
+		assert.InDelta(t, 100*helpers.FingerprintInterval, *markers.IntroStart, 0.01)
+		assert.InDelta(t, 300*helpers.FingerprintInterval, *markers.IntroEnd, 0.01)
+	}
+	if assert.NotNil(t, markers.CreditsStart) {
+		assert.Equal(t, float64(1236), *markers.CreditsStart)
+	}
+	mockVideo.AssertExpectations(t)
+	mockBucket.AssertExpectations(t)
+	mockBucket.AssertNotCalled(t, "UploadFileReader", "test-bucket-2", "videos/ep100/manifest.json", mock.Anything)
+	mockQueue.AssertExpectations(t)
+	mockQueue.AssertNotCalled(t, "Publish", "processed_videos", mock.Anything)
+}
+
+func TestDetectMarkers_KeepsCreditsWhenFingerprintFails(t *testing.T) {
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.Markers = true
+	cfg.MarkersIntroSeconds = 600
+	cfg.MarkersCreditsSeconds = 300
+	cfg.MarkersMinCredits = 20
+
+	job := &models.Job{
+		Event: models.UploadEvent{Bucket: "test-bucket", Key: "video.mp4", EpId: "ep123", SeasonId: "s1"},
+		Info:  &models.MediaInfo{Duration: 1300, AudioTracks: []models.AudioTrack{{Index: 0, Default: true}}},
+	}
+	mockVideo.On("FingerprintAudio", mock.Anything, job, float64(1300)).
+		Return(nil, errors.New("chromaprint failed"))
+
+	analysis := &models.CreditsAnalysis{}
+	for sec := 1250; sec < 1300; sec++ {
+		analysis.Frames = append(analysis.Frames, models.CreditsFrame{Time: float64(sec), Dark: 0.9, Edges: 4})
+	}
+	mockVideo.On("AnalyzeCredits", mock.Anything, job, float64(300)).
+		Return(analysis, nil)
+
+	processor := app.NewProcessor(&cfg, nil, nil, mockVideo, nil, cfg.BucketProcessedName)
+
+	markers, err := processor.DetectMarkers(context.Background(), job)
+	assert.ErrorContains(t, err, "chromaprint failed")
+	if assert.NotNil(t, markers) && assert.NotNil(t, markers.CreditsStart) {
+		assert.Equal(t, float64(1250), *markers.CreditsStart)
+	}
+	assert.Nil(t, markers.IntroStart)
+}
+
+func TestBuildChapters_MergesSceneCutsOrKeepsEmbedded(t *testing.T) {
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.Chapters = true
+	cfg.ChapterMinSeconds = 60
+	cfg.ChapterSceneThreshold = 0.4
+	cfg.ChapterThumbnailWidth = 320
+	opts := models.ChapterOptions{MinSeconds: 60, SceneThreshold: 0.4, ThumbnailWidth: 320}
+
+	processor := app.NewProcessor(&cfg, nil, nil, mockVideo, nil, cfg.BucketProcessedName)
+
+	// cuts closer than a minute to the previous chapter or to the end are dropped
+	scenes := &models.Job{
+		Event: models.UploadEvent{Bucket: "test-bucket", Key: "video.mp4", EpId: "ep123"},
+		Info:  &models.MediaInfo{Duration: 300},
+	}
+	mockVideo.On("DetectScenes", mock.Anything, scenes, 0.4).
+		Return([]float64{30, 75, 150, 170, 260}, nil)
+	merged := []models.Chapter{
+		{Start: 0, End: 75, Title: "Chapter 1"},
+		{Start: 75, End: 150, Title: "Chapter 2"},
+		{Start: 150, End: 300, Title: "Chapter 3"},
+	}
+	mockVideo.On("GenerateChapters", mock.Anything, scenes, merged, opts).
+		Return(&models.ChapterAssets{VTTKey: "videos/ep123/chapters/chapters.vtt", Chapters: merged}, nil)
+
+	assets, err := processor.BuildChapters(context.Background(), scenes)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ChapterSourceScenes, assets.Source)
+	assert.Len(t, assets.Chapters, 3)
+
+	// the source's own chapters win and skip scene detection
+	embedded := []models.Chapter{{Start: 0, End: 120, Title: "Cold open"}, {Start: 120, End: 300, Title: "Act one"}}
+	withChapters := &models.Job{
+		Event: models.UploadEvent{Bucket: "test-bucket", Key: "movie.mkv", EpId: "ep456"},
+		Info:  &models.MediaInfo{Duration: 300, Chapters: embedded},
+	}
+	mockVideo.On("GenerateChapters", mock.Anything, withChapters, embedded, opts).
+		Return(&models.ChapterAssets{VTTKey: "videos/ep456/chapters/chapters.vtt", Chapters: embedded}, nil)
+
+	assets, err = processor.BuildChapters(context.Background(), withChapters)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ChapterSourceEmbedded, assets.Source)
+	mockVideo.AssertNumberOfCalls(t, "DetectScenes", 1)
+	mockVideo.AssertExpectations(t)
+}
+
+func TestBuildPreview_PicksMotionWindowOrOperatorTimestamp(t *testing.T) {
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.Preview = true
+	cfg.PreviewSeconds = 6
+	cfg.PreviewWidths = []int{480, 240}
+	opts := models.PreviewOptions{Seconds: 6, Widths: []int{480, 240}}
+
+	processor := app.NewProcessor(&cfg, nil, nil, mockVideo, nil, cfg.BucketProcessedName)
+
+	// a calm episode with an action scene at 300s and a busier recap in the first tenth
+	motion := &models.Job{
+		Event: models.UploadEvent{Bucket: "test-bucket", Key: "video.mp4", EpId: "ep123"},
+		Info:  &models.MediaInfo{Duration: 1200},
+	}
+	var samples []models.MotionSample
+	for tenths := 0; tenths < 12000; tenths += 5 {
+		sample := models.MotionSample{Time: float64(tenths) / 10, Score: 0.01}
+		switch {
+		case sample.Time < 60:
+			sample.Score = 0.5
+		case sample.Time >= 300 && sample.Time < 306:
+			sample.Score = 0.3
+		}
+		samples = append(samples, sample)
+	}
+	mockVideo.On("MeasureMotion", mock.Anything, motion).Return(samples, nil)
+	clips := []models.PreviewClip{{Width: 480, MP4Key: "videos/ep123/preview/480.mp4", WebPKey: "videos/ep123/preview/480.webp"}}
+	mockVideo.On("GeneratePreview", mock.Anything, motion, float64(300), opts).Return(clips, nil)
+
+	preview, err := processor.BuildPreview(context.Background(), motion)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PreviewSourceMotion, preview.Source)
+	assert.Equal(t, float64(300), preview.Start)
+	assert.Equal(t, clips, preview.Clips)
+
+	// the operator's timestamp skips the analysis, pulled back so the preview fits
+	at := 1198.0
+	operator := &models.Job{
+		Event: models.UploadEvent{Bucket: "test-bucket", Key: "movie.mkv", EpId: "ep456", PreviewAt: &at},
+		Info:  &models.MediaInfo{Duration: 1200},
+	}
+	mockVideo.On("GeneratePreview", mock.Anything, operator, float64(1194), opts).Return(clips, nil)
+
+	preview, err = processor.BuildPreview(context.Background(), operator)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PreviewSourceOperator, preview.Source)
+	assert.Equal(t, float64(1194), preview.Start)
+	mockVideo.AssertNumberOfCalls(t, "MeasureMotion", 1)
+	mockVideo.AssertExpectations(t)
+}
+
+func TestProcessVideo_HDRSourceGetsToneMappedAndHDRLadders(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.HLSSegmentType = models.SegmentTypeFMP4
+	cfg.HDRLadder = true
+
+	event := models.UploadEvent{
+		Key:    "video.mp4",
+		EpId:   "ep123",
+		Bucket: "test-bucket",
+	}
+
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(&models.MediaInfo{Height: 720, ColorTransfer: "smpte2084", VideoRange: models.VideoRangePQ}, nil)
+
+	mockBucket.On("ListObjects", "test-bucket", "video.mp4.").
+		Return(nil, nil)
+
+	for _, rung := range []models.Rung{
+		{Height: 720, Codec: models.CodecH264},
+		{Height: 480, Codec: models.CodecH264},
+		{Height: 720, Codec: models.CodecHEVC, HDR: true},
+		{Height: 480, Codec: models.CodecHEVC, HDR: true},
+	} {
+		mockVideo.On("Process", mock.Anything, mock.Anything, rung).
+			Return(nil, errors.New("encoder crash")).Once()
+	}
+
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.ThumbnailAssets{}, nil).Maybe()
+
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, nil).Maybe()
+
+	processor := app.NewProcessor(&cfg, nil, mockBucket, mockVideo, nil, cfg.BucketProcessedName)
+
+	_, err := processor.ProcessVideo(event)
+	assert.ErrorContains(t, err, "encoder crash")
+	mockVideo.AssertExpectations(t)
+}
+
+func TestProcessVideo_LoudnormPassesTargetToAudio(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.Loudnorm = true
+	cfg.LoudnormIntegrated = -16
+	cfg.LoudnormTruePeak = -1.5
+	cfg.LoudnormRange = 11
+
+	event := models.UploadEvent{
+		Key:    "video.mp4",
+		EpId:   "ep123",
+		Bucket: "test-bucket",
+	}
+
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(&models.MediaInfo{Height: 480, AudioTracks: []models.AudioTrack{{Index: 0}}}, nil)
+
+	mockBucket.On("ListObjects", "test-bucket", "video.mp4.").
+		Return(nil, nil)
+
+	mockVideo.On("Process", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("encoder crash")).Maybe()
+
+	target := models.LoudnessTarget{Integrated: -16, TruePeak: -1.5, Range: 11}
+	mockVideo.On("ProcessAudio", mock.Anything, mock.MatchedBy(func(job *models.Job) bool {
+		return job.Loudness != nil && *job.Loudness == target
+	}), models.AudioTrack{Index: 0, Channels: 2}).
+		Return(nil, errors.New("encoder crash")).Once()
+
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.ThumbnailAssets{}, nil).Maybe()
+
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, nil).Maybe()
+
+	processor := app.NewProcessor(&cfg, nil, mockBucket, mockVideo, nil, cfg.BucketProcessedName)
+
+	_, err := processor.ProcessVideo(event)
+	assert.ErrorContains(t, err, "encoder crash")
+	mockVideo.AssertExpectations(t)
+}
+
+func mockPublishedPlaylists(mockBucket *mocks.MockBucket, objects []models.ObjectInfo) {
+	playlists := map[string]string{
+		"videos/ep123/master.m3u8": "#EXTM3U\n#EXT-X-VERSION:3\n" +
+			"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",NAME=\"Português\",DEFAULT=YES,AUTOSELECT=YES,URI=\"audio/0/index.m3u8\"\n" +
+			"#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=\"Português\",AUTOSELECT=YES,URI=\"subtitles/0/index.m3u8\"\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=3000000,AUDIO=\"audio\",SUBTITLES=\"subs\"\n720p/index.m3u8\n",
+		"videos/ep123/720p/index.m3u8": "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n" +
+			"#EXTINF:10.000,\nseg000.ts\n#EXTINF:5.000,\nseg001.ts\n#EXT-X-ENDLIST\n",
+		"videos/ep123/audio/0/index.m3u8": "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:15\n" +
+			"#EXTINF:15.000,\nseg000.ts\n#EXT-X-ENDLIST\n",
+		"videos/ep123/subtitles/0/index.m3u8": "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n" +
+			"#EXTINF:6.000,\nseg000.vtt\n#EXT-X-ENDLIST\n",
+	}
+	for key, body := range playlists {
+		mockBucket.On("GetObjectStream", "test-bucket-2", key).
+			Return(io.NopCloser(bytes.NewBufferString(body)), nil).Maybe()
+	}
+	mockBucket.On("ListObjects", "test-bucket-2", "videos/ep123/").Return(objects, nil)
+}
+
+func TestVerifyOutputs_Success(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.VerifyDurationTolerance = 1
+	cfg.VerifySampleSegments = 2
+
+	mockPublishedPlaylists(mockBucket, []models.ObjectInfo{
+		{Key: "videos/ep123/master.m3u8", Size: 300},
+		{Key: "videos/ep123/720p/index.m3u8", Size: 120},
+		{Key: "videos/ep123/720p/seg000.ts", Size: 2000},
+		{Key: "videos/ep123/720p/seg001.ts", Size: 1000},
+		{Key: "videos/ep123/audio/0/index.m3u8", Size: 90},
+		{Key: "videos/ep123/audio/0/seg000.ts", Size: 300},
+		{Key: "videos/ep123/subtitles/0/index.m3u8", Size: 80},
+		{Key: "videos/ep123/subtitles/0/seg000.vtt", Size: 40},
+	})
+	mockVideo.On("VerifySegment", mock.Anything, mock.Anything, mock.AnythingOfType("string"), "").
+		Return(nil)
+
+	job := &models.Job{
+		Event: models.UploadEvent{Key: "video.mp4", EpId: "ep123", Bucket: "test-bucket"},
+		Info:  &models.MediaInfo{Duration: 15.4},
+	}
+	processor := app.NewProcessor(&cfg, nil, mockBucket, mockVideo, nil, cfg.BucketProcessedName)
+
+	err := processor.VerifyOutputs(context.Background(), job)
+	assert.NoError(t, err)
+	mockVideo.AssertNumberOfCalls(t, "VerifySegment", 2)
+}
+
+func TestVerifyOutputs_MissingSegmentAndShortRendition(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	mockPublishedPlaylists(mockBucket, []models.ObjectInfo{
+		{Key: "videos/ep123/720p/seg000.ts", Size: 2000},
+		{Key: "videos/ep123/audio/0/seg000.ts", Size: 0},
+	})
+
+	job := &models.Job{
+		Event: models.UploadEvent{Key: "video.mp4", EpId: "ep123", Bucket: "test-bucket"},
+		Info:  &models.MediaInfo{Duration: 30},
+	}
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
+
+	err := processor.VerifyOutputs(context.Background(), job)
+	assert.ErrorContains(t, err, "videos/ep123/720p/seg001.ts, que não existe")
+	assert.ErrorContains(t, err, "videos/ep123/audio/0/seg000.ts está vazio")
+	assert.ErrorContains(t, err, "videos/ep123/720p/index.m3u8 dura 10.000s, a fonte 30.000s")
+	mockVideo.AssertNotCalled(t, "VerifySegment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestVerifyOutputs_EstimatedSourceDurationSkipsLengthCheck(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.VerifyDurationTolerance = 1
+
+	mockPublishedPlaylists(mockBucket, []models.ObjectInfo{
+		{Key: "videos/ep123/720p/seg000.ts", Size: 2000},
+		{Key: "videos/ep123/720p/seg001.ts", Size: 1000},
+		{Key: "videos/ep123/audio/0/seg000.ts", Size: 300},
+		{Key: "videos/ep123/subtitles/0/seg000.vtt", Size: 40},
+	})
+	mockVideo.On("VerifySegment", mock.Anything, mock.Anything, mock.AnythingOfType("string"), "").
+		Return(nil)
+
+	// ffprobe's guess from the first megabytes of an MPEG-TS source
+	job := &models.Job{
+		Event: models.UploadEvent{Key: "video.ts", EpId: "ep123", Bucket: "test-bucket"},
+		Info:  &models.MediaInfo{Duration: 1.2, DurationEstimated: true},
+	}
+	processor := app.NewProcessor(&cfg, nil, mockBucket, mockVideo, nil, cfg.BucketProcessedName)
+
+	err := processor.VerifyOutputs(context.Background(), job)
+	assert.NoError(t, err)
+}
+
+func TestUploadManifest(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+
+	mockBucket.On("ListObjects", "test-bucket-2", "videos/ep123/").
+		Return([]models.ObjectInfo{
+			{Key: "videos/ep123/master.m3u8", Size: 100},
+			{Key: "videos/ep123/720p/index.m3u8", Size: 300},
+			{Key: "videos/ep123/720p/seg000.ts", Size: 2000000},
+		}, nil)
+
+	var raw []byte
+	mockBucket.On("UploadFileReader", "test-bucket-2", "videos/ep123/manifest.json", mock.Anything).
+		Run(func(args mock.Arguments) {
+			raw, _ = io.ReadAll(args.Get(2).(io.Reader))
+		}).Return(nil)
+
+	event := &models.UploadSuccessEvent{
+		Version:           models.SuccessEventVersion,
+		EpId:              "ep123",
+		Key:               "video.mp4",
+		Bucket:            "test-bucket-2",
+		MasterPlaylistKey: "videos/ep123/master.m3u8",
+		ManifestKey:       "videos/ep123/manifest.json",
+		Packaging:         models.Packaging{SegmentType: models.SegmentTypeMPEGTS},
+		Duration:          60.5,
+		Source:            models.SourceSummary{Container: "mov,mp4", VideoCodec: "h264", Width: 1920, Height: 1080, Duration: 60.5, AudioStreams: 1},
+		Renditions:        []models.Rendition{{Height: 720, Codecs: "avc1.64001f", Bitrate: 3000000, Duration: 60.5, PlaylistKey: "videos/ep123/720p/index.m3u8"}},
+		AudioTracks: []models.AudioRendition{{
+			AudioTrack: models.AudioTrack{Language: "por", Name: "Português", Default: true},
+			Rendition:  models.Rendition{PlaylistKey: "videos/ep123/audio/0/index.m3u8"},
+		}},
+		Subtitles: []models.SubtitleRendition{},
+		Stills:    []models.Still{{Time: 42, Images: []models.StillImage{{Width: 1280, Key: "videos/ep123/stills/0-1280.jpg"}}}},
+	}
+
+	processor := app.NewProcessor(configMock, nil, mockBucket, nil, nil, configMock.BucketProcessedName)
+	err := processor.UploadManifest(event, time.Now().Add(-2*time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2000400), event.TotalBytes)
+	assert.GreaterOrEqual(t, event.ProcessingTimeSeconds, 2.0)
+
+	// the manifest is the success event as published
+	var manifest models.UploadSuccessEvent
+	assert.NoError(t, json.Unmarshal(raw, &manifest))
+	assert.Equal(t, *event, manifest)
+
+	var fields map[string]any
+	assert.NoError(t, json.Unmarshal(raw, &fields))
+	for _, key := range []string{"version", "epId", "masterPlaylistKey", "manifestKey", "packaging", "duration", "source", "renditions", "audioTracks", "subtitles", "stills", "totalBytes", "processingTimeSeconds"} {
+		assert.Contains(t, fields, key)
+	}
+	for _, key := range []string{"dashManifestKey", "thumbnails", "ladder", "qc", "markers", "downloads", "downloadsOmitted", "clip"} {
+		assert.NotContains(t, fields, key)
+	}
+
+	mockBucket.AssertExpectations(t)
+}
+
+func TestUploadManifest_ListError(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockBucket.On("ListObjects", "test-bucket-2", "videos/ep123/").Return(nil, errors.New("access denied"))
+
+	processor := app.NewProcessor(configMock, nil, mockBucket, nil, nil, configMock.BucketProcessedName)
+	err := processor.UploadManifest(&models.UploadSuccessEvent{EpId: "ep123", ManifestKey: "videos/ep123/manifest.json"}, time.Now())
+	assert.ErrorContains(t, err, "access denied")
+	mockBucket.AssertNotCalled(t, "UploadFileReader", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestUploadMasterPlaylist(t *testing.T) {
 	mockBucket := new(mocks.MockBucket)
 	mockVideo := new(mocks.MockVideo)
@@ -106,7 +1021,78 @@ func TestUploadMasterPlaylist(t *testing.T) {
 		EpId:   "ep123",
 		Bucket: "test-bucket",
 	}
-	resolutions := []int{720, 1080}
+	renditions := []models.Rendition{
+		{
+			Width: 1280, Height: 720, Codecs: "avc1.64001f", Bitrate: 2500000, PeakBitrate: 3000000, PlaylistKey: "videos/ep123/720p/index.m3u8",
+			IFramePlaylistKey: "videos/ep123/720p/iframes.m3u8", IFrameBitrate: 450000,
+		},
+		{Height: 1080, PlaylistKey: "videos/ep123/1080p/index.m3u8"},
+	}
+	audio := []models.AudioRendition{
+		{
+			AudioTrack: models.AudioTrack{Index: 0, Language: "por", Name: "Português", Default: true},
+			Rendition:  models.Rendition{Codecs: "mp4a.40.2", Bitrate: 120000, PeakBitrate: 130000, PlaylistKey: "videos/ep123/audio/0/index.m3u8"},
+		},
+		{
+			AudioTrack: models.AudioTrack{Index: 1, Language: "eng", Name: "English"},
+			Rendition:  models.Rendition{Codecs: "mp4a.40.2", Bitrate: 110000, PeakBitrate: 125000, PlaylistKey: "videos/ep123/audio/1/index.m3u8"},
+		},
+	}
+	subtitles := []models.SubtitleRendition{
+		{
+			SubtitleTrack: models.SubtitleTrack{Index: 0, Language: "por", Name: "Português"},
+			PlaylistKey:   "videos/ep123/subtitles/0/index.m3u8",
+		},
+	}
+
+	mockBucket.On("UploadFileReader", "test-bucket-2", "videos/ep123/master.m3u8", mock.Anything).
+		Run(func(args mock.Arguments) {
+			body := args.Get(2).(io.Reader)
+			buf := new(bytes.Buffer)
+			_, _ = buf.ReadFrom(body)
+			content := buf.String()
+			assert.Contains(t, content, "\n720p/index.m3u8\n")
+			assert.Contains(t, content, "\n1080p/index.m3u8\n")
+			assert.Contains(t, content, `#EXT-X-STREAM-INF:BANDWIDTH=3130000,AVERAGE-BANDWIDTH=2620000,RESOLUTION=1280x720,CODECS="avc1.64001f,mp4a.40.2"`)
+			assert.Contains(t, content, `#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,AUDIO="audio"`)
+			assert.Contains(t, content, `#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="Português",LANGUAGE="por",DEFAULT=YES,AUTOSELECT=YES,URI="audio/0/index.m3u8"`)
+			assert.Contains(t, content, `NAME="English",LANGUAGE="eng",DEFAULT=NO,AUTOSELECT=YES,URI="audio/1/index.m3u8"`)
+			assert.Contains(t, content, `AUDIO="audio"`)
+			assert.Contains(t, content, `#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="Português",LANGUAGE="por",DEFAULT=NO,AUTOSELECT=YES,FORCED=NO,URI="subtitles/0/index.m3u8"`)
+			assert.Contains(t, content, `SUBTITLES="subs"`)
+			assert.Contains(t, content, `#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=450000,RESOLUTION=1280x720,CODECS="avc1.64001f",URI="720p/iframes.m3u8"`)
+		}).Return(nil)
+
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
+	job := &models.Job{Event: event, Packaging: models.Packaging{SegmentType: models.SegmentTypeMPEGTS}}
+	err := processor.UploadMasterPlaylist(job, renditions, audio, subtitles)
+
+	assert.NoError(t, err)
+	mockBucket.AssertExpectations(t)
+}
+
+func TestUploadMasterPlaylist_SurroundAudio(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	event := models.UploadEvent{
+		Key:    "video.mp4",
+		EpId:   "ep123",
+		Bucket: "test-bucket",
+	}
+	renditions := []models.Rendition{
+		{Width: 1280, Height: 720, Codecs: "avc1.64001f", Bitrate: 2500000, PeakBitrate: 3000000, PlaylistKey: "videos/ep123/720p/index.m3u8"},
+	}
+	audio := []models.AudioRendition{
+		{
+			AudioTrack: models.AudioTrack{Index: 0, Language: "por", Name: "Português", Default: true, Channels: 2},
+			Rendition:  models.Rendition{Codecs: "mp4a.40.2", Bitrate: 120000, PeakBitrate: 130000, PlaylistKey: "videos/ep123/audio/0/index.m3u8"},
+		},
+		{
+			AudioTrack: models.AudioTrack{Index: 0, Language: "por", Name: "Português 5.1", Channels: 6, Surround: true},
+			Rendition:  models.Rendition{Codecs: "ec-3", Bitrate: 384000, PeakBitrate: 390000, PlaylistKey: "videos/ep123/audio/0-surround/index.m3u8"},
+		},
+	}
 
 	mockBucket.On("UploadFileReader", "test-bucket-2", "videos/ep123/master.m3u8", mock.Anything).
 		Run(func(args mock.Arguments) {
@@ -114,12 +1100,475 @@ func TestUploadMasterPlaylist(t *testing.T) {
 			buf := new(bytes.Buffer)
 			_, _ = buf.ReadFrom(body)
 			content := buf.String()
-			assert.Contains(t, content, "720/index.m3u8")
-			assert.Contains(t, content, "1080/index.m3u8")
+			assert.Contains(t, content, `NAME="Português",LANGUAGE="por",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="audio/0/index.m3u8"`)
+			assert.Contains(t, content, `NAME="Português 5.1",LANGUAGE="por",DEFAULT=NO,AUTOSELECT=YES,CHANNELS="6",URI="audio/0-surround/index.m3u8"`)
+			// the variant is sized and labelled for the heaviest rendition of its audio group
+			assert.Contains(t, content, `#EXT-X-STREAM-INF:BANDWIDTH=3390000,AVERAGE-BANDWIDTH=2884000,RESOLUTION=1280x720,CODECS="avc1.64001f,mp4a.40.2,ec-3",AUDIO="audio"`)
+		}).Return(nil)
+
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
+	job := &models.Job{Event: event, Packaging: models.Packaging{SegmentType: models.SegmentTypeMPEGTS}}
+	err := processor.UploadMasterPlaylist(job, renditions, audio, nil)
+
+	assert.NoError(t, err)
+	mockBucket.AssertExpectations(t)
+}
+
+func TestProcessVideo_SurroundSourceGetsDownmixAndSurroundRendition(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.SurroundAudioCodec = models.SurroundCodecEAC3
+
+	event := models.UploadEvent{
+		Key:    "video.mp4",
+		EpId:   "ep123",
+		Bucket: "test-bucket",
+	}
+
+	source := models.AudioTrack{Index: 0, Language: "por", Name: "Português", Default: true, Channels: 6, ChannelLayout: "5.1(side)"}
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(&models.MediaInfo{Height: 480, AudioTracks: []models.AudioTrack{source}}, nil)
+
+	mockBucket.On("ListObjects", "test-bucket", "video.mp4.").
+		Return(nil, nil)
+
+	mockVideo.On("Process", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("encoder crash")).Maybe()
+
+	stereo := source
+	stereo.Channels = 2
+	surround := source
+	surround.Name = "Português 5.1"
+	surround.Default = false
+	surround.Surround = true
+	onlyEAC3 := mock.MatchedBy(func(job *models.Job) bool { return job.SurroundCodec == models.SurroundCodecEAC3 })
+	mockVideo.On("ProcessAudio", mock.Anything, onlyEAC3, stereo).
+		Return(nil, errors.New("encoder crash")).Once()
+	mockVideo.On("ProcessAudio", mock.Anything, onlyEAC3, surround).
+		Return(nil, errors.New("encoder crash")).Once()
+
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.ThumbnailAssets{}, nil).Maybe()
+
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, nil).Maybe()
+
+	processor := app.NewProcessor(&cfg, nil, mockBucket, mockVideo, nil, cfg.BucketProcessedName)
+
+	_, err := processor.ProcessVideo(event)
+	assert.ErrorContains(t, err, "encoder crash")
+	mockVideo.AssertExpectations(t)
+}
+
+func TestProcessVideo_DownloadsRemuxConfiguredHeights(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	cfg := *configMock
+	cfg.VideoCodecs = []string{"h264", "hevc"}
+	cfg.HLSSegmentType = models.SegmentTypeFMP4
+	cfg.DownloadHeights = []int{720, 480}
+
+	event := models.UploadEvent{
+		Key:    "video.mp4",
+		EpId:   "ep123",
+		Bucket: "test-bucket",
+	}
+
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(&models.MediaInfo{Height: 1080, AudioTracks: []models.AudioTrack{{Index: 0, Default: true, Channels: 2}}}, nil)
+
+	mockBucket.On("ListObjects", "test-bucket", "video.mp4.").
+		Return(nil, nil)
+
+	playlist := []byte("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:6\n#EXT-X-MAP:URI=\"init.mp4\"\n" +
+		"#EXTINF:6.000,\nseg000.m4s\n#EXTINF:4.000,\nseg001.m4s\n#EXT-X-ENDLIST\n")
+	for _, codec := range []string{models.CodecH264, models.CodecHEVC} {
+		for _, res := range []int{1080, 720, 480} {
+			rung := models.Rung{Height: res, Codec: codec}
+			dir := "videos/ep123/" + helpers.RenditionName(rung)
+			mockVideo.On("Process", mock.Anything, mock.Anything, rung).
+				Return(&models.Rendition{Height: res, PlaylistKey: dir + "/index.m3u8"}, nil)
+			mockBucket.On("GetObjectStream", "test-bucket-2", dir+"/index.m3u8").
+				Return(io.NopCloser(bytes.NewReader(playlist)), nil).Maybe()
+		}
+	}
+	mockVideo.On("ProcessAudio", mock.Anything, mock.Anything, mock.AnythingOfType("models.AudioTrack")).
+		Return(&models.AudioRendition{
+			AudioTrack: models.AudioTrack{Index: 0, Default: true, Channels: 2},
+			Rendition:  models.Rendition{PlaylistKey: "videos/ep123/audio/0/index.m3u8"},
+		}, nil)
+	mockBucket.On("GetObjectStream", "test-bucket-2", "videos/ep123/audio/0/index.m3u8").
+		Return(io.NopCloser(bytes.NewReader(playlist)), nil)
+
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.ThumbnailAssets{}, nil)
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, nil)
+
+	// only the H.264 rungs at the configured heights, remuxed with the stereo audio,
+	// never 1080p nor the HEVC ladder
+	audioKeys := []string{"videos/ep123/audio/0/init.mp4", "videos/ep123/audio/0/seg000.m4s", "videos/ep123/audio/0/seg001.m4s"}
+	for _, name := range []string{"720p", "480p"} {
+		mockVideo.On("ProcessDownload", mock.Anything, mock.Anything, models.DownloadPlan{
+			Name:      name,
+			VideoKeys: []string{"videos/ep123/" + name + "/init.mp4", "videos/ep123/" + name + "/seg000.m4s", "videos/ep123/" + name + "/seg001.m4s"},
+			AudioKeys: audioKeys,
+		}).Return(nil, errors.New("remux failed")).Once()
+	}
+
+	processor := app.NewProcessor(&cfg, nil, mockBucket, mockVideo, nil, cfg.BucketProcessedName)
+
+	_, err := processor.ProcessVideo(event)
+	assert.ErrorContains(t, err, "remux failed")
+	mockVideo.AssertExpectations(t)
+}
+
+func TestBuildDownloads_EncryptedEpisodeSaysWhy(t *testing.T) {
+	cfg := *configMock
+	cfg.DownloadHeights = []int{720}
+
+	processor := app.NewProcessor(&cfg, nil, nil, nil, nil, cfg.BucketProcessedName)
+
+	job := &models.Job{
+		Ladder:     []models.Rung{{Height: 720, Codec: models.CodecH264}},
+		Encryption: &models.Encryption{Scheme: models.EncryptionAES128},
+	}
+	downloads, omitted, err := processor.BuildDownloads(context.Background(), job, []models.Rendition{{Height: 720}}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, downloads)
+	assert.Equal(t, models.DownloadsOmittedEncrypted, omitted)
+
+	// a source shorter than every download height
+	job = &models.Job{Ladder: []models.Rung{{Height: 480, Codec: models.CodecH264}}}
+	_, omitted, err = processor.BuildDownloads(context.Background(), job, []models.Rendition{{Height: 480}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DownloadsOmittedNoRung, omitted)
+}
+
+func TestProcessVideo_OverlayIsResolvedAndReachesRenditions(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	event := models.UploadEvent{
+		Key:     "video.mp4",
+		EpId:    "ep123",
+		Bucket:  "test-bucket",
+		Overlay: &models.Overlay{ImageKey: "logos/channel.png", Margin: 40, Start: 5},
+	}
+
+	mockVideo.On("Probe", mock.Anything, "test-bucket", "video.mp4").
+		Return(&models.MediaInfo{Width: 1920, Height: 1080, Duration: 600}, nil)
+
+	mockBucket.On("ListObjects", "test-bucket", "video.mp4.").
+		Return(nil, nil)
+
+	// unset corner and opacity get their defaults before the image is fetched
+	resolved := models.Overlay{ImageKey: "logos/channel.png", Corner: models.OverlayTopRight, Margin: 40, Opacity: 1, Start: 5}
+	image := &models.OverlayImage{Overlay: resolved, Path: "/dev/shm/overlays/channel.png", Width: 200, Height: 80}
+	mockVideo.On("PrepareOverlay", mock.Anything, mock.Anything, resolved).
+		Return(image, nil).Once()
+
+	withOverlay := mock.MatchedBy(func(job *models.Job) bool { return job.Overlay == image })
+	mockVideo.On("Process", mock.Anything, withOverlay, mock.Anything).
+		Return(nil, errors.New("encoder crash"))
+
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.ThumbnailAssets{}, nil).Maybe()
+
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, nil).Maybe()
+
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
+
+	_, err := processor.ProcessVideo(event)
+	assert.ErrorContains(t, err, "encoder crash")
+	mockVideo.AssertNumberOfCalls(t, "Process", 3)
+
+	// a bad parameter fails the upload before anything is fetched or encoded
+	event.Overlay = &models.Overlay{ImageKey: "logos/channel.png", Corner: "center"}
+	_, err = processor.ProcessVideo(event)
+	assert.ErrorContains(t, err, "overlay inválido")
+	mockVideo.AssertNumberOfCalls(t, "PrepareOverlay", 1)
+	mockVideo.AssertNumberOfCalls(t, "Process", 3)
+}
+
+// mockClipSource publishes ep123: 60s in six 10s segments per rendition.
+func mockClipSource(mockBucket *mocks.MockBucket) {
+	source := models.UploadSuccessEvent{
+		EpId:      "ep123",
+		Packaging: models.Packaging{SegmentType: models.SegmentTypeMPEGTS},
+		Duration:  60,
+		Renditions: []models.Rendition{
+			{Height: 720, Bitrate: 2500000, PlaylistKey: "videos/ep123/720p/index.m3u8"},
+			{Height: 1080, Bitrate: 5000000, PlaylistKey: "videos/ep123/1080p/index.m3u8"},
+			{Height: 480, Bitrate: 1000000, PlaylistKey: "videos/ep123/480p/index.m3u8"},
+		},
+		AudioTracks: []models.AudioRendition{
+			{
+				AudioTrack: models.AudioTrack{Index: 0, Language: "por", Name: "Português", Default: true, Channels: 2},
+				Rendition:  models.Rendition{PlaylistKey: "videos/ep123/audio/0/index.m3u8"},
+			},
+			{
+				AudioTrack: models.AudioTrack{Index: 0, Language: "por", Name: "Português 5.1", Channels: 6, Surround: true},
+				Rendition:  models.Rendition{PlaylistKey: "videos/ep123/audio/0-surround/index.m3u8"},
+			},
+		},
+	}
+	manifest, _ := json.Marshal(source)
+	mockBucket.On("GetObjectStream", "test-bucket-2", "videos/ep123/manifest.json").
+		Return(io.NopCloser(bytes.NewReader(manifest)), nil)
+
+	playlist := hls.MediaPlaylist{Version: 3, TargetDuration: 10}
+	for i := 0; i < 6; i++ {
+		playlist.Segments = append(playlist.Segments, hls.Segment{Duration: 10, URI: fmt.Sprintf("seg%03d.ts", i)})
+	}
+	for _, dir := range []string{"1080p", "audio/0-surround"} {
+		mockBucket.On("GetObjectStream", "test-bucket-2", "videos/ep123/"+dir+"/index.m3u8").
+			Return(io.NopCloser(bytes.NewReader(playlist.Encode())), nil)
+	}
+}
+
+func TestProcessClip_PlansSegmentsAndPackagesTheCut(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	mockClipSource(mockBucket)
+
+	// 25s to 42s lies in the third to fifth segments; the clip takes the 1080p video and
+	// the surround audio, named and flagged like the source track
+	plan := models.ClipPlan{
+		Video: models.ClipInput{
+			Keys:  []string{"videos/ep123/1080p/seg002.ts", "videos/ep123/1080p/seg003.ts", "videos/ep123/1080p/seg004.ts"},
+			Start: 20,
+			End:   50,
+		},
+		Audio: []models.ClipInput{{
+			Keys:  []string{"videos/ep123/audio/0-surround/seg002.ts", "videos/ep123/audio/0-surround/seg003.ts", "videos/ep123/audio/0-surround/seg004.ts"},
+			Start: 20,
+			End:   50,
+			Track: models.AudioTrack{Index: 0, Language: "por", Name: "Português", Default: true, Channels: 6},
+		}},
+		In:   25,
+		Out:  42,
+		Mode: models.ClipModeReencode,
+	}
+	mockVideo.On("ExtractClip", mock.Anything, plan, "test-bucket-2", "clips/trailer1.mkv").
+		Return(&models.ClipCut{Mode: models.ClipModeReencode, In: 25, Out: 42}, nil)
+
+	// the staged cut then goes through the upload pipeline
+	mockVideo.On("Probe", mock.Anything, "test-bucket-2", "clips/trailer1.mkv").
+		Return(nil, errors.New("probe failed"))
+
+	// only a job that got as far as cutting cleans up, and only the target it created
+	mockBucket.On("ListObjects", "test-bucket-2", "videos/trailer1/manifest.json").Return([]models.ObjectInfo{}, nil)
+	mockBucket.On("ListObjects", "test-bucket-2", "videos/ep456/manifest.json").
+		Return([]models.ObjectInfo{{Key: "videos/ep456/manifest.json"}}, nil)
+	mockBucket.On("DeleteObject", "test-bucket-2", "clips/trailer1.mkv").Return(nil)
+	mockBucket.On("DeletePrefix", "test-bucket-2", "videos/trailer1/").Return(nil)
+
+	// the target is claimed for the length of the job; a second request for it loses the claim
+	mockBucket.On("CreateObject", "test-bucket-2", "clips/trailer1.claim", mock.Anything).Return(nil).Once()
+	mockBucket.On("DeleteObject", "test-bucket-2", "clips/trailer1.claim").Return(nil).Once()
+	mockBucket.On("ListObjects", "test-bucket-2", "videos/trailer2/manifest.json").Return([]models.ObjectInfo{}, nil)
+	mockBucket.On("CreateObject", "test-bucket-2", "clips/trailer2.claim", mock.Anything).Return(models.ErrObjectExists)
+
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
+
+	_, err := processor.ProcessClip(models.ClipRequestEvent{SourceEpId: "ep123", EpId: "trailer1", In: 25, Out: 42})
+	assert.ErrorContains(t, err, "probe failed")
+	mockVideo.AssertExpectations(t)
+	mockBucket.AssertCalled(t, "DeletePrefix", "test-bucket-2", "videos/trailer1/")
+
+	_, err = processor.ProcessClip(models.ClipRequestEvent{SourceEpId: "ep123", EpId: "ep123", In: 25})
+	assert.ErrorContains(t, err, "corte inválido")
+
+	_, err = processor.ProcessClip(models.ClipRequestEvent{SourceEpId: "ep123", EpId: "ep456", In: 25})
+	assert.ErrorContains(t, err, "já existe")
+
+	_, err = processor.ProcessClip(models.ClipRequestEvent{SourceEpId: "ep123", EpId: "trailer2", In: 25})
+	assert.ErrorContains(t, err, "já está sendo criado")
+
+	mockVideo.AssertNumberOfCalls(t, "ExtractClip", 1)
+	mockBucket.AssertNumberOfCalls(t, "DeletePrefix", 1)
+	mockBucket.AssertNotCalled(t, "DeleteObject", "test-bucket-2", "clips/trailer2.claim")
+}
+
+func TestProcessClip_CopyModeSnapsToSegmentsAndCopiesTheRendition(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	mockClipSource(mockBucket)
+	mockBucket.On("ListObjects", "test-bucket-2", "videos/trailer1/manifest.json").Return([]models.ObjectInfo{}, nil)
+	mockBucket.On("CreateObject", "test-bucket-2", "clips/trailer1.claim", mock.Anything).Return(nil)
+	mockBucket.On("DeleteObject", "test-bucket-2", mock.Anything).Return(nil)
+	mockBucket.On("DeletePrefix", "test-bucket-2", "videos/trailer1/").Return(nil)
+
+	// 25s to 42s widens to the segments around it, 20s to 50s
+	snapped := mock.MatchedBy(func(plan models.ClipPlan) bool {
+		return plan.Mode == models.ClipModeCopy && plan.In == 20 && plan.Out == 50 &&
+			plan.Video.Keys[0] == "videos/ep123/1080p/seg002.ts"
+	})
+	mockVideo.On("ExtractClip", mock.Anything, snapped, "test-bucket-2", "clips/trailer1.mkv").
+		Return(&models.ClipCut{Mode: models.ClipModeCopy, In: 20, Out: 50}, nil)
+
+	mockVideo.On("Probe", mock.Anything, "test-bucket-2", "clips/trailer1.mkv").
+		Return(&models.MediaInfo{VideoCodec: models.CodecH264, Width: 1920, Height: 1080}, nil)
+	mockBucket.On("ListObjects", "test-bucket-2", "clips/trailer1.mkv.").Return(nil, nil)
+
+	// the one rendition is copied, not encoded again
+	mockVideo.On("Process", mock.Anything, mock.Anything, models.Rung{Height: 1080, Codec: models.CodecH264, Copy: true}).
+		Return(nil, errors.New("copy failed")).Once()
+	mockVideo.On("GenerateThumbnails", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.ThumbnailAssets{}, nil).Maybe()
+	mockVideo.On("GenerateStills", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, nil).Maybe()
+
+	cfg := *configMock
+	cfg.CropDetection = true
+	cfg.PerTitleEncoding = true
+	processor := app.NewProcessor(&cfg, nil, mockBucket, mockVideo, nil, cfg.BucketProcessedName)
+
+	_, err := processor.ProcessClip(models.ClipRequestEvent{SourceEpId: "ep123", EpId: "trailer1", In: 25, Out: 42, Mode: models.ClipModeCopy})
+	assert.ErrorContains(t, err, "copy failed")
+	mockVideo.AssertExpectations(t)
+	mockVideo.AssertNotCalled(t, "DetectCrop", mock.Anything, mock.Anything, mock.Anything)
+	mockVideo.AssertNotCalled(t, "AnalyzeComplexity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadDashManifest(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	job := &models.Job{
+		Event:     models.UploadEvent{Key: "video.mp4", EpId: "ep123", Bucket: "test-bucket"},
+		Packaging: models.Packaging{SegmentType: models.SegmentTypeFMP4, Dash: true},
+	}
+	renditions := []models.Rendition{{
+		Width: 1280, Height: 720, Codecs: "avc1.64001f", PeakBitrate: 3000000,
+		PlaylistKey: "videos/ep123/720p/index.m3u8",
+		InitKey:     "videos/ep123/720p/init.mp4",
+		Segments:    []models.Segment{{Name: "seg000.m4s", Duration: 10}, {Name: "seg001.m4s", Duration: 10}, {Name: "seg002.m4s", Duration: 4.5}},
+	}, {
+		Width: 1280, Height: 720, VideoCodec: models.CodecHEVC, Codecs: "hvc1.1.6.L93.B0", PeakBitrate: 1800000,
+		PlaylistKey: "videos/ep123/720p-hevc/index.m3u8",
+		InitKey:     "videos/ep123/720p-hevc/init.mp4",
+		Segments:    []models.Segment{{Name: "seg000.m4s", Duration: 24.5}},
+	}}
+	audio := []models.AudioRendition{{
+		AudioTrack: models.AudioTrack{Index: 0, Language: "por", Name: "Português", Default: true},
+		Rendition: models.Rendition{
+			Codecs: "mp4a.40.2", PeakBitrate: 130000,
+			PlaylistKey: "videos/ep123/audio/0/index.m3u8",
+			InitKey:     "videos/ep123/audio/0/init.mp4",
+			Segments:    []models.Segment{{Name: "seg000.m4s", Duration: 24.5}},
+		},
+	}}
+	subtitles := []models.SubtitleRendition{{
+		SubtitleTrack: models.SubtitleTrack{Index: 0, Language: "en", Name: "en"},
+		PlaylistKey:   "videos/ep123/subtitles/0/index.m3u8",
+		FileKey:       "videos/ep123/subtitles/0/full.vtt",
+	}}
+
+	mockBucket.On("UploadFileReader", "test-bucket-2", "videos/ep123/manifest.mpd", mock.Anything).
+		Run(func(args mock.Arguments) {
+			buf := new(bytes.Buffer)
+			_, _ = buf.ReadFrom(args.Get(2).(io.Reader))
+			content := buf.String()
+			assert.Contains(t, content, `mediaPresentationDuration="PT24.500S"`)
+			assert.Contains(t, content, `<Representation id="720p" bandwidth="3000000" codecs="avc1.64001f" width="1280" height="720">`)
+			assert.Contains(t, content, `<BaseURL>720p/</BaseURL>`)
+			assert.Contains(t, content, `<SegmentTemplate timescale="1000" initialization="init.mp4" media="seg$Number%03d$.m4s" startNumber="0">`)
+			assert.Contains(t, content, `<S t="0" d="10000" r="1"></S>`)
+			assert.Contains(t, content, `<S d="4500"></S>`)
+			assert.Contains(t, content, `<AdaptationSet id="1" contentType="video" mimeType="video/mp4"`)
+			assert.Contains(t, content, `<Representation id="720p-hevc" bandwidth="1800000" codecs="hvc1.1.6.L93.B0" width="1280" height="720">`)
+			assert.Contains(t, content, `<AdaptationSet id="2" contentType="audio" mimeType="audio/mp4" lang="por"`)
+			assert.Contains(t, content, `<BaseURL>subtitles/0/full.vtt</BaseURL>`)
+		}).Return(nil)
+
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
+	err := processor.UploadDashManifest(job, 24.5, renditions, audio, subtitles)
+
+	assert.NoError(t, err)
+	mockBucket.AssertExpectations(t)
+}
+
+func TestUploadDashManifest_ClearKey(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	kid := []byte{0x10, 0x77, 0xef, 0xec, 0xc0, 0xb2, 0x4d, 0x02, 0xac, 0xe3, 0x3c, 0x1e, 0x52, 0xe2, 0xfb, 0x4b}
+	job := &models.Job{
+		Event:     models.UploadEvent{Key: "video.mp4", EpId: "ep123", Bucket: "test-bucket"},
+		Packaging: models.Packaging{SegmentType: models.SegmentTypeFMP4, Dash: true, Encryption: models.EncryptionCENC},
+		Encryption: &models.Encryption{
+			ContentKey: models.ContentKey{KID: kid, Key: make([]byte, 16)},
+			Scheme:     models.EncryptionCENC,
+			LicenseURL: "https://keys.test/keys/ep123/license",
+		},
+	}
+	renditions := []models.Rendition{{
+		Width: 1280, Height: 720, Codecs: "avc1.64001f", PeakBitrate: 3000000,
+		PlaylistKey: "videos/ep123/720p/index.m3u8",
+		InitKey:     "videos/ep123/720p/init.mp4",
+		Segments:    []models.Segment{{Name: "seg000.m4s", Duration: 10}},
+	}}
+
+	mockBucket.On("UploadFileReader", "test-bucket-2", "videos/ep123/manifest.mpd", mock.Anything).
+		Run(func(args mock.Arguments) {
+			buf := new(bytes.Buffer)
+			_, _ = buf.ReadFrom(args.Get(2).(io.Reader))
+			content := buf.String()
+			assert.Contains(t, content, `xmlns:cenc="urn:mpeg:cenc:2013"`)
+			assert.Contains(t, content, `<ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc" cenc:default_KID="1077efec-c0b2-4d02-ace3-3c1e52e2fb4b">`)
+			assert.Contains(t, content, `<ContentProtection schemeIdUri="urn:uuid:e2719d58-a985-b3c9-781a-b030af78d30e" value="ClearKey1.0">`)
+			assert.Contains(t, content, `<dashif:Laurl>https://keys.test/keys/ep123/license</dashif:Laurl>`)
+			assert.Contains(t, content, `<cenc:pssh>AAAANHBzc2gBAAAAEHfv7MCyTQKs4zweUuL7SwAAAAEQd+/swLJNAqzjPB5S4vtLAAAAAA==</cenc:pssh>`)
+		}).Return(nil)
+
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
+	err := processor.UploadDashManifest(job, 10, renditions, nil, nil)
+
+	assert.NoError(t, err)
+	mockBucket.AssertExpectations(t)
+}
+
+func TestUploadDashManifest_HDRAdaptationSet(t *testing.T) {
+	mockBucket := new(mocks.MockBucket)
+	mockVideo := new(mocks.MockVideo)
+
+	job := &models.Job{
+		Event:     models.UploadEvent{Key: "video.mp4", EpId: "ep123", Bucket: "test-bucket"},
+		Packaging: models.Packaging{SegmentType: models.SegmentTypeFMP4, Dash: true},
+	}
+	renditions := []models.Rendition{{
+		Width: 1280, Height: 720, VideoCodec: models.CodecHEVC, VideoRange: models.VideoRangeSDR, Codecs: "hvc1.1.6.L93.B0", PeakBitrate: 1800000,
+		PlaylistKey: "videos/ep123/720p-hevc/index.m3u8",
+		InitKey:     "videos/ep123/720p-hevc/init.mp4",
+		Segments:    []models.Segment{{Name: "seg000.m4s", Duration: 10}},
+	}, {
+		Width: 1280, Height: 720, VideoCodec: models.CodecHEVC, VideoRange: models.VideoRangePQ, Codecs: "hvc1.2.4.L93.B0", PeakBitrate: 2200000,
+		PlaylistKey: "videos/ep123/720p-hevc-hdr/index.m3u8",
+		InitKey:     "videos/ep123/720p-hevc-hdr/init.mp4",
+		Segments:    []models.Segment{{Name: "seg000.m4s", Duration: 10}},
+	}}
+
+	mockBucket.On("UploadFileReader", "test-bucket-2", "videos/ep123/manifest.mpd", mock.Anything).
+		Run(func(args mock.Arguments) {
+			buf := new(bytes.Buffer)
+			_, _ = buf.ReadFrom(args.Get(2).(io.Reader))
+			content := buf.String()
+			assert.Contains(t, content, `<AdaptationSet id="1" contentType="video" mimeType="video/mp4" segmentAlignment="true" startWithSAP="1">
+      <EssentialProperty schemeIdUri="urn:mpeg:mpegB:cicp:ColourPrimaries" value="9"></EssentialProperty>`)
+			assert.Contains(t, content, `<EssentialProperty schemeIdUri="urn:mpeg:mpegB:cicp:TransferCharacteristics" value="16"></EssentialProperty>`)
+			assert.Equal(t, 3, strings.Count(content, "<EssentialProperty"))
 		}).Return(nil)
 
-	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, configMock.BucketProcessedName)
-	err := processor.UploadMasterPlaylist(event, resolutions)
+	processor := app.NewProcessor(configMock, nil, mockBucket, mockVideo, nil, configMock.BucketProcessedName)
+	err := processor.UploadDashManifest(job, 10, renditions, nil, nil)
 
 	assert.NoError(t, err)
 	mockBucket.AssertExpectations(t)
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"process-video-service/internal/models"
+)
+
+type qcOptions struct {
+	enabled bool
+	// deinterlace is the filter used on interlaced sources, or off
+	deinterlace string
+	// interlaceThreshold is the share of idet-decided frames that must be interlaced
+	interlaceThreshold float64
+	// a zero maximum disables the check
+	maxBlackRatio   float64
+	maxSilenceRatio float64
+}
+
+// CheckSource runs the QC pass over the source, picks a deinterlacer for the job when
+// the source is interlaced and fails sources that are mostly black or silent.
+func (p *Processor) CheckSource(ctx context.Context, job *models.Job) (*models.QCReport, error) {
+	report, err := p.video.AnalyzeQC(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	duration := job.Info.Duration
+
+	if duration > 0 {
+		report.BlackRatio = coveredRatio(report.Black, duration)
+		report.SilenceRatio = coveredRatio(report.Silence, duration)
+	}
+
+	il := &report.Interlace
+	if decided := il.TFF + il.BFF + il.Progressive; decided > 0 {
+		il.Interlaced = float64(il.TFF+il.BFF)/float64(decided) >= p.qc.interlaceThreshold
+	}
+	if il.Interlaced {
+		if p.qc.deinterlace != models.DeinterlaceNone {
+			job.Deinterlace = p.qc.deinterlace
+			report.Deinterlace = p.qc.deinterlace
+		} else {
+			report.Warnings = append(report.Warnings, "interlaced source published without deinterlacing")
+		}
+	}
+
+	if len(job.Info.AudioTracks) == 0 {
+		report.Warnings = append(report.Warnings, "source has no audio")
+	}
+	for _, freeze := range report.Freeze {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("video frozen for %.1fs at %.1fs", freeze.Duration, freeze.Start))
+	}
+
+	if p.qc.maxBlackRatio > 0 && report.BlackRatio > p.qc.maxBlackRatio {
+		return nil, fmt.Errorf("fonte quase toda preta: %.0f%% do vídeo", report.BlackRatio*100)
+	}
+	if p.qc.maxSilenceRatio > 0 && len(job.Info.AudioTracks) > 0 && report.SilenceRatio > p.qc.maxSilenceRatio {
+		return nil, fmt.Errorf("fonte quase toda em silêncio: %.0f%% do áudio", report.SilenceRatio*100)
+	}
+	return report, nil
+}
+
+func coveredRatio(intervals []models.QCInterval, duration float64) float64 {
+	var covered float64
+	for _, in := range intervals {
+		covered += in.Duration
+	}
+	return min(covered/duration, 1)
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	helpers "process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+)
+
+// ErrQualityBelowThreshold marks jobs whose renditions scored under the configured
+// minimums; like a failed verification, the source is kept for another attempt.
+var ErrQualityBelowThreshold = errors.New("qualidade abaixo do mínimo")
+
+type qualityOptions struct {
+	enabled bool
+	models.QualityOptions
+	// a zero minimum disables the check for that metric
+	minPSNR float64
+	minSSIM float64
+	minVMAF float64
+	// failBelow fails the job instead of only flagging the rendition
+	failBelow bool
+}
+
+// MeasureQuality scores every video rendition against the source, stores the scores on
+// the rendition and flags the ones below the configured minimums.
+func (p *Processor) MeasureQuality(ctx context.Context, job *models.Job, renditions []models.Rendition) error {
+	group, ctx := errgroup.WithContext(ctx)
+	for i := range renditions {
+		group.Go(func() error {
+			scores, err := p.video.MeasureQuality(ctx, job, renditions[i], p.quality.QualityOptions)
+			if err != nil {
+				return fmt.Errorf("falha qualidade %s: %w", renditionLabel(renditions[i]), err)
+			}
+			scores.Reasons = p.quality.below(scores)
+			scores.Flagged = len(scores.Reasons) > 0
+			renditions[i].Quality = scores
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, r := range renditions {
+		q := r.Quality
+		p.logger.Infof("Quality: episodeId=%s rendition=%s psnr=%.2f ssim=%.4f vmaf=%.2f flagged=%t",
+			job.Event.EpId, renditionLabel(r), q.PSNR, q.SSIM, q.VMAF, q.Flagged)
+		if q.Flagged {
+			failures = append(failures, fmt.Sprintf("%s (%s)", renditionLabel(r), strings.Join(q.Reasons, ", ")))
+		}
+	}
+	if len(failures) > 0 && p.quality.failBelow {
+		return fmt.Errorf("%w: %s", ErrQualityBelowThreshold, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// below lists the metrics under their minimum; VMAF is only checked when it was computed.
+func (o qualityOptions) below(scores *models.QualityScores) []string {
+	var reasons []string
+	if o.minPSNR > 0 && scores.PSNR < o.minPSNR {
+		reasons = append(reasons, fmt.Sprintf("psnr %.2f < %g", scores.PSNR, o.minPSNR))
+	}
+	if o.minSSIM > 0 && scores.SSIM < o.minSSIM {
+		reasons = append(reasons, fmt.Sprintf("ssim %.4f < %g", scores.SSIM, o.minSSIM))
+	}
+	if o.minVMAF > 0 && scores.VMAF > 0 && scores.VMAF < o.minVMAF {
+		reasons = append(reasons, fmt.Sprintf("vmaf %.2f < %g", scores.VMAF, o.minVMAF))
+	}
+	return reasons
+}
+
+func renditionLabel(r models.Rendition) string {
+	hdr := r.VideoRange == models.VideoRangePQ || r.VideoRange == models.VideoRangeHLG
+	return helpers.RenditionName(models.Rung{Height: r.Height, Codec: r.VideoCodec, HDR: hdr})
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"path"
+
+	"process-video-service/internal/hls"
+	"process-video-service/internal/models"
+)
+
+// ErrVerificationFailed marks jobs whose outputs were written but didn't check out;
+// the source is kept so the upload can be reprocessed.
+var ErrVerificationFailed = errors.New("verificação das saídas falhou")
+
+type verifyOptions struct {
+	enabled           bool
+	durationTolerance float64
+	sampleSegments    int
+}
+
+// segmentRef is a published media segment that can be decoded on its own (with its init segment).
+type segmentRef struct {
+	key     string
+	initKey string
+}
+
+// VerifyOutputs re-reads the published master playlist and every playlist it references
+// from the bucket. Each segment must exist with a non-zero size (and hold its byte ranges),
+// each audio/video playlist must add up to the source duration, and a random sample of
+// segments must decode.
+func (p *Processor) VerifyOutputs(ctx context.Context, job *models.Job) error {
+	prefix := fmt.Sprintf("videos/%s/", job.Event.EpId)
+	masterKey := prefix + "master.m3u8"
+
+	objects, err := p.bucket.ListObjects(p.processBucketName, prefix)
+	if err != nil {
+		return fmt.Errorf("erro ao listar saídas: %w", err)
+	}
+	sizes := make(map[string]int64, len(objects))
+	for _, obj := range objects {
+		sizes[obj.Key] = obj.Size
+	}
+
+	master, err := p.readMaster(masterKey)
+	if err != nil {
+		return err
+	}
+
+	type playlistRef struct {
+		uri          string
+		checkLength  bool
+		decodeSample bool
+	}
+	var refs []playlistRef
+	for _, m := range master.Media {
+		// a subtitle playlist only runs to its last cue
+		audio := m.Type == hls.MediaTypeAudio
+		refs = append(refs, playlistRef{uri: m.URI, checkLength: audio, decodeSample: audio})
+	}
+	for _, v := range master.Variants {
+		refs = append(refs, playlistRef{uri: v.URI, checkLength: true, decodeSample: true})
+	}
+	for _, v := range master.IFrameVariants {
+		refs = append(refs, playlistRef{uri: v.URI})
+	}
+
+	var candidates []segmentRef
+	var errs []error
+	for _, ref := range refs {
+		playlistKey, err := hls.ResolveURI(masterKey, ref.uri)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		playlist, err := p.readMedia(playlistKey)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		initKey := ""
+		if playlist.Map != nil {
+			initKey, err = checkObject(sizes, playlistKey, playlist.Map.URI, playlist.Map.ByteRange)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		total := 0.0
+		for _, s := range playlist.Segments {
+			key, err := checkObject(sizes, playlistKey, s.URI, s.ByteRange)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			total += s.Duration
+			if ref.decodeSample {
+				candidates = append(candidates, segmentRef{key: key, initKey: initKey})
+			}
+		}
+
+		// an estimated source duration says nothing about the outputs
+		expected := job.Info.Duration
+		if ref.checkLength && expected > 0 && !job.Info.DurationEstimated && math.Abs(total-expected) > p.verify.durationTolerance {
+			errs = append(errs, fmt.Errorf("%s dura %.3fs, a fonte %.3fs", playlistKey, total, expected))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, i := range rand.Perm(len(candidates))[:min(p.verify.sampleSegments, len(candidates))] {
+		segment := candidates[i]
+		if err := p.video.VerifySegment(ctx, job, segment.key, segment.initKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkObject(sizes map[string]int64, playlistKey, uri string, byteRange *hls.ByteRange) (string, error) {
+	key, err := hls.ResolveURI(playlistKey, uri)
+	if err != nil {
+		return "", err
+	}
+	size, ok := sizes[key]
+	if !ok {
+		return "", fmt.Errorf("%s referencia %s, que não existe", path.Base(playlistKey), key)
+	}
+	if size == 0 {
+		return "", fmt.Errorf("%s está vazio", key)
+	}
+	if byteRange != nil && byteRange.Offset+byteRange.Length > size {
+		return "", fmt.Errorf("%s: byte range %s além do fim (%d bytes)", key, byteRange, size)
+	}
+	return key, nil
+}
+
+func (p *Processor) readMaster(key string) (*hls.MasterPlaylist, error) {
+	stream, err := p.bucket.GetObjectStream(p.processBucketName, key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler %s: %w", key, err)
+	}
+	defer stream.Close()
+	playlist, err := hls.ParseMaster(stream)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	if err := playlist.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return playlist, nil
+}
+
+func (p *Processor) readMedia(key string) (*hls.MediaPlaylist, error) {
+	stream, err := p.bucket.GetObjectStream(p.processBucketName, key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler %s: %w", key, err)
+	}
+	defer stream.Close()
+	playlist, err := hls.ParseMedia(stream)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	if err := playlist.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return playlist, nil
+}
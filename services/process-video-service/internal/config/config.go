@@ -3,22 +3,87 @@ package config
 import (
 	"fmt"
 
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	RabbitMQUrl           string `mapstructure:"RABBITMQ_URL"`
-	ProcessedVideoQueue   string `mapstructure:"PROCESSED_VIDEO_QUEUE_NAME"`
-	UploadVideoQueue      string `mapstructure:"UPLOAD_QUEUE_NAME"`
-	FailProcessVideoQueue string `mapstructure:"FAILED_PROCESSED_VIDEO_QUEUE_NAME"`
-	BucketURL             string `mapstructure:"BUCKET_URL"`
-	BucketKey             string `mapstructure:"BUCKET_ACCESS_KEY"`
-	BucketSecret          string `mapstructure:"BUCKET_ACCESS_PASSWORD"`
-	BucketRawName         string `mapstructure:"BUCKET_RAW_NAME"`
-	BucketProcessedName   string `mapstructure:"BUCKET_PROCESSED_NAME"`
-	EnableGPUProcess      bool   `mapstructure:"ENABLE_GPU_PROCESS"`
-	EnableGPUScaleNPP     bool   `mapstructure:"ENABLE_GPU_SCALE_NPP"`
-	Port                  string `mapstructure:"PORT"`
+	RabbitMQUrl               string   `mapstructure:"RABBITMQ_URL"`
+	ProcessedVideoQueue       string   `mapstructure:"PROCESSED_VIDEO_QUEUE_NAME"`
+	UploadVideoQueue          string   `mapstructure:"UPLOAD_QUEUE_NAME"`
+	FailProcessVideoQueue     string   `mapstructure:"FAILED_PROCESSED_VIDEO_QUEUE_NAME"`
+	ClipQueue                 string   `mapstructure:"CLIP_QUEUE_NAME"`
+	MarkersUpdatedQueue       string   `mapstructure:"MARKERS_UPDATED_QUEUE_NAME"`
+	BucketURL                 string   `mapstructure:"BUCKET_URL"`
+	BucketKey                 string   `mapstructure:"BUCKET_ACCESS_KEY"`
+	BucketSecret              string   `mapstructure:"BUCKET_ACCESS_PASSWORD"`
+	BucketRawName             string   `mapstructure:"BUCKET_RAW_NAME"`
+	BucketProcessedName       string   `mapstructure:"BUCKET_PROCESSED_NAME"`
+	EnableGPUProcess          bool     `mapstructure:"ENABLE_GPU_PROCESS"`
+	EnableGPUScaleNPP         bool     `mapstructure:"ENABLE_GPU_SCALE_NPP"`
+	Port                      string   `mapstructure:"PORT"`
+	ThumbnailInterval         float64  `mapstructure:"THUMBNAIL_INTERVAL"`
+	ThumbnailWidth            int      `mapstructure:"THUMBNAIL_WIDTH"`
+	ThumbnailColumns          int      `mapstructure:"THUMBNAIL_COLUMNS"`
+	ThumbnailRows             int      `mapstructure:"THUMBNAIL_ROWS"`
+	ThumbnailFormat           string   `mapstructure:"THUMBNAIL_FORMAT"`
+	StillSamples              int      `mapstructure:"STILL_SAMPLES"`
+	StillCandidates           int      `mapstructure:"STILL_CANDIDATES"`
+	StillWidths               []int    `mapstructure:"STILL_WIDTHS"`
+	HLSSegmentType            string   `mapstructure:"HLS_SEGMENT_TYPE"`
+	EnableDash                bool     `mapstructure:"ENABLE_DASH"`
+	VideoCodecs               []string `mapstructure:"VIDEO_CODECS"`
+	HLSEncryption             string   `mapstructure:"HLS_ENCRYPTION"`
+	KeyStore                  string   `mapstructure:"KEY_STORE"`
+	KeyStorePath              string   `mapstructure:"KEY_STORE_PATH"`
+	KeyStoreDatabaseURL       string   `mapstructure:"KEY_STORE_DATABASE_URL"`
+	KeyServerURL              string   `mapstructure:"KEY_SERVER_URL"`
+	PlaybackTokenSecret       string   `mapstructure:"PLAYBACK_TOKEN_SECRET"`
+	IFramePlaylistHeights     []int    `mapstructure:"IFRAME_PLAYLIST_HEIGHTS"`
+	VerifyOutputs             bool     `mapstructure:"VERIFY_OUTPUTS"`
+	VerifyDurationTolerance   float64  `mapstructure:"VERIFY_DURATION_TOLERANCE"`
+	VerifySampleSegments      int      `mapstructure:"VERIFY_SAMPLE_SEGMENTS"`
+	PerTitleEncoding          bool     `mapstructure:"PER_TITLE_ENCODING"`
+	PerTitleSamples           int      `mapstructure:"PER_TITLE_SAMPLES"`
+	PerTitleSampleSeconds     float64  `mapstructure:"PER_TITLE_SAMPLE_SECONDS"`
+	PerTitleCRF               int      `mapstructure:"PER_TITLE_CRF"`
+	QualityMetrics            bool     `mapstructure:"QUALITY_METRICS"`
+	QualityVMAF               bool     `mapstructure:"QUALITY_VMAF"`
+	QualityMinPSNR            float64  `mapstructure:"QUALITY_MIN_PSNR"`
+	QualityMinSSIM            float64  `mapstructure:"QUALITY_MIN_SSIM"`
+	QualityMinVMAF            float64  `mapstructure:"QUALITY_MIN_VMAF"`
+	QualityFailBelowThreshold bool     `mapstructure:"QUALITY_FAIL_BELOW_THRESHOLD"`
+	QCAnalysis                bool     `mapstructure:"QC_ANALYSIS"`
+	QCDeinterlace             string   `mapstructure:"QC_DEINTERLACE"`
+	QCInterlaceThreshold      float64  `mapstructure:"QC_INTERLACE_THRESHOLD"`
+	QCMaxBlackRatio           float64  `mapstructure:"QC_MAX_BLACK_RATIO"`
+	QCMaxSilenceRatio         float64  `mapstructure:"QC_MAX_SILENCE_RATIO"`
+	CropDetection             bool     `mapstructure:"CROP_DETECTION"`
+	CropSamples               int      `mapstructure:"CROP_SAMPLES"`
+	CropSampleSeconds         float64  `mapstructure:"CROP_SAMPLE_SECONDS"`
+	CropMinConfidence         float64  `mapstructure:"CROP_MIN_CONFIDENCE"`
+	HDRLadder                 bool     `mapstructure:"HDR_LADDER"`
+	Loudnorm                  bool     `mapstructure:"LOUDNORM"`
+	LoudnormIntegrated        float64  `mapstructure:"LOUDNORM_INTEGRATED"`
+	LoudnormTruePeak          float64  `mapstructure:"LOUDNORM_TRUE_PEAK"`
+	LoudnormRange             float64  `mapstructure:"LOUDNORM_RANGE"`
+	SurroundAudioCodec        string   `mapstructure:"SURROUND_AUDIO_CODEC"`
+	Markers                   bool     `mapstructure:"MARKERS"`
+	MarkersIntroSeconds       float64  `mapstructure:"MARKERS_INTRO_SECONDS"`
+	MarkersMinIntro           float64  `mapstructure:"MARKERS_MIN_INTRO"`
+	MarkersMaxIntro           float64  `mapstructure:"MARKERS_MAX_INTRO"`
+	MarkersCreditsSeconds     float64  `mapstructure:"MARKERS_CREDITS_SECONDS"`
+	MarkersMinCredits         float64  `mapstructure:"MARKERS_MIN_CREDITS"`
+	DownloadHeights           []int    `mapstructure:"DOWNLOAD_HEIGHTS"`
+	Preview                   bool     `mapstructure:"PREVIEW"`
+	PreviewSeconds            float64  `mapstructure:"PREVIEW_SECONDS"`
+	PreviewWidths             []int    `mapstructure:"PREVIEW_WIDTHS"`
+	Chapters                  bool     `mapstructure:"CHAPTERS"`
+	ChapterMinSeconds         float64  `mapstructure:"CHAPTER_MIN_SECONDS"`
+	ChapterSceneThreshold     float64  `mapstructure:"CHAPTER_SCENE_THRESHOLD"`
+	ChapterThumbnailWidth     int      `mapstructure:"CHAPTER_THUMBNAIL_WIDTH"`
 }
 
 func LoadEnv(path string) (*Config, error) {
@@ -29,6 +94,63 @@ func LoadEnv(path string) (*Config, error) {
 
 	viper.SetDefault("ENABLE_GPU_PROCESS", false)
 	viper.SetDefault("ENABLE_GPU_SCALE_NPP", false)
+	viper.SetDefault("THUMBNAIL_INTERVAL", 10)
+	viper.SetDefault("THUMBNAIL_WIDTH", 160)
+	viper.SetDefault("THUMBNAIL_COLUMNS", 10)
+	viper.SetDefault("THUMBNAIL_ROWS", 10)
+	viper.SetDefault("THUMBNAIL_FORMAT", "jpg")
+	viper.SetDefault("STILL_SAMPLES", 40)
+	viper.SetDefault("STILL_CANDIDATES", 3)
+	viper.SetDefault("STILL_WIDTHS", "1280,640,320")
+	viper.SetDefault("HLS_SEGMENT_TYPE", "mpegts")
+	viper.SetDefault("ENABLE_DASH", false)
+	viper.SetDefault("VIDEO_CODECS", "h264")
+	viper.SetDefault("HLS_ENCRYPTION", "none")
+	viper.SetDefault("KEY_STORE", "file")
+	viper.SetDefault("KEY_STORE_PATH", "./keys")
+	viper.SetDefault("IFRAME_PLAYLIST_HEIGHTS", "480")
+	viper.SetDefault("VERIFY_OUTPUTS", true)
+	viper.SetDefault("VERIFY_DURATION_TOLERANCE", 1.5)
+	viper.SetDefault("VERIFY_SAMPLE_SEGMENTS", 3)
+	viper.SetDefault("PER_TITLE_ENCODING", false)
+	viper.SetDefault("PER_TITLE_SAMPLES", 6)
+	viper.SetDefault("PER_TITLE_SAMPLE_SECONDS", 4)
+	viper.SetDefault("PER_TITLE_CRF", 23)
+	viper.SetDefault("QUALITY_METRICS", false)
+	viper.SetDefault("QUALITY_VMAF", true)
+	viper.SetDefault("QUALITY_MIN_PSNR", 0)
+	viper.SetDefault("QUALITY_MIN_SSIM", 0)
+	viper.SetDefault("QUALITY_MIN_VMAF", 0)
+	viper.SetDefault("QUALITY_FAIL_BELOW_THRESHOLD", false)
+	viper.SetDefault("QC_ANALYSIS", false)
+	viper.SetDefault("QC_DEINTERLACE", "bwdif")
+	viper.SetDefault("QC_INTERLACE_THRESHOLD", 0.3)
+	viper.SetDefault("QC_MAX_BLACK_RATIO", 0.9)
+	viper.SetDefault("QC_MAX_SILENCE_RATIO", 0.9)
+	viper.SetDefault("CROP_DETECTION", false)
+	viper.SetDefault("CROP_SAMPLES", 10)
+	viper.SetDefault("CROP_SAMPLE_SECONDS", 2)
+	viper.SetDefault("CROP_MIN_CONFIDENCE", 0.8)
+	viper.SetDefault("HDR_LADDER", false)
+	viper.SetDefault("LOUDNORM", false)
+	viper.SetDefault("LOUDNORM_INTEGRATED", -23)
+	viper.SetDefault("LOUDNORM_TRUE_PEAK", -1)
+	viper.SetDefault("LOUDNORM_RANGE", 7)
+	viper.SetDefault("SURROUND_AUDIO_CODEC", "eac3")
+	viper.SetDefault("MARKERS", false)
+	viper.SetDefault("MARKERS_INTRO_SECONDS", 600)
+	viper.SetDefault("MARKERS_MIN_INTRO", 15)
+	viper.SetDefault("MARKERS_MAX_INTRO", 120)
+	viper.SetDefault("MARKERS_CREDITS_SECONDS", 600)
+	viper.SetDefault("MARKERS_MIN_CREDITS", 20)
+	viper.SetDefault("DOWNLOAD_HEIGHTS", "")
+	viper.SetDefault("PREVIEW", false)
+	viper.SetDefault("PREVIEW_SECONDS", 6)
+	viper.SetDefault("PREVIEW_WIDTHS", "480,240")
+	viper.SetDefault("CHAPTERS", false)
+	viper.SetDefault("CHAPTER_MIN_SECONDS", 120)
+	viper.SetDefault("CHAPTER_SCENE_THRESHOLD", 0.4)
+	viper.SetDefault("CHAPTER_THUMBNAIL_WIDTH", 320)
 
 	if err := viper.ReadInConfig(); err != nil {
 		fmt.Println(".env not found, using sistem enviroment")
@@ -38,6 +160,8 @@ func LoadEnv(path string) (*Config, error) {
 	viper.BindEnv("PROCESSED_VIDEO_QUEUE_NAME")
 	viper.BindEnv("UPLOAD_QUEUE_NAME")
 	viper.BindEnv("FAILED_PROCESSED_VIDEO_QUEUE_NAME")
+	viper.BindEnv("CLIP_QUEUE_NAME")
+	viper.BindEnv("MARKERS_UPDATED_QUEUE_NAME")
 	viper.BindEnv("BUCKET_URL")
 	viper.BindEnv("BUCKET_ACCESS_KEY")
 	viper.BindEnv("BUCKET_ACCESS_PASSWORD")
@@ -46,11 +170,154 @@ func LoadEnv(path string) (*Config, error) {
 	viper.BindEnv("ENABLE_GPU_PROCESS")
 	viper.BindEnv("ENABLE_GPU_SCALE_NPP")
 	viper.BindEnv("PORT")
+	viper.BindEnv("THUMBNAIL_INTERVAL")
+	viper.BindEnv("THUMBNAIL_WIDTH")
+	viper.BindEnv("THUMBNAIL_COLUMNS")
+	viper.BindEnv("THUMBNAIL_ROWS")
+	viper.BindEnv("THUMBNAIL_FORMAT")
+	viper.BindEnv("STILL_SAMPLES")
+	viper.BindEnv("STILL_CANDIDATES")
+	viper.BindEnv("STILL_WIDTHS")
+	viper.BindEnv("HLS_SEGMENT_TYPE")
+	viper.BindEnv("ENABLE_DASH")
+	viper.BindEnv("VIDEO_CODECS")
+	viper.BindEnv("HLS_ENCRYPTION")
+	viper.BindEnv("KEY_STORE")
+	viper.BindEnv("KEY_STORE_PATH")
+	viper.BindEnv("KEY_STORE_DATABASE_URL")
+	viper.BindEnv("KEY_SERVER_URL")
+	viper.BindEnv("PLAYBACK_TOKEN_SECRET")
+	viper.BindEnv("IFRAME_PLAYLIST_HEIGHTS")
+	viper.BindEnv("VERIFY_OUTPUTS")
+	viper.BindEnv("VERIFY_DURATION_TOLERANCE")
+	viper.BindEnv("VERIFY_SAMPLE_SEGMENTS")
+	viper.BindEnv("PER_TITLE_ENCODING")
+	viper.BindEnv("PER_TITLE_SAMPLES")
+	viper.BindEnv("PER_TITLE_SAMPLE_SECONDS")
+	viper.BindEnv("PER_TITLE_CRF")
+	viper.BindEnv("QUALITY_METRICS")
+	viper.BindEnv("QUALITY_VMAF")
+	viper.BindEnv("QUALITY_MIN_PSNR")
+	viper.BindEnv("QUALITY_MIN_SSIM")
+	viper.BindEnv("QUALITY_MIN_VMAF")
+	viper.BindEnv("QUALITY_FAIL_BELOW_THRESHOLD")
+	viper.BindEnv("QC_ANALYSIS")
+	viper.BindEnv("QC_DEINTERLACE")
+	viper.BindEnv("QC_INTERLACE_THRESHOLD")
+	viper.BindEnv("QC_MAX_BLACK_RATIO")
+	viper.BindEnv("QC_MAX_SILENCE_RATIO")
+	viper.BindEnv("CROP_DETECTION")
+	viper.BindEnv("CROP_SAMPLES")
+	viper.BindEnv("CROP_SAMPLE_SECONDS")
+	viper.BindEnv("CROP_MIN_CONFIDENCE")
+	viper.BindEnv("HDR_LADDER")
+	viper.BindEnv("LOUDNORM")
+	viper.BindEnv("LOUDNORM_INTEGRATED")
+	viper.BindEnv("LOUDNORM_TRUE_PEAK")
+	viper.BindEnv("LOUDNORM_RANGE")
+	viper.BindEnv("SURROUND_AUDIO_CODEC")
+	viper.BindEnv("MARKERS")
+	viper.BindEnv("MARKERS_INTRO_SECONDS")
+	viper.BindEnv("MARKERS_MIN_INTRO")
+	viper.BindEnv("MARKERS_MAX_INTRO")
+	viper.BindEnv("MARKERS_CREDITS_SECONDS")
+	viper.BindEnv("MARKERS_MIN_CREDITS")
+	viper.BindEnv("DOWNLOAD_HEIGHTS")
+	viper.BindEnv("PREVIEW")
+	viper.BindEnv("PREVIEW_SECONDS")
+	viper.BindEnv("PREVIEW_WIDTHS")
+	viper.BindEnv("CHAPTERS")
+	viper.BindEnv("CHAPTER_MIN_SECONDS")
+	viper.BindEnv("CHAPTER_SCENE_THRESHOLD")
+	viper.BindEnv("CHAPTER_THUMBNAIL_WIDTH")
 
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, err
 	}
 
+	if cfg.ThumbnailFormat != "jpg" && cfg.ThumbnailFormat != "webp" {
+		return nil, fmt.Errorf("THUMBNAIL_FORMAT must be jpg or webp, got %q", cfg.ThumbnailFormat)
+	}
+	if cfg.StillSamples <= 0 || cfg.StillCandidates <= 0 {
+		return nil, fmt.Errorf("STILL_SAMPLES and STILL_CANDIDATES must be positive")
+	}
+
+	packaging := models.Packaging{SegmentType: cfg.HLSSegmentType, Dash: cfg.EnableDash, Encryption: cfg.HLSEncryption}
+	if err := helpers.ValidatePackaging(packaging); err != nil {
+		return nil, fmt.Errorf("invalid HLS_SEGMENT_TYPE/ENABLE_DASH/HLS_ENCRYPTION: %w", err)
+	}
+	if err := helpers.ValidateCodecs(cfg.VideoCodecs, packaging); err != nil {
+		return nil, fmt.Errorf("invalid VIDEO_CODECS: %w", err)
+	}
+
+	if cfg.HDRLadder && cfg.HLSSegmentType != models.SegmentTypeFMP4 {
+		return nil, fmt.Errorf("HDR_LADDER requires HLS_SEGMENT_TYPE=%s", models.SegmentTypeFMP4)
+	}
+
+	switch cfg.SurroundAudioCodec {
+	case models.SurroundCodecNone, models.SurroundCodecAAC, models.SurroundCodecAC3, models.SurroundCodecEAC3:
+	default:
+		return nil, fmt.Errorf("SURROUND_AUDIO_CODEC must be none, aac, ac3 or eac3, got %q", cfg.SurroundAudioCodec)
+	}
+
+	// ranges accepted by ffmpeg's loudnorm filter
+	if cfg.Loudnorm {
+		if cfg.LoudnormIntegrated < -70 || cfg.LoudnormIntegrated > -5 {
+			return nil, fmt.Errorf("LOUDNORM_INTEGRATED must be between -70 and -5, got %g", cfg.LoudnormIntegrated)
+		}
+		if cfg.LoudnormTruePeak < -9 || cfg.LoudnormTruePeak > 0 {
+			return nil, fmt.Errorf("LOUDNORM_TRUE_PEAK must be between -9 and 0, got %g", cfg.LoudnormTruePeak)
+		}
+		if cfg.LoudnormRange < 1 || cfg.LoudnormRange > 50 {
+			return nil, fmt.Errorf("LOUDNORM_RANGE must be between 1 and 50, got %g", cfg.LoudnormRange)
+		}
+	}
+
+	switch cfg.KeyStore {
+	case "file":
+	case "postgres":
+		if cfg.KeyStoreDatabaseURL == "" {
+			return nil, fmt.Errorf("KEY_STORE_DATABASE_URL is required when KEY_STORE=postgres")
+		}
+	default:
+		return nil, fmt.Errorf("KEY_STORE must be file or postgres, got %q", cfg.KeyStore)
+	}
+	if cfg.HLSEncryption != models.EncryptionNone && (cfg.KeyServerURL == "" || cfg.PlaybackTokenSecret == "") {
+		return nil, fmt.Errorf("KEY_SERVER_URL and PLAYBACK_TOKEN_SECRET are required when HLS_ENCRYPTION=%s", cfg.HLSEncryption)
+	}
+
+	if cfg.PerTitleEncoding && (cfg.PerTitleSamples <= 0 || cfg.PerTitleSampleSeconds <= 0) {
+		return nil, fmt.Errorf("PER_TITLE_SAMPLES and PER_TITLE_SAMPLE_SECONDS must be positive")
+	}
+
+	if cfg.Markers {
+		if cfg.MarkersIntroSeconds <= 0 || cfg.MarkersCreditsSeconds <= 0 {
+			return nil, fmt.Errorf("MARKERS_INTRO_SECONDS and MARKERS_CREDITS_SECONDS must be positive")
+		}
+		if cfg.MarkersMinIntro <= 0 || cfg.MarkersMaxIntro < cfg.MarkersMinIntro {
+			return nil, fmt.Errorf("MARKERS_MIN_INTRO must be positive and at most MARKERS_MAX_INTRO")
+		}
+	}
+
+	if cfg.Preview && (cfg.PreviewSeconds <= 0 || len(cfg.PreviewWidths) == 0) {
+		return nil, fmt.Errorf("PREVIEW_SECONDS must be positive and PREVIEW_WIDTHS not empty")
+	}
+
+	if cfg.Chapters {
+		if cfg.ChapterMinSeconds <= 0 || cfg.ChapterThumbnailWidth <= 0 {
+			return nil, fmt.Errorf("CHAPTER_MIN_SECONDS and CHAPTER_THUMBNAIL_WIDTH must be positive")
+		}
+		if cfg.ChapterSceneThreshold <= 0 || cfg.ChapterSceneThreshold >= 1 {
+			return nil, fmt.Errorf("CHAPTER_SCENE_THRESHOLD must be between 0 and 1, got %g", cfg.ChapterSceneThreshold)
+		}
+	}
+
+	switch cfg.QCDeinterlace {
+	case models.DeinterlaceNone, models.DeinterlaceYadif, models.DeinterlaceBwdif:
+	default:
+		return nil, fmt.Errorf("QC_DEINTERLACE must be off, yadif or bwdif, got %q", cfg.QCDeinterlace)
+	}
+
 	return &cfg, nil
 }
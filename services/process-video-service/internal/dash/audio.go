@@ -0,0 +1,16 @@
+package dash
+
+const cicpChannelConfiguration = "urn:mpeg:mpegB:cicp:ChannelConfiguration"
+
+// ChannelConfiguration code points (ISO/IEC 23001-8)
+var cicpChannels = map[int]string{1: "1", 2: "2", 6: "6", 8: "12"}
+
+// AudioChannelConfiguration describes the layout of an audio representation; an
+// unknown channel count returns nothing.
+func AudioChannelConfiguration(channels int) []Descriptor {
+	value, ok := cicpChannels[channels]
+	if !ok {
+		return nil
+	}
+	return []Descriptor{{SchemeIDURI: cicpChannelConfiguration, Value: value}}
+}
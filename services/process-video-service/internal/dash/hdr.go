@@ -0,0 +1,25 @@
+package dash
+
+// CICP descriptors (ISO/IEC 23001-8) as DASH-IF IOP uses them to signal HDR.
+const (
+	cicpColourPrimaries         = "urn:mpeg:mpegB:cicp:ColourPrimaries"
+	cicpTransferCharacteristics = "urn:mpeg:mpegB:cicp:TransferCharacteristics"
+	cicpMatrixCoefficients      = "urn:mpeg:mpegB:cicp:MatrixCoefficients"
+)
+
+// HDRProperties describes a BT.2020 adaptation set with the PQ or HLG transfer. PQ is
+// essential because SDR players can't render it; HLG degrades gracefully, so its
+// transfer is only supplemental. Any other range returns nothing.
+func HDRProperties(videoRange string) (essential, supplemental []Descriptor) {
+	colour := []Descriptor{
+		{SchemeIDURI: cicpColourPrimaries, Value: "9"},
+		{SchemeIDURI: cicpMatrixCoefficients, Value: "9"},
+	}
+	switch videoRange {
+	case "PQ":
+		return append(colour, Descriptor{SchemeIDURI: cicpTransferCharacteristics, Value: "16"}), nil
+	case "HLG":
+		return colour, []Descriptor{{SchemeIDURI: cicpTransferCharacteristics, Value: "18"}}
+	}
+	return nil, nil
+}
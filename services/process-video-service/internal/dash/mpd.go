@@ -0,0 +1,134 @@
+package dash
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+)
+
+// MPD is the subset of ISO/IEC 23009-1 needed for a static presentation of CMAF
+// segments addressed with SegmentTemplate and SegmentTimeline.
+type MPD struct {
+	XMLName                   xml.Name `xml:"MPD"`
+	Xmlns                     string   `xml:"xmlns,attr"`
+	XmlnsCenc                 string   `xml:"xmlns:cenc,attr,omitempty"`
+	XmlnsDashif               string   `xml:"xmlns:dashif,attr,omitempty"`
+	Profiles                  string   `xml:"profiles,attr"`
+	Type                      string   `xml:"type,attr"`
+	MediaPresentationDuration string   `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string   `xml:"minBufferTime,attr"`
+	Periods                   []Period `xml:"Period"`
+}
+
+type Period struct {
+	ID             string          `xml:"id,attr"`
+	Start          string          `xml:"start,attr"`
+	AdaptationSets []AdaptationSet `xml:"AdaptationSet"`
+}
+
+type AdaptationSet struct {
+	ID                 int                 `xml:"id,attr"`
+	ContentType        string              `xml:"contentType,attr"`
+	MimeType           string              `xml:"mimeType,attr"`
+	Lang               string              `xml:"lang,attr,omitempty"`
+	SegmentAlignment   bool                `xml:"segmentAlignment,attr,omitempty"`
+	StartWithSAP       int                 `xml:"startWithSAP,attr,omitempty"`
+	ContentProtections []ContentProtection `xml:"ContentProtection,omitempty"`
+	EssentialProps     []Descriptor        `xml:"EssentialProperty,omitempty"`
+	SupplementalProps  []Descriptor        `xml:"SupplementalProperty,omitempty"`
+	Roles              []Descriptor        `xml:"Role,omitempty"`
+	Representations    []Representation    `xml:"Representation"`
+}
+
+type Descriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr,omitempty"`
+}
+
+type Representation struct {
+	ID                string           `xml:"id,attr"`
+	Bandwidth         int              `xml:"bandwidth,attr"`
+	Codecs            string           `xml:"codecs,attr,omitempty"`
+	Width             int              `xml:"width,attr,omitempty"`
+	Height            int              `xml:"height,attr,omitempty"`
+	AudioSamplingRate int              `xml:"audioSamplingRate,attr,omitempty"`
+	AudioChannels     []Descriptor     `xml:"AudioChannelConfiguration,omitempty"`
+	BaseURL           string           `xml:"BaseURL,omitempty"`
+	SegmentTemplate   *SegmentTemplate `xml:"SegmentTemplate,omitempty"`
+}
+
+type SegmentTemplate struct {
+	Timescale      int              `xml:"timescale,attr"`
+	Initialization string           `xml:"initialization,attr"`
+	Media          string           `xml:"media,attr"`
+	StartNumber    int              `xml:"startNumber,attr"`
+	Timeline       *SegmentTimeline `xml:"SegmentTimeline"`
+}
+
+type SegmentTimeline struct {
+	Segments []S `xml:"S"`
+}
+
+// S is a SegmentTimeline entry; consecutive segments with the same duration are
+// folded with R (repeat count).
+type S struct {
+	T *int64 `xml:"t,attr,omitempty"`
+	D int64  `xml:"d,attr"`
+	R int    `xml:"r,attr,omitempty"`
+}
+
+// Timescale used for SegmentTimeline durations (milliseconds).
+const Timescale = 1000
+
+func NewStatic(duration float64) *MPD {
+	return &MPD{
+		Xmlns:                     "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:                  "urn:mpeg:dash:profile:isoff-live:2011,urn:mpeg:dash:profile:cmaf:2019",
+		Type:                      "static",
+		MediaPresentationDuration: FormatDuration(duration),
+		MinBufferTime:             "PT2S",
+	}
+}
+
+// NewTimeline folds segment durations (seconds) into SegmentTimeline entries.
+func NewTimeline(durations []float64) *SegmentTimeline {
+	timeline := &SegmentTimeline{}
+	var start int64
+	for i, d := range durations {
+		ticks := int64(math.Round(d * Timescale))
+		last := len(timeline.Segments) - 1
+		if last >= 0 && timeline.Segments[last].D == ticks {
+			timeline.Segments[last].R++
+		} else {
+			s := S{D: ticks}
+			if i == 0 {
+				s.T = &start
+			}
+			timeline.Segments = append(timeline.Segments, s)
+		}
+	}
+	return timeline
+}
+
+// FormatDuration renders seconds as an xs:duration (PT1H2M3.456S).
+func FormatDuration(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	h := ms / 3600000
+	m := ms / 60000 % 60
+	s := float64(ms%60000) / 1000
+	if h > 0 {
+		return fmt.Sprintf("PT%dH%dM%.3fS", h, m, s)
+	}
+	if m > 0 {
+		return fmt.Sprintf("PT%dM%.3fS", m, s)
+	}
+	return fmt.Sprintf("PT%.3fS", s)
+}
+
+func (m *MPD) Marshal() ([]byte, error) {
+	out, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
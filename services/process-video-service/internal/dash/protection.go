@@ -0,0 +1,74 @@
+package dash
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	cencNamespace   = "urn:mpeg:cenc:2013"
+	dashifNamespace = "https://dashif.org/CPS"
+
+	// mp4protection, DASH-IF ClearKey and the W3C common PSSH system
+	mp4ProtectionScheme = "urn:mpeg:dash:mp4protection:2011"
+	clearKeySystemID    = "e2719d58-a985-b3c9-781a-b030af78d30e"
+	commonSystemID      = "1077efec-c0b2-4d02-ace3-3c1e52e2fb4b"
+)
+
+type ContentProtection struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr,omitempty"`
+	DefaultKID  string `xml:"cenc:default_KID,attr,omitempty"`
+	Laurl       *Laurl `xml:"dashif:Laurl,omitempty"`
+	Pssh        string `xml:"cenc:pssh,omitempty"`
+}
+
+type Laurl struct {
+	URL string `xml:",chardata"`
+}
+
+// EnableCENC declares the cenc and dashif namespaces used by ClearKey descriptors.
+func (m *MPD) EnableCENC() {
+	m.XmlnsCenc = cencNamespace
+	m.XmlnsDashif = dashifNamespace
+}
+
+// ClearKeyProtection returns the descriptors for a cenc-encrypted adaptation set
+// whose key is handed out by the ClearKey license server at licenseURL.
+func ClearKeyProtection(kid []byte, licenseURL string) []ContentProtection {
+	pssh := base64.StdEncoding.EncodeToString(PSSH(commonSystemID, kid))
+	return []ContentProtection{
+		{SchemeIDURI: mp4ProtectionScheme, Value: "cenc", DefaultKID: FormatKID(kid)},
+		{SchemeIDURI: "urn:uuid:" + clearKeySystemID, Value: "ClearKey1.0", Laurl: &Laurl{URL: licenseURL}, Pssh: pssh},
+		{SchemeIDURI: "urn:uuid:" + commonSystemID, Value: "cenc", Pssh: pssh},
+	}
+}
+
+// FormatKID renders a 16-byte key id in the 8-4-4-4-12 form default_KID expects.
+func FormatKID(kid []byte) string {
+	h := hex.EncodeToString(kid)
+	if len(h) != 32 {
+		return h
+	}
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32])
+}
+
+// PSSH builds a version 1 pssh box listing the key ids and carrying no system data.
+func PSSH(systemID string, kids ...[]byte) []byte {
+	sysID, _ := hex.DecodeString(strings.ReplaceAll(systemID, "-", ""))
+
+	size := 4 + 4 + 4 + 16 + 4 + 16*len(kids) + 4
+	box := make([]byte, 0, size)
+	box = binary.BigEndian.AppendUint32(box, uint32(size))
+	box = append(box, "pssh"...)
+	box = append(box, 1, 0, 0, 0) // version 1, no flags
+	box = append(box, sysID...)
+	box = binary.BigEndian.AppendUint32(box, uint32(len(kids)))
+	for _, kid := range kids {
+		box = append(box, kid...)
+	}
+	return binary.BigEndian.AppendUint32(box, 0)
+}
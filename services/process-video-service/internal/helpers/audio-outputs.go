@@ -0,0 +1,30 @@
+package helpers
+
+import "process-video-service/internal/models"
+
+// AC-3 and E-AC-3 stop at 5.1
+const surroundChannels = 6
+
+// AudioOutputs lists the audio renditions to package. Every source track gets a stereo
+// rendition; with surround on, tracks of 5.1 or more also get a 5.1 rendition next to
+// it. Only the stereo renditions keep the source's DEFAULT flag, so players pick the
+// downmix unless the viewer asks otherwise.
+func AudioOutputs(tracks []models.AudioTrack, surround bool) []models.AudioTrack {
+	var outputs []models.AudioTrack
+	for _, track := range tracks {
+		stereo := track
+		stereo.Channels = 2
+		outputs = append(outputs, stereo)
+
+		if surround && track.Channels >= surroundChannels {
+			multi := track
+			multi.Channels = surroundChannels
+			multi.Surround = true
+			multi.Default = false
+			// NAME must be unique inside the audio group
+			multi.Name = track.Name + " 5.1"
+			outputs = append(outputs, multi)
+		}
+	}
+	return outputs
+}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"fmt"
+	"slices"
+
+	"process-video-service/internal/models"
+)
+
+// ChaptersFromCuts merges scene cuts into chapters of at least minSeconds: a cut only
+// starts a new chapter when both the chapter it closes and what is left of the video
+// are long enough. A video without usable cuts is a single chapter.
+func ChaptersFromCuts(cuts []float64, duration, minSeconds float64) []models.Chapter {
+	if duration <= 0 {
+		return nil
+	}
+	cuts = slices.Sorted(slices.Values(cuts))
+
+	var chapters []models.Chapter
+	start := 0.0
+	for _, cut := range cuts {
+		if cut-start < minSeconds || duration-cut < minSeconds {
+			continue
+		}
+		chapters = append(chapters, models.Chapter{Start: start, End: cut})
+		start = cut
+	}
+	chapters = append(chapters, models.Chapter{Start: start, End: duration})
+
+	for i := range chapters {
+		chapters[i].Title = fmt.Sprintf("Chapter %d", i+1)
+	}
+	return chapters
+}
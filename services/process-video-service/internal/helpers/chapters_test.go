@@ -0,0 +1,55 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaptersFromCuts(t *testing.T) {
+	tests := []struct {
+		name     string
+		cuts     []float64
+		duration float64
+		want     []models.Chapter
+	}{
+		{
+			name:     "unsorted cuts",
+			cuts:     []float64{200, 100},
+			duration: 300,
+			want: []models.Chapter{
+				{Start: 0, End: 100, Title: "Chapter 1"},
+				{Start: 100, End: 200, Title: "Chapter 2"},
+				{Start: 200, End: 300, Title: "Chapter 3"},
+			},
+		},
+		{
+			name:     "cuts too close to the previous chapter or the end",
+			cuts:     []float64{30, 100, 130, 280},
+			duration: 300,
+			want: []models.Chapter{
+				{Start: 0, End: 100, Title: "Chapter 1"},
+				{Start: 100, End: 300, Title: "Chapter 2"},
+			},
+		},
+		{
+			name:     "no cuts",
+			duration: 300,
+			want:     []models.Chapter{{Start: 0, End: 300, Title: "Chapter 1"}},
+		},
+		{
+			name:     "no duration",
+			cuts:     []float64{100},
+			duration: 0,
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, helpers.ChaptersFromCuts(tt.cuts, tt.duration, 60))
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package helpers
+
+import (
+	"fmt"
+
+	"process-video-service/internal/models"
+)
+
+// ResolveClip validates a clip request and fills in the default mode.
+func ResolveClip(clip models.ClipRequestEvent) (models.ClipRequestEvent, error) {
+	if clip.SourceEpId == "" || clip.EpId == "" {
+		return clip, fmt.Errorf("source_episode_id and episode_id are required")
+	}
+	if clip.SourceEpId == clip.EpId {
+		return clip, fmt.Errorf("a clip can't replace its source episode %s", clip.SourceEpId)
+	}
+	if clip.In < 0 || clip.Out < 0 || clip.Out > 0 && clip.Out <= clip.In {
+		return clip, fmt.Errorf("clip range %g-%g is invalid", clip.In, clip.Out)
+	}
+	switch clip.Mode {
+	case "":
+		clip.Mode = models.ClipModeReencode
+	case models.ClipModeReencode, models.ClipModeCopy:
+	default:
+		return clip, fmt.Errorf("clip mode must be %s or %s, got %q", models.ClipModeReencode, models.ClipModeCopy, clip.Mode)
+	}
+	return clip, nil
+}
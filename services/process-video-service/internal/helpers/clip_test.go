@@ -0,0 +1,43 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveClip(t *testing.T) {
+	tests := []struct {
+		name     string
+		clip     models.ClipRequestEvent
+		wantMode string
+		wantErr  string
+	}{
+		{name: "range", clip: models.ClipRequestEvent{SourceEpId: "ep1", EpId: "clip1", In: 10, Out: 40}, wantMode: models.ClipModeReencode},
+		{name: "copy", clip: models.ClipRequestEvent{SourceEpId: "ep1", EpId: "clip1", In: 10, Mode: models.ClipModeCopy}, wantMode: models.ClipModeCopy},
+		{name: "unknown mode", clip: models.ClipRequestEvent{SourceEpId: "ep1", EpId: "clip1", Mode: "fast"}, wantErr: "clip mode"},
+		{name: "open end", clip: models.ClipRequestEvent{SourceEpId: "ep1", EpId: "clip1", In: 10}, wantMode: models.ClipModeReencode},
+		{name: "whole episode", clip: models.ClipRequestEvent{SourceEpId: "ep1", EpId: "clip1"}, wantMode: models.ClipModeReencode},
+		{name: "missing source", clip: models.ClipRequestEvent{EpId: "clip1", Out: 40}, wantErr: "required"},
+		{name: "missing episode", clip: models.ClipRequestEvent{SourceEpId: "ep1", Out: 40}, wantErr: "required"},
+		{name: "replaces its source", clip: models.ClipRequestEvent{SourceEpId: "ep1", EpId: "ep1", Out: 40}, wantErr: "source episode"},
+		{name: "negative in", clip: models.ClipRequestEvent{SourceEpId: "ep1", EpId: "clip1", In: -1, Out: 40}, wantErr: "invalid"},
+		{name: "negative out", clip: models.ClipRequestEvent{SourceEpId: "ep1", EpId: "clip1", Out: -5}, wantErr: "invalid"},
+		{name: "out before in", clip: models.ClipRequestEvent{SourceEpId: "ep1", EpId: "clip1", In: 40, Out: 10}, wantErr: "invalid"},
+		{name: "empty range", clip: models.ClipRequestEvent{SourceEpId: "ep1", EpId: "clip1", In: 40, Out: 40}, wantErr: "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clip, err := helpers.ResolveClip(tt.clip)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantMode, clip.Mode)
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package helpers
+
+import (
+	"crypto/rand"
+
+	"process-video-service/internal/models"
+)
+
+// NewContentKey draws a random key id, AES-128 key and IV.
+func NewContentKey() (models.ContentKey, error) {
+	key := models.ContentKey{KID: make([]byte, 16), Key: make([]byte, 16), IV: make([]byte, 16)}
+	for _, b := range [][]byte{key.KID, key.Key, key.IV} {
+		if _, err := rand.Read(b); err != nil {
+			return models.ContentKey{}, err
+		}
+	}
+	return key, nil
+}
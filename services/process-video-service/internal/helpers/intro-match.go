@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"cmp"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// FingerprintInterval is what one chromaprint point covers: 4096-sample frames of
+// 11025Hz audio, overlapped by two thirds.
+const FingerprintInterval = 4096.0 / 3 / 11025
+
+const (
+	// points differing in more bits than this are different audio
+	maxFingerprintBitErrors = 6
+	// a run survives this much unmatched audio (a sound effect over the theme)
+	maxFingerprintGap = 1.0
+)
+
+// IntroMatch is a stretch of audio two episodes share, in seconds from the start of each.
+type IntroMatch struct {
+	Start      float64
+	End        float64
+	OtherStart float64
+	OtherEnd   float64
+}
+
+// MatchIntro slides fingerprint b along a and returns the longest stretch where they
+// agree, as long as it lasts between minSeconds and maxSeconds. Longer stretches are
+// ignored: two episodes sharing minutes of audio are more likely the same episode.
+func MatchIntro(a, b []uint32, interval, minSeconds, maxSeconds float64) (IntroMatch, bool) {
+	maxPoints := int(maxSeconds / interval)
+
+	var best *matchRun
+	matchRuns(a, b, interval, minSeconds, func(run matchRun) {
+		if run.length <= maxPoints && (best == nil || run.length > best.length) {
+			best = &run
+		}
+	})
+	if best == nil {
+		return IntroMatch{}, false
+	}
+	return best.match(interval), true
+}
+
+// MatchClips returns the stretches of a, each at least minSeconds long, that also occur
+// somewhere in b, in the order they play in a. Where stretches overlap in a the longest
+// one is kept.
+func MatchClips(a, b []uint32, interval, minSeconds float64) []IntroMatch {
+	var runs []matchRun
+	matchRuns(a, b, interval, minSeconds, func(run matchRun) {
+		runs = append(runs, run)
+	})
+	slices.SortStableFunc(runs, func(x, y matchRun) int { return cmp.Compare(y.length, x.length) })
+
+	var kept []matchRun
+	for _, run := range runs {
+		overlaps := slices.ContainsFunc(kept, func(k matchRun) bool {
+			return run.start < k.start+k.length && k.start < run.start+run.length
+		})
+		if !overlaps {
+			kept = append(kept, run)
+		}
+	}
+	slices.SortFunc(kept, func(x, y matchRun) int { return cmp.Compare(x.start, y.start) })
+
+	clips := make([]IntroMatch, len(kept))
+	for i, run := range kept {
+		clips[i] = run.match(interval)
+	}
+	return clips
+}
+
+// matchRun is a stretch of length points from a[start], lined up with b shifted by shift.
+type matchRun struct {
+	start, length, shift int
+}
+
+func (r matchRun) match(interval float64) IntroMatch {
+	end := r.start + r.length
+	return IntroMatch{
+		Start:      float64(r.start) * interval,
+		End:        float64(end) * interval,
+		OtherStart: float64(r.start-r.shift) * interval,
+		OtherEnd:   float64(end-r.shift) * interval,
+	}
+}
+
+func matchRuns(a, b []uint32, interval, minSeconds float64, found func(matchRun)) {
+	minPoints := int(math.Ceil(minSeconds / interval))
+	maxGap := int(math.Round(maxFingerprintGap / interval))
+
+	// a[i] lines up with b[i-shift]
+	for shift := -(len(b) - 1); shift < len(a); shift++ {
+		lo, hi := max(0, shift), min(len(a), len(b)+shift)
+		start, last := -1, -1
+		flush := func() {
+			if n := last - start + 1; start >= 0 && n >= minPoints {
+				found(matchRun{start: start, length: n, shift: shift})
+			}
+		}
+		for i := lo; i < hi; i++ {
+			if bits.OnesCount32(a[i]^b[i-shift]) > maxFingerprintBitErrors {
+				continue
+			}
+			if start >= 0 && i-last > maxGap {
+				flush()
+				start = -1
+			}
+			if start < 0 {
+				start = i
+			}
+			last = i
+		}
+		flush()
+	}
+}
@@ -0,0 +1,110 @@
+package helpers_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"process-video-service/internal/helpers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomPoints(rng *rand.Rand, n int) []uint32 {
+	p := make([]uint32, n)
+	for i := range p {
+		p[i] = rng.Uint32()
+	}
+	return p
+}
+
+func TestMatchIntro(t *testing.T) {
+	const interval = 0.1
+	rng := rand.New(rand.NewSource(1))
+	theme := randomPoints(rng, 300)
+	episode := randomPoints(rng, 600)
+
+	// a few flipped bits and a short sound effect over the theme still match
+	noisy := append([]uint32(nil), theme...)
+	for i := range noisy {
+		noisy[i] ^= 0b101
+	}
+	copy(noisy[150:155], randomPoints(rng, 5))
+
+	tests := []struct {
+		name   string
+		a, b   []uint32
+		want   helpers.IntroMatch
+		wantOK bool
+	}{
+		{
+			name:   "theme at different offsets",
+			a:      append(append(randomPoints(rng, 100), theme...), randomPoints(rng, 100)...),
+			b:      append(append(randomPoints(rng, 40), theme...), randomPoints(rng, 100)...),
+			want:   helpers.IntroMatch{Start: 10, End: 40, OtherStart: 4, OtherEnd: 34},
+			wantOK: true,
+		},
+		{
+			name:   "noisy theme",
+			a:      append(randomPoints(rng, 20), theme...),
+			b:      noisy,
+			want:   helpers.IntroMatch{Start: 2, End: 32, OtherStart: 0, OtherEnd: 30},
+			wantOK: true,
+		},
+		{
+			name: "shorter than the minimum",
+			a:    append(randomPoints(rng, 20), theme[:100]...),
+			b:    theme[:100],
+		},
+		{
+			name: "longer than the maximum",
+			a:    episode,
+			b:    episode,
+		},
+		{
+			name: "nothing shared",
+			a:    randomPoints(rng, 400),
+			b:    randomPoints(rng, 400),
+		},
+		{
+			name: "empty",
+			a:    nil,
+			b:    theme,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := helpers.MatchIntro(tt.a, tt.b, interval, 15, 45)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.InDelta(t, tt.want.Start, got.Start, 1e-9)
+				assert.InDelta(t, tt.want.End, got.End, 1e-9)
+				assert.InDelta(t, tt.want.OtherStart, got.OtherStart, 1e-9)
+				assert.InDelta(t, tt.want.OtherEnd, got.OtherEnd, 1e-9)
+			}
+		})
+	}
+}
+
+func TestMatchClips(t *testing.T) {
+	const interval = 0.1
+	rng := rand.New(rand.NewSource(2))
+	other := randomPoints(rng, 1000)
+
+	// two scenes of the other episode, out of order, and one too short to count
+	a := randomPoints(rng, 300)
+	copy(a[10:], other[600:650])
+	copy(a[100:], other[200:280])
+	copy(a[250:], other[800:820])
+
+	clips := helpers.MatchClips(a, other, interval, 3)
+	if assert.Len(t, clips, 2) {
+		assert.InDelta(t, 1, clips[0].Start, 1e-9)
+		assert.InDelta(t, 6, clips[0].End, 1e-9)
+		assert.InDelta(t, 60, clips[0].OtherStart, 1e-9)
+		assert.InDelta(t, 10, clips[1].Start, 1e-9)
+		assert.InDelta(t, 18, clips[1].End, 1e-9)
+		assert.InDelta(t, 20, clips[1].OtherStart, 1e-9)
+	}
+
+	assert.Empty(t, helpers.MatchClips(randomPoints(rng, 300), other, interval, 3))
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"fmt"
+	"slices"
+
+	"process-video-service/internal/models"
+)
+
+// Ladder builds the renditions to encode: every enabled codec gets the resolutions
+// FilterResolutions allows for the source, H.264 first so older clients see it first.
+func Ladder(originalHeight int, codecs []string) []models.Rung {
+	var ladder []models.Rung
+	for _, codec := range []string{models.CodecH264, models.CodecHEVC, models.CodecAV1} {
+		if !slices.Contains(codecs, codec) {
+			continue
+		}
+		for _, res := range FilterResolutions(originalHeight) {
+			ladder = append(ladder, models.Rung{Height: res, Codec: codec})
+		}
+	}
+	return ladder
+}
+
+// HDRLadder is the 10-bit HEVC ladder kept in the source's HDR range, next to the
+// tone-mapped SDR ladder.
+func HDRLadder(originalHeight int) []models.Rung {
+	var ladder []models.Rung
+	for _, res := range FilterResolutions(originalHeight) {
+		ladder = append(ladder, models.Rung{Height: res, Codec: models.CodecHEVC, HDR: true})
+	}
+	return ladder
+}
+
+// RenditionName is the directory of a rung under videos/<epId>/ (720p, 720p-hevc, 720p-hevc-hdr).
+func RenditionName(rung models.Rung) string {
+	name := fmt.Sprintf("%dp", rung.Height)
+	if rung.Codec != models.CodecH264 && rung.Codec != "" {
+		name += "-" + rung.Codec
+	}
+	if rung.HDR {
+		name += "-hdr"
+	}
+	return name
+}
+
+// IsDownloadRung reports whether the rung is an SDR H.264 one at one of the given
+// heights, which every device can play offline.
+func IsDownloadRung(rung models.Rung, heights []int) bool {
+	return rung.Codec == models.CodecH264 && !rung.HDR && slices.Contains(heights, rung.Height)
+}
+
+// ValidateCodecs checks the configured ladder codecs; HEVC and AV1 are only
+// packaged as fMP4.
+func ValidateCodecs(codecs []string, packaging models.Packaging) error {
+	if !slices.Contains(codecs, models.CodecH264) {
+		return fmt.Errorf("the ladder must include %s", models.CodecH264)
+	}
+	for _, codec := range codecs {
+		switch codec {
+		case models.CodecH264:
+		case models.CodecHEVC, models.CodecAV1:
+			if packaging.SegmentType != models.SegmentTypeFMP4 {
+				return fmt.Errorf("%s rungs require %s segments", codec, models.SegmentTypeFMP4)
+			}
+		default:
+			return fmt.Errorf("unknown codec %q", codec)
+		}
+	}
+	return nil
+}
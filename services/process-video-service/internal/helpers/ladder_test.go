@@ -0,0 +1,82 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLadder(t *testing.T) {
+	rungs := func(codec string, heights ...int) []models.Rung {
+		var ladder []models.Rung
+		for _, height := range heights {
+			ladder = append(ladder, models.Rung{Height: height, Codec: codec})
+		}
+		return ladder
+	}
+
+	tests := []struct {
+		name   string
+		height int
+		codecs []string
+		want   []models.Rung
+	}{
+		{name: "1080p source", height: 1080, codecs: []string{models.CodecH264}, want: rungs(models.CodecH264, 1080, 720, 480)},
+		{name: "4k source capped at 1080p", height: 2160, codecs: []string{models.CodecH264}, want: rungs(models.CodecH264, 1080, 720, 480)},
+		{name: "720p source", height: 720, codecs: []string{models.CodecH264}, want: rungs(models.CodecH264, 720, 480)},
+		{name: "small source still gets 480p", height: 360, codecs: []string{models.CodecH264}, want: rungs(models.CodecH264, 480)},
+		{
+			name:   "h264 first whatever the configured order",
+			height: 720,
+			codecs: []string{models.CodecAV1, models.CodecH264, models.CodecHEVC},
+			want: append(append(rungs(models.CodecH264, 720, 480),
+				rungs(models.CodecHEVC, 720, 480)...),
+				rungs(models.CodecAV1, 720, 480)...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, helpers.Ladder(tt.height, tt.codecs))
+		})
+	}
+}
+
+func TestRenditionName(t *testing.T) {
+	assert.Equal(t, "720p", helpers.RenditionName(models.Rung{Height: 720, Codec: models.CodecH264}))
+	assert.Equal(t, "720p", helpers.RenditionName(models.Rung{Height: 720}))
+	assert.Equal(t, "1080p-hevc", helpers.RenditionName(models.Rung{Height: 1080, Codec: models.CodecHEVC}))
+	assert.Equal(t, "1080p-hevc-hdr", helpers.RenditionName(models.Rung{Height: 1080, Codec: models.CodecHEVC, HDR: true}))
+}
+
+func TestValidateCodecs(t *testing.T) {
+	ts := models.Packaging{SegmentType: models.SegmentTypeMPEGTS}
+	fmp4 := models.Packaging{SegmentType: models.SegmentTypeFMP4}
+
+	tests := []struct {
+		name      string
+		codecs    []string
+		packaging models.Packaging
+		wantErr   string
+	}{
+		{name: "h264 over ts", codecs: []string{models.CodecH264}, packaging: ts},
+		{name: "every codec over fmp4", codecs: []string{models.CodecH264, models.CodecHEVC, models.CodecAV1}, packaging: fmp4},
+		{name: "h264 missing", codecs: []string{models.CodecHEVC}, packaging: fmp4, wantErr: "must include h264"},
+		{name: "no codecs", packaging: ts, wantErr: "must include h264"},
+		{name: "hevc over ts", codecs: []string{models.CodecH264, models.CodecHEVC}, packaging: ts, wantErr: "hevc rungs require fmp4 segments"},
+		{name: "av1 over ts", codecs: []string{models.CodecH264, models.CodecAV1}, packaging: ts, wantErr: "av1 rungs require fmp4 segments"},
+		{name: "unknown codec", codecs: []string{models.CodecH264, "vp9"}, packaging: fmp4, wantErr: `unknown codec "vp9"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := helpers.ValidateCodecs(tt.codecs, tt.packaging)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
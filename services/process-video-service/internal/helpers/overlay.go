@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+// ResolveOverlay validates an upload's overlay parameters against the source duration
+// and fills in the defaults: top-right corner, fully opaque.
+func ResolveOverlay(overlay models.Overlay, duration float64) (models.Overlay, error) {
+	switch strings.ToLower(path.Ext(overlay.ImageKey)) {
+	case ".png", ".webp", ".jpg", ".jpeg":
+	default:
+		return overlay, fmt.Errorf("overlay image must be a png, webp or jpeg, got %q", overlay.ImageKey)
+	}
+
+	switch overlay.Corner {
+	case "":
+		overlay.Corner = models.OverlayTopRight
+	case models.OverlayTopLeft, models.OverlayTopRight, models.OverlayBottomLeft, models.OverlayBottomRight:
+	default:
+		return overlay, fmt.Errorf("overlay corner must be %s, %s, %s or %s, got %q",
+			models.OverlayTopLeft, models.OverlayTopRight, models.OverlayBottomLeft, models.OverlayBottomRight, overlay.Corner)
+	}
+
+	if overlay.Margin < 0 {
+		return overlay, fmt.Errorf("overlay margin must not be negative, got %d", overlay.Margin)
+	}
+	if overlay.Opacity < 0 || overlay.Opacity > 1 {
+		return overlay, fmt.Errorf("overlay opacity must be between 0 and 1, got %g", overlay.Opacity)
+	}
+	if overlay.Opacity == 0 {
+		overlay.Opacity = 1
+	}
+
+	if overlay.Start < 0 || overlay.End < 0 || overlay.End > 0 && overlay.End <= overlay.Start {
+		return overlay, fmt.Errorf("overlay time range %g-%g is invalid", overlay.Start, overlay.End)
+	}
+	if duration > 0 && overlay.Start >= duration {
+		return overlay, fmt.Errorf("overlay starts at %g, after the end of the video (%g)", overlay.Start, duration)
+	}
+	return overlay, nil
+}
@@ -0,0 +1,47 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveOverlay(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay models.Overlay
+		want    models.Overlay
+		wantErr string
+	}{
+		{
+			name:    "defaults",
+			overlay: models.Overlay{ImageKey: "logos/canal.PNG"},
+			want:    models.Overlay{ImageKey: "logos/canal.PNG", Corner: models.OverlayTopRight, Opacity: 1},
+		},
+		{
+			name:    "explicit values kept",
+			overlay: models.Overlay{ImageKey: "logo.webp", Corner: models.OverlayBottomLeft, Margin: 24, Opacity: 0.6, Start: 5, End: 50},
+			want:    models.Overlay{ImageKey: "logo.webp", Corner: models.OverlayBottomLeft, Margin: 24, Opacity: 0.6, Start: 5, End: 50},
+		},
+		{name: "not an image", overlay: models.Overlay{ImageKey: "logo.gif"}, wantErr: "png, webp or jpeg"},
+		{name: "unknown corner", overlay: models.Overlay{ImageKey: "logo.png", Corner: "center"}, wantErr: "corner"},
+		{name: "negative margin", overlay: models.Overlay{ImageKey: "logo.png", Margin: -1}, wantErr: "margin"},
+		{name: "opacity above one", overlay: models.Overlay{ImageKey: "logo.png", Opacity: 1.5}, wantErr: "opacity"},
+		{name: "end before start", overlay: models.Overlay{ImageKey: "logo.png", Start: 20, End: 10}, wantErr: "time range"},
+		{name: "starts after the video", overlay: models.Overlay{ImageKey: "logo.png", Start: 120}, wantErr: "after the end"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := helpers.ResolveOverlay(tt.overlay, 100)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
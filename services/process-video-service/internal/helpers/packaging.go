@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"fmt"
+
+	"process-video-service/internal/models"
+)
+
+// ResolvePackaging applies a per-job override on top of the configured packaging.
+// Asking for DASH without a segment type picks fMP4, since the MPD reuses the CMAF segments.
+func ResolvePackaging(defaults models.Packaging, override *models.PackagingOverride) (models.Packaging, error) {
+	packaging := defaults
+	if override != nil {
+		if override.Dash != nil {
+			packaging.Dash = *override.Dash
+		}
+		if override.SegmentType != "" {
+			packaging.SegmentType = override.SegmentType
+		} else if packaging.Dash {
+			packaging.SegmentType = models.SegmentTypeFMP4
+		}
+		if override.Encryption != "" {
+			packaging.Encryption = override.Encryption
+		}
+	}
+	return packaging, ValidatePackaging(packaging)
+}
+
+func ValidatePackaging(packaging models.Packaging) error {
+	switch packaging.SegmentType {
+	case models.SegmentTypeMPEGTS, models.SegmentTypeFMP4:
+	default:
+		return fmt.Errorf("segment type must be %s or %s, got %q", models.SegmentTypeMPEGTS, models.SegmentTypeFMP4, packaging.SegmentType)
+	}
+	if packaging.Dash && packaging.SegmentType != models.SegmentTypeFMP4 {
+		return fmt.Errorf("dash packaging requires %s segments", models.SegmentTypeFMP4)
+	}
+	switch packaging.Encryption {
+	case "", models.EncryptionNone:
+	case models.EncryptionAES128:
+		if packaging.SegmentType != models.SegmentTypeMPEGTS {
+			return fmt.Errorf("%s encryption requires %s segments", models.EncryptionAES128, models.SegmentTypeMPEGTS)
+		}
+	case models.EncryptionCENC:
+		if packaging.SegmentType != models.SegmentTypeFMP4 {
+			return fmt.Errorf("%s encryption requires %s segments", models.EncryptionCENC, models.SegmentTypeFMP4)
+		}
+	default:
+		return fmt.Errorf("encryption must be %s, %s or %s, got %q", models.EncryptionNone, models.EncryptionAES128, models.EncryptionCENC, packaging.Encryption)
+	}
+	return nil
+}
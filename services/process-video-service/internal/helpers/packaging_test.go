@@ -0,0 +1,115 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvePackaging(t *testing.T) {
+	ts := models.Packaging{SegmentType: models.SegmentTypeMPEGTS}
+	fmp4 := models.Packaging{SegmentType: models.SegmentTypeFMP4, Dash: true}
+	dash, noDash := true, false
+
+	tests := []struct {
+		name     string
+		defaults models.Packaging
+		override *models.PackagingOverride
+		want     models.Packaging
+		wantErr  string
+	}{
+		{name: "no override", defaults: ts, want: ts},
+		{
+			name:     "dash override picks fmp4",
+			defaults: ts,
+			override: &models.PackagingOverride{Dash: &dash},
+			want:     models.Packaging{SegmentType: models.SegmentTypeFMP4, Dash: true},
+		},
+		{
+			name:     "override turns dash off",
+			defaults: fmp4,
+			override: &models.PackagingOverride{Dash: &noDash},
+			want:     models.Packaging{SegmentType: models.SegmentTypeFMP4},
+		},
+		{
+			name:     "encryption override keeps configured dash",
+			defaults: fmp4,
+			override: &models.PackagingOverride{Encryption: models.EncryptionCENC},
+			want:     models.Packaging{SegmentType: models.SegmentTypeFMP4, Dash: true, Encryption: models.EncryptionCENC},
+		},
+		{
+			name:     "empty override keeps the defaults",
+			defaults: fmp4,
+			override: &models.PackagingOverride{},
+			want:     fmp4,
+		},
+		{
+			name:     "segment type override",
+			defaults: fmp4,
+			override: &models.PackagingOverride{SegmentType: models.SegmentTypeMPEGTS, Dash: &noDash},
+			want:     ts,
+		},
+		{
+			name:     "ts override with configured dash",
+			defaults: fmp4,
+			override: &models.PackagingOverride{SegmentType: models.SegmentTypeMPEGTS},
+			wantErr:  "dash packaging requires fmp4 segments",
+		},
+		{
+			name:     "encryption override keeps the configured segments",
+			defaults: ts,
+			override: &models.PackagingOverride{Encryption: models.EncryptionAES128},
+			want:     models.Packaging{SegmentType: models.SegmentTypeMPEGTS, Encryption: models.EncryptionAES128},
+		},
+		{
+			name:     "configured encryption kept",
+			defaults: models.Packaging{SegmentType: models.SegmentTypeFMP4, Encryption: models.EncryptionCENC},
+			override: &models.PackagingOverride{Dash: &dash},
+			want:     models.Packaging{SegmentType: models.SegmentTypeFMP4, Dash: true, Encryption: models.EncryptionCENC},
+		},
+		{
+			name:     "dash over ts",
+			defaults: ts,
+			override: &models.PackagingOverride{SegmentType: models.SegmentTypeMPEGTS, Dash: &dash},
+			wantErr:  "dash packaging requires fmp4 segments",
+		},
+		{
+			name:     "aes-128 over fmp4",
+			defaults: fmp4,
+			override: &models.PackagingOverride{Dash: &dash, Encryption: models.EncryptionAES128},
+			wantErr:  "aes-128 encryption requires mpegts segments",
+		},
+		{
+			name:     "cenc over ts",
+			defaults: ts,
+			override: &models.PackagingOverride{Encryption: models.EncryptionCENC},
+			wantErr:  "cenc encryption requires fmp4 segments",
+		},
+		{
+			name:     "unknown segment type",
+			defaults: ts,
+			override: &models.PackagingOverride{SegmentType: "webm"},
+			wantErr:  `got "webm"`,
+		},
+		{
+			name:     "unknown encryption",
+			defaults: ts,
+			override: &models.PackagingOverride{Encryption: "widevine"},
+			wantErr:  `got "widevine"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := helpers.ResolvePackaging(tt.defaults, tt.override)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
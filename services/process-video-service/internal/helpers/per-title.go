@@ -0,0 +1,111 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"process-video-service/internal/models"
+)
+
+const (
+	// perTitleHeadroom lets scenes harder than the sampled ones spend a little more.
+	perTitleHeadroom = 1.2
+	// perTitleMinBitrate keeps a cap from starving a rung on near-static sources.
+	perTitleMinBitrate = 200000
+	// share of the next rung's bits a rung must save to be kept
+	perTitleRedundancy = 0.25
+)
+
+var codecEfficiency = map[string]float64{
+	models.CodecH264: 1,
+	models.CodecHEVC: 0.7,
+	models.CodecAV1:  0.55,
+}
+
+// StaticLadderDecision describes a ladder encoded without per-title analysis.
+func StaticLadderDecision(ladder []models.Rung) models.LadderDecision {
+	decision := models.LadderDecision{}
+	for _, rung := range ladder {
+		decision.Rungs = append(decision.Rungs, models.LadderRung{
+			Height: rung.Height,
+			Codec:  rung.Codec,
+			Reason: "static ladder",
+		})
+	}
+	return decision
+}
+
+// PerTitleLadder caps every rung at the bitrate its height needed in the probe encode
+// and drops the heights that would cost nearly as much as the next height up. The
+// highest height is always kept. Heights the analysis did not measure are kept as is.
+func PerTitleLadder(ladder []models.Rung, analysis *models.ComplexityAnalysis) ([]models.Rung, models.LadderDecision) {
+	measured := map[int]int{}
+	for _, probe := range analysis.Probes {
+		if probe.Bitrate > 0 {
+			measured[probe.Height] = probe.Bitrate
+		}
+	}
+
+	// heights are walked from the top; a height is compared against the last one kept
+	reasons := map[int]string{}
+	dropped := map[int]bool{}
+	kept := 0
+	for _, height := range ladderHeights(ladder) {
+		bitrate, ok := measured[height]
+		switch {
+		case !ok:
+			reasons[height] = "not measured, static ladder"
+		case kept == 0:
+			reasons[height] = fmt.Sprintf("top rung, %s at CRF %d", formatBitrate(bitrate), analysis.CRF)
+			kept = height
+		case float64(bitrate) > float64(measured[kept])*(1-perTitleRedundancy):
+			reasons[height] = fmt.Sprintf("redundant, %s is within %.0f%% of %dp at %s",
+				formatBitrate(bitrate), perTitleRedundancy*100, kept, formatBitrate(measured[kept]))
+			dropped[height] = true
+		default:
+			reasons[height] = fmt.Sprintf("%s at CRF %d, %.0f%% below %dp",
+				formatBitrate(bitrate), analysis.CRF, (1-float64(bitrate)/float64(measured[kept]))*100, kept)
+			kept = height
+		}
+	}
+
+	decision := models.LadderDecision{PerTitle: true, Analysis: analysis}
+	var result []models.Rung
+	for _, rung := range ladder {
+		entry := models.LadderRung{Height: rung.Height, Codec: rung.Codec, Reason: reasons[rung.Height]}
+		if dropped[rung.Height] {
+			entry.Dropped = true
+			decision.Rungs = append(decision.Rungs, entry)
+			continue
+		}
+		if bitrate, ok := measured[rung.Height]; ok {
+			efficiency, ok := codecEfficiency[rung.Codec]
+			if !ok {
+				efficiency = 1
+			}
+			rung.MaxBitrate = max(int(math.Round(float64(bitrate)*perTitleHeadroom*efficiency)), perTitleMinBitrate)
+			entry.MaxBitrate = rung.MaxBitrate
+		}
+		decision.Rungs = append(decision.Rungs, entry)
+		result = append(result, rung)
+	}
+	return result, decision
+}
+
+// ladderHeights returns the distinct heights of the ladder, highest first.
+func ladderHeights(ladder []models.Rung) []int {
+	var heights []int
+	for _, rung := range ladder {
+		if !slices.Contains(heights, rung.Height) {
+			heights = append(heights, rung.Height)
+		}
+	}
+	slices.Sort(heights)
+	slices.Reverse(heights)
+	return heights
+}
+
+func formatBitrate(bps int) string {
+	return fmt.Sprintf("%.2f Mbps", float64(bps)/1e6)
+}
@@ -0,0 +1,110 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerTitleLadder(t *testing.T) {
+	h264 := func(heights ...int) []models.Rung {
+		var ladder []models.Rung
+		for _, height := range heights {
+			ladder = append(ladder, models.Rung{Height: height, Codec: models.CodecH264})
+		}
+		return ladder
+	}
+	probes := func(pairs ...int) []models.ComplexityProbe {
+		var out []models.ComplexityProbe
+		for i := 0; i < len(pairs); i += 2 {
+			out = append(out, models.ComplexityProbe{Height: pairs[i], Bitrate: pairs[i+1]})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name        string
+		ladder      []models.Rung
+		probes      []models.ComplexityProbe
+		want        []models.Rung
+		wantDropped []int
+	}{
+		{
+			name:   "every height capped with headroom",
+			ladder: h264(1080, 720, 480),
+			probes: probes(1080, 5000000, 720, 3000000, 480, 1500000),
+			want: []models.Rung{
+				{Height: 1080, Codec: models.CodecH264, MaxBitrate: 6000000},
+				{Height: 720, Codec: models.CodecH264, MaxBitrate: 3600000},
+				{Height: 480, Codec: models.CodecH264, MaxBitrate: 1800000},
+			},
+		},
+		{
+			name:   "redundant height dropped, next compared against the last kept",
+			ladder: h264(1080, 720, 480),
+			probes: probes(1080, 5000000, 720, 4000000, 480, 2000000),
+			want: []models.Rung{
+				{Height: 1080, Codec: models.CodecH264, MaxBitrate: 6000000},
+				{Height: 480, Codec: models.CodecH264, MaxBitrate: 2400000},
+			},
+			wantDropped: []int{720},
+		},
+		{
+			name:   "top height kept even when the lower ones cost the same",
+			ladder: h264(1080, 720),
+			probes: probes(1080, 3000000, 720, 3000000),
+			want: []models.Rung{
+				{Height: 1080, Codec: models.CodecH264, MaxBitrate: 3600000},
+			},
+			wantDropped: []int{720},
+		},
+		{
+			name:   "unmeasured height kept uncapped",
+			ladder: h264(1080, 720),
+			probes: probes(1080, 5000000, 720, 0),
+			want: []models.Rung{
+				{Height: 1080, Codec: models.CodecH264, MaxBitrate: 6000000},
+				{Height: 720, Codec: models.CodecH264},
+			},
+		},
+		{
+			name:   "codec efficiency scales the cap",
+			ladder: []models.Rung{{Height: 1080, Codec: models.CodecH264}, {Height: 1080, Codec: models.CodecHEVC}, {Height: 1080, Codec: models.CodecAV1}},
+			probes: probes(1080, 5000000),
+			want: []models.Rung{
+				{Height: 1080, Codec: models.CodecH264, MaxBitrate: 6000000},
+				{Height: 1080, Codec: models.CodecHEVC, MaxBitrate: 4200000},
+				{Height: 1080, Codec: models.CodecAV1, MaxBitrate: 3300000},
+			},
+		},
+		{
+			name:   "cap never below the minimum",
+			ladder: h264(720, 240),
+			probes: probes(720, 1000000, 240, 100000),
+			want: []models.Rung{
+				{Height: 720, Codec: models.CodecH264, MaxBitrate: 1200000},
+				{Height: 240, Codec: models.CodecH264, MaxBitrate: 200000},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, decision := helpers.PerTitleLadder(tt.ladder, &models.ComplexityAnalysis{CRF: 23, Probes: tt.probes})
+			assert.Equal(t, tt.want, got)
+
+			assert.True(t, decision.PerTitle)
+			assert.Len(t, decision.Rungs, len(tt.ladder))
+			var dropped []int
+			for _, rung := range decision.Rungs {
+				assert.NotEmpty(t, rung.Reason)
+				if rung.Dropped {
+					dropped = append(dropped, rung.Height)
+				}
+			}
+			assert.Equal(t, tt.wantDropped, dropped)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"slices"
+
+	"process-video-service/internal/models"
+)
+
+// PreviewWindow picks the start of the seconds-long window with the most motion,
+// away from the first and last tenth of the video where recaps, intros and credits
+// sit. Videos too short for that get a window from their middle.
+func PreviewWindow(samples []models.MotionSample, duration, seconds float64) float64 {
+	fallback := max((duration-seconds)/2, 0)
+	lo, hi := duration*0.1, duration*0.9-seconds
+	if hi < lo {
+		return fallback
+	}
+
+	samples = slices.SortedFunc(slices.Values(samples), func(a, b models.MotionSample) int {
+		switch {
+		case a.Time < b.Time:
+			return -1
+		case a.Time > b.Time:
+			return 1
+		}
+		return 0
+	})
+
+	best, bestScore := fallback, -1.0
+	sum, end := 0.0, 0
+	// a window starts at every sample; sum covers it up to samples[end]
+	for _, s := range samples {
+		for end < len(samples) && samples[end].Time < s.Time+seconds {
+			sum += samples[end].Score
+			end++
+		}
+		if s.Time >= lo && s.Time <= hi && sum > bestScore {
+			best, bestScore = s.Time, sum
+		}
+		sum -= s.Score
+	}
+	return best
+}
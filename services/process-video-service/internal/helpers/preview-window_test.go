@@ -0,0 +1,58 @@
+package helpers_test
+
+import (
+	"slices"
+	"testing"
+
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// motion samples one frame per second with the given scores at the given seconds.
+func motion(duration int, scores map[int]float64) []models.MotionSample {
+	samples := make([]models.MotionSample, duration)
+	for i := range samples {
+		samples[i] = models.MotionSample{Time: float64(i), Score: scores[i]}
+	}
+	return samples
+}
+
+func reversed(samples []models.MotionSample) []models.MotionSample {
+	slices.Reverse(samples)
+	return samples
+}
+
+func TestPreviewWindow(t *testing.T) {
+	burst := map[int]float64{60: 1, 61: 1, 62: 1, 63: 1, 64: 1}
+
+	tests := []struct {
+		name     string
+		samples  []models.MotionSample
+		duration float64
+		seconds  float64
+		want     float64
+	}{
+		{name: "window with the most motion", samples: motion(100, burst), duration: 100, seconds: 10, want: 55},
+		{name: "unsorted samples", samples: reversed(motion(100, burst)), duration: 100, seconds: 10, want: 55},
+		{
+			name:     "motion in the first tenth ignored",
+			samples:  motion(100, map[int]float64{2: 1, 3: 1, 4: 1, 40: 0.5, 41: 0.5}),
+			duration: 100, seconds: 10, want: 32,
+		},
+		{
+			name:     "motion in the last tenth ignored",
+			samples:  motion(100, map[int]float64{95: 1, 96: 1, 97: 1}),
+			duration: 100, seconds: 10, want: 10,
+		},
+		{name: "no samples falls back to the middle", duration: 100, seconds: 10, want: 45},
+		{name: "window too long for the margins", samples: motion(100, burst), duration: 100, seconds: 90, want: 5},
+		{name: "window longer than the video", samples: motion(20, nil), duration: 20, seconds: 30, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, helpers.PreviewWindow(tt.samples, tt.duration, tt.seconds))
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package helpers
+
+import (
+	"path"
+	"strings"
+
+	"process-video-service/internal/models"
+)
+
+// SidecarSubtitles picks the subtitle files uploaded next to the video, named
+// <videoKey>.<language>.srt or <videoKey>.<language>.vtt, numbering them after firstIndex.
+func SidecarSubtitles(videoKey string, objects []models.ObjectInfo, firstIndex int) []models.SubtitleTrack {
+	var tracks []models.SubtitleTrack
+	for _, obj := range objects {
+		key := obj.Key
+		if !strings.HasPrefix(key, videoKey+".") {
+			continue
+		}
+		ext := strings.ToLower(path.Ext(key))
+		if ext != ".srt" && ext != ".vtt" {
+			continue
+		}
+		lang, ok := strings.CutSuffix(strings.TrimPrefix(key, videoKey+"."), path.Ext(key))
+		if !ok || lang == "" || strings.Contains(lang, ".") {
+			continue
+		}
+		tracks = append(tracks, models.SubtitleTrack{
+			Index:      firstIndex + len(tracks),
+			SidecarKey: key,
+			Language:   lang,
+			Name:       lang,
+		})
+	}
+	return tracks
+}
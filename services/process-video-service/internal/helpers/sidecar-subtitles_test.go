@@ -0,0 +1,30 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"process-video-service/internal/helpers"
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSidecarSubtitles(t *testing.T) {
+	objects := []models.ObjectInfo{
+		{Key: "uploads/video.mp4"},
+		{Key: "uploads/video.mp4.por.srt"},
+		{Key: "uploads/video.mp4.en.VTT"},
+		{Key: "uploads/video.mp4.txt"},
+		{Key: "uploads/video.mp4.srt"},
+		{Key: "uploads/video.mp4.pt.forced.srt"},
+		{Key: "uploads/video.mp4.jpg"},
+		{Key: "uploads/video.mp40.eng.srt"},
+	}
+
+	assert.Equal(t, []models.SubtitleTrack{
+		{Index: 2, SidecarKey: "uploads/video.mp4.por.srt", Language: "por", Name: "por"},
+		{Index: 3, SidecarKey: "uploads/video.mp4.en.VTT", Language: "en", Name: "en"},
+	}, helpers.SidecarSubtitles("uploads/video.mp4", objects, 2))
+
+	assert.Nil(t, helpers.SidecarSubtitles("uploads/video.mp4", nil, 0))
+}
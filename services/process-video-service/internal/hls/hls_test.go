@@ -0,0 +1,234 @@
+package hls_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"process-video-service/internal/hls"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var masterFixture = &hls.MasterPlaylist{
+	Version: 4,
+	Media: []hls.Media{
+		{Type: hls.MediaTypeAudio, GroupID: "audio", Name: "Português", Language: "por", Default: true, AutoSelect: true, Channels: "2", URI: "audio/0/index.m3u8"},
+		{Type: hls.MediaTypeAudio, GroupID: "audio", Name: "Português 5.1", Language: "por", AutoSelect: true, Channels: "6", URI: "audio/0-surround/index.m3u8"},
+		{Type: hls.MediaTypeAudio, GroupID: "audio", Name: "English", Language: "eng", AutoSelect: true, Channels: "2", URI: "audio/1/index.m3u8"},
+		{Type: hls.MediaTypeSubtitles, GroupID: "subs", Name: "Português, forçada", Language: "por", AutoSelect: true, Forced: true, URI: "subtitles/0/index.m3u8"},
+	},
+	Variants: []hls.Variant{
+		{Bandwidth: 5130000, AverageBandwidth: 4120000, Width: 1920, Height: 1080, Codecs: "avc1.640028,mp4a.40.2,ec-3", Audio: "audio", Subtitles: "subs", URI: "1080p/index.m3u8"},
+		{Bandwidth: 1630000, AverageBandwidth: 1120000, Width: 854, Height: 480, Codecs: "avc1.64001e,mp4a.40.2,ec-3", Audio: "audio", Subtitles: "subs", URI: "480p/index.m3u8"},
+		{Bandwidth: 4230000, AverageBandwidth: 3310000, Width: 1920, Height: 1080, Codecs: "hvc1.2.4.L123.B0,mp4a.40.2,ec-3", VideoRange: hls.VideoRangePQ, Audio: "audio", Subtitles: "subs", URI: "1080p-hevc-hdr/index.m3u8"},
+	},
+	IFrameVariants: []hls.IFrameVariant{
+		{Bandwidth: 310000, Width: 854, Height: 480, Codecs: "avc1.64001e", URI: "480p/iframes.m3u8"},
+	},
+}
+
+var mediaFixtures = map[string]*hls.MediaPlaylist{
+	"media-ts.m3u8": {
+		Version:        3,
+		TargetDuration: 11,
+		Segments: []hls.Segment{
+			{Duration: 10.427, URI: "seg000.ts"},
+			{Duration: 10, URI: "seg001.ts"},
+			{Duration: 3.5, URI: "seg002.ts"},
+		},
+	},
+	"media-aes128.m3u8": {
+		Version:        3,
+		TargetDuration: 10,
+		Key:            &hls.Key{Method: "AES-128", URI: "https://keys.example/keys/ep123", IV: "0x000102030405060708090a0b0c0d0e0f"},
+		Segments: []hls.Segment{
+			{Duration: 10, URI: "seg000.ts"},
+			{Duration: 4.2, URI: "seg001.ts"},
+		},
+	},
+	"media-fmp4-cenc.m3u8": {
+		Version:        7,
+		TargetDuration: 10,
+		Map:            &hls.Map{URI: "init.mp4"},
+		Key:            &hls.Key{Method: "SAMPLE-AES-CTR", URI: "https://keys.example/keys/ep123/license", KeyFormat: "org.w3.clearkey", KeyFormatVersions: "1"},
+		Segments: []hls.Segment{
+			{Duration: 10, URI: "seg000.m4s"},
+			{Duration: 6.016, URI: "seg001.m4s"},
+		},
+	},
+	"iframes-ts.m3u8": {
+		Version:        5,
+		TargetDuration: 3,
+		IFramesOnly:    true,
+		Map:            &hls.Map{URI: "seg000.ts", ByteRange: &hls.ByteRange{Length: 564}},
+		Segments: []hls.Segment{
+			{Duration: 2.5, URI: "seg000.ts", ByteRange: &hls.ByteRange{Length: 40044, Offset: 564}},
+			{Duration: 2.5, URI: "seg000.ts", ByteRange: &hls.ByteRange{Length: 37224, Offset: 812256}},
+			{Duration: 2, URI: "seg001.ts", ByteRange: &hls.ByteRange{Length: 39480, Offset: 564}},
+		},
+	},
+}
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(path, got, 0644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestMasterPlaylist_Golden(t *testing.T) {
+	require.NoError(t, masterFixture.Validate())
+	golden(t, "master.m3u8", masterFixture.Encode())
+
+	parsed, err := hls.ParseMaster(bytes.NewReader(masterFixture.Encode()))
+	require.NoError(t, err)
+	assert.Equal(t, masterFixture, parsed)
+}
+
+func TestMediaPlaylist_Golden(t *testing.T) {
+	for name, playlist := range mediaFixtures {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, playlist.Validate())
+			golden(t, name, playlist.Encode())
+
+			parsed, err := hls.ParseMedia(bytes.NewReader(playlist.Encode()))
+			require.NoError(t, err)
+			assert.Equal(t, playlist, parsed)
+		})
+	}
+}
+
+func TestParseMedia_ByteRangeWithoutOffset(t *testing.T) {
+	playlist, err := hls.ParseMedia(bytes.NewBufferString("#EXTM3U\n#EXT-X-VERSION:4\n#EXT-X-TARGETDURATION:10\n" +
+		"#EXTINF:10.0,\n#EXT-X-BYTERANGE:1000@200\nall.ts\n" +
+		"#EXTINF:10.0,\n#EXT-X-BYTERANGE:500\nall.ts\n#EXT-X-ENDLIST\n"))
+	require.NoError(t, err)
+	assert.Equal(t, &hls.ByteRange{Length: 500, Offset: 1200}, playlist.Segments[1].ByteRange)
+}
+
+func TestParse_RejectsNonPlaylist(t *testing.T) {
+	_, err := hls.ParseMedia(bytes.NewBufferString("WEBVTT\n"))
+	assert.ErrorIs(t, err, hls.ErrNotPlaylist)
+}
+
+func TestMediaPlaylist_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		playlist hls.MediaPlaylist
+		err      string
+	}{
+		{
+			name:     "zero target duration",
+			playlist: hls.MediaPlaylist{Version: 3, Segments: []hls.Segment{{Duration: 0, URI: "seg000.ts"}}},
+			err:      "EXT-X-TARGETDURATION must be at least 1",
+		},
+		{
+			name:     "segment over target duration",
+			playlist: hls.MediaPlaylist{Version: 3, TargetDuration: 10, Segments: []hls.Segment{{Duration: 10.6, URI: "seg000.ts"}}},
+			err:      "over EXT-X-TARGETDURATION 10",
+		},
+		{
+			name:     "map needs version 6",
+			playlist: hls.MediaPlaylist{Version: 3, TargetDuration: 10, Map: &hls.Map{URI: "init.mp4"}, Segments: []hls.Segment{{Duration: 10, URI: "seg000.m4s"}}},
+			err:      "EXT-X-VERSION 3 is lower than the 6 required by EXT-X-MAP",
+		},
+		{
+			name:     "uri escaping the directory",
+			playlist: hls.MediaPlaylist{Version: 3, TargetDuration: 10, Segments: []hls.Segment{{Duration: 10, URI: "../../other/seg000.ts"}}},
+			err:      "escapes the playlist directory",
+		},
+		{
+			name: "quote in KEYFORMAT",
+			playlist: hls.MediaPlaylist{Version: 7, TargetDuration: 10, Map: &hls.Map{URI: "init.mp4"},
+				Key: &hls.Key{Method: "SAMPLE-AES-CTR", URI: "license", KeyFormat: `org."clearkey`}, Segments: []hls.Segment{{Duration: 10, URI: "seg000.m4s"}}},
+			err: "can't be written in a quoted attribute",
+		},
+		{
+			name:     "no segments",
+			playlist: hls.MediaPlaylist{Version: 3, TargetDuration: 10},
+			err:      "playlist has no segments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.playlist.Validate(), tt.err)
+		})
+	}
+}
+
+func TestMasterPlaylist_Validate(t *testing.T) {
+	master := *masterFixture
+	master.Media = append([]hls.Media{}, masterFixture.Media...)
+	master.Media[2].Default = true
+	master.Media[1].Channels = "5.1"
+	master.Variants = append([]hls.Variant{}, masterFixture.Variants...)
+	master.Variants[0].Subtitles = "cc"
+	master.Variants[1].VideoRange = "HDR10"
+
+	err := master.Validate()
+	assert.ErrorContains(t, err, `group "audio" has 2 DEFAULT renditions`)
+	assert.ErrorContains(t, err, `references unknown SUBTITLES group "cc"`)
+	assert.ErrorContains(t, err, `invalid VIDEO-RANGE "HDR10"`)
+	assert.ErrorContains(t, err, `invalid CHANNELS "5.1"`)
+}
+
+func TestMasterPlaylist_ValidateQuotedAttributes(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*hls.MasterPlaylist)
+		err    string
+	}{
+		{name: "quote in NAME", modify: func(m *hls.MasterPlaylist) { m.Media[0].Name = `Português "original"` }, err: `NAME "Português \"original\""`},
+		{name: "newline in NAME", modify: func(m *hls.MasterPlaylist) { m.Media[3].Name = "Português\nforçada" }, err: "NAME"},
+		{name: "quote in LANGUAGE", modify: func(m *hls.MasterPlaylist) { m.Media[2].Language = `en"g` }, err: "LANGUAGE"},
+		{name: "quote in GROUP-ID", modify: func(m *hls.MasterPlaylist) { m.Media[0].GroupID = `au"dio` }, err: "GROUP-ID"},
+		{name: "quote in CHANNELS", modify: func(m *hls.MasterPlaylist) { m.Media[0].Channels = `2/"JOC` }, err: "CHANNELS"},
+		{name: "carriage return in CODECS", modify: func(m *hls.MasterPlaylist) { m.Variants[0].Codecs = "avc1.640028\r" }, err: "CODECS"},
+		{name: "quote in I-frame CODECS", modify: func(m *hls.MasterPlaylist) { m.IFrameVariants[0].Codecs = `avc1"` }, err: "I-frame variant 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			master := *masterFixture
+			master.Media = append([]hls.Media{}, masterFixture.Media...)
+			master.Variants = append([]hls.Variant{}, masterFixture.Variants...)
+			master.IFrameVariants = append([]hls.IFrameVariant{}, masterFixture.IFrameVariants...)
+			tt.modify(&master)
+
+			err := master.Validate()
+			assert.ErrorContains(t, err, "can't be written in a quoted attribute")
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestMasterPlaylist_ValidateVersion(t *testing.T) {
+	master := *masterFixture
+	master.Version = 3
+	assert.ErrorContains(t, master.Validate(), "EXT-X-VERSION 3 is lower than the 4 required by EXT-X-I-FRAME-STREAM-INF")
+
+	master.IFrameVariants = nil
+	assert.NoError(t, master.Validate())
+}
+
+func TestResolveURI(t *testing.T) {
+	key, err := hls.ResolveURI("videos/ep123/master.m3u8", "720p/index.m3u8")
+	assert.NoError(t, err)
+	assert.Equal(t, "videos/ep123/720p/index.m3u8", key)
+
+	key, err = hls.ResolveURI("videos/ep123/720p/index.m3u8", "https://cdn.example/seg000.ts")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://cdn.example/seg000.ts", key)
+
+	_, err = hls.ResolveURI("videos/ep123/master.m3u8", "../../../secret")
+	assert.Error(t, err)
+}
@@ -0,0 +1,261 @@
+package hls
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrNotPlaylist = errors.New("missing #EXTM3U header")
+
+// ParseMaster reads a master playlist. Tags the service doesn't produce are skipped.
+func ParseMaster(r io.Reader) (*MasterPlaylist, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &MasterPlaylist{}
+	var pending *Variant
+	for n, line := range lines {
+		tag, value, _ := strings.Cut(line, ":")
+		switch {
+		case tag == "#EXT-X-VERSION":
+			if p.Version, err = strconv.Atoi(value); err != nil {
+				return nil, lineError(n, err)
+			}
+		case tag == "#EXT-X-MEDIA":
+			attrs, err := parseAttributes(value)
+			if err != nil {
+				return nil, lineError(n, err)
+			}
+			p.Media = append(p.Media, Media{
+				Type:       attrs["TYPE"],
+				GroupID:    attrs["GROUP-ID"],
+				Name:       attrs["NAME"],
+				Language:   attrs["LANGUAGE"],
+				Default:    attrs["DEFAULT"] == "YES",
+				AutoSelect: attrs["AUTOSELECT"] == "YES",
+				Forced:     attrs["FORCED"] == "YES",
+				Channels:   attrs["CHANNELS"],
+				URI:        attrs["URI"],
+			})
+		case tag == "#EXT-X-STREAM-INF":
+			attrs, err := parseAttributes(value)
+			if err != nil {
+				return nil, lineError(n, err)
+			}
+			pending = &Variant{
+				Codecs:     attrs["CODECS"],
+				VideoRange: attrs["VIDEO-RANGE"],
+				Audio:      attrs["AUDIO"],
+				Subtitles:  attrs["SUBTITLES"],
+			}
+			if pending.Bandwidth, err = intAttribute(attrs, "BANDWIDTH"); err != nil {
+				return nil, lineError(n, err)
+			}
+			if pending.AverageBandwidth, err = intAttribute(attrs, "AVERAGE-BANDWIDTH"); err != nil {
+				return nil, lineError(n, err)
+			}
+			if pending.Width, pending.Height, err = parseResolution(attrs["RESOLUTION"]); err != nil {
+				return nil, lineError(n, err)
+			}
+		case tag == "#EXT-X-I-FRAME-STREAM-INF":
+			attrs, err := parseAttributes(value)
+			if err != nil {
+				return nil, lineError(n, err)
+			}
+			v := IFrameVariant{Codecs: attrs["CODECS"], VideoRange: attrs["VIDEO-RANGE"], URI: attrs["URI"]}
+			if v.Bandwidth, err = intAttribute(attrs, "BANDWIDTH"); err != nil {
+				return nil, lineError(n, err)
+			}
+			if v.Width, v.Height, err = parseResolution(attrs["RESOLUTION"]); err != nil {
+				return nil, lineError(n, err)
+			}
+			p.IFrameVariants = append(p.IFrameVariants, v)
+		case line == "" || strings.HasPrefix(line, "#"):
+		default:
+			if pending == nil {
+				return nil, lineError(n, fmt.Errorf("URI %q without EXT-X-STREAM-INF", line))
+			}
+			pending.URI = line
+			p.Variants = append(p.Variants, *pending)
+			pending = nil
+		}
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("EXT-X-STREAM-INF without URI")
+	}
+	return p, nil
+}
+
+// ParseMedia reads a VOD media playlist. A BYTERANGE without offset continues
+// where the previous range of the same URI ended, as RFC 8216 specifies.
+func ParseMedia(r io.Reader) (*MediaPlaylist, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &MediaPlaylist{}
+	var segment Segment
+	nextOffset := map[string]int64{}
+	for n, line := range lines {
+		tag, value, _ := strings.Cut(line, ":")
+		switch {
+		case tag == "#EXT-X-VERSION":
+			if p.Version, err = strconv.Atoi(value); err != nil {
+				return nil, lineError(n, err)
+			}
+		case tag == "#EXT-X-TARGETDURATION":
+			if p.TargetDuration, err = strconv.Atoi(value); err != nil {
+				return nil, lineError(n, err)
+			}
+		case tag == "#EXT-X-MEDIA-SEQUENCE":
+			if p.MediaSequence, err = strconv.Atoi(value); err != nil {
+				return nil, lineError(n, err)
+			}
+		case tag == "#EXT-X-I-FRAMES-ONLY":
+			p.IFramesOnly = true
+		case tag == "#EXT-X-MAP":
+			attrs, err := parseAttributes(value)
+			if err != nil {
+				return nil, lineError(n, err)
+			}
+			p.Map = &Map{URI: attrs["URI"]}
+			if v, ok := attrs["BYTERANGE"]; ok {
+				if p.Map.ByteRange, err = parseByteRange(v, 0); err != nil {
+					return nil, lineError(n, err)
+				}
+			}
+		case tag == "#EXT-X-KEY":
+			attrs, err := parseAttributes(value)
+			if err != nil {
+				return nil, lineError(n, err)
+			}
+			p.Key = &Key{
+				Method:            attrs["METHOD"],
+				URI:               attrs["URI"],
+				IV:                attrs["IV"],
+				KeyFormat:         attrs["KEYFORMAT"],
+				KeyFormatVersions: attrs["KEYFORMATVERSIONS"],
+			}
+		case tag == "#EXTINF":
+			duration, _, _ := strings.Cut(value, ",")
+			if segment.Duration, err = strconv.ParseFloat(duration, 64); err != nil {
+				return nil, lineError(n, err)
+			}
+		case tag == "#EXT-X-BYTERANGE":
+			// the offset default depends on the URI, which comes on a later line
+			if segment.ByteRange, err = parseByteRange(value, -1); err != nil {
+				return nil, lineError(n, err)
+			}
+		case line == "" || strings.HasPrefix(line, "#"):
+		default:
+			segment.URI = line
+			if br := segment.ByteRange; br != nil {
+				if br.Offset < 0 {
+					br.Offset = nextOffset[line]
+				}
+				nextOffset[line] = br.Offset + br.Length
+			}
+			p.Segments = append(p.Segments, segment)
+			segment = Segment{}
+		}
+	}
+	return p, nil
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || lines[0] != "#EXTM3U" {
+		return nil, ErrNotPlaylist
+	}
+	return lines, nil
+}
+
+// parseAttributes splits an attribute list (NAME=VALUE,...), unquoting quoted strings.
+func parseAttributes(s string) (map[string]string, error) {
+	attrs := map[string]string{}
+	for s != "" {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("malformed attribute list %q", s)
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string in %s", name)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		attrs[name] = value
+		if rest != "" && rest[0] != ',' {
+			return nil, fmt.Errorf("expected comma after %s", name)
+		}
+		s = strings.TrimPrefix(rest, ",")
+	}
+	return attrs, nil
+}
+
+func intAttribute(attrs map[string]string, name string) (int, error) {
+	v, ok := attrs[name]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return n, nil
+}
+
+func parseResolution(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	w, h, ok := strings.Cut(s, "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil {
+		return 0, 0, fmt.Errorf("invalid RESOLUTION %q", s)
+	}
+	return width, height, nil
+}
+
+// parseByteRange reads length[@offset], using defaultOffset when there is none.
+func parseByteRange(s string, defaultOffset int64) (*ByteRange, error) {
+	length, offset, hasOffset := strings.Cut(s, "@")
+	br := &ByteRange{Offset: defaultOffset}
+	var err error
+	if br.Length, err = strconv.ParseInt(length, 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid byte range %q", s)
+	}
+	if hasOffset {
+		if br.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid byte range %q", s)
+		}
+	}
+	return br, nil
+}
+
+func lineError(n int, err error) error {
+	return fmt.Errorf("line %d: %w", n+1, err)
+}
@@ -0,0 +1,100 @@
+// Package hls models the master and media playlists the service publishes
+// (RFC 8216) and renders them.
+package hls
+
+const (
+	MediaTypeAudio     = "AUDIO"
+	MediaTypeSubtitles = "SUBTITLES"
+)
+
+// VIDEO-RANGE values.
+const (
+	VideoRangeSDR = "SDR"
+	VideoRangePQ  = "PQ"
+	VideoRangeHLG = "HLG"
+)
+
+// MasterPlaylist lists the renditions groups and variant streams of an episode.
+type MasterPlaylist struct {
+	Version        int
+	Media          []Media
+	Variants       []Variant
+	IFrameVariants []IFrameVariant
+}
+
+// Media is an EXT-X-MEDIA rendition (alternative audio or subtitles).
+type Media struct {
+	Type       string
+	GroupID    string
+	Name       string
+	Language   string
+	Default    bool
+	AutoSelect bool
+	// Forced is only written for SUBTITLES.
+	Forced bool
+	// Channels is the AUDIO channel count ("2", "6"); omitted when empty.
+	Channels string
+	URI      string
+}
+
+// Variant is an EXT-X-STREAM-INF entry.
+type Variant struct {
+	Bandwidth        int
+	AverageBandwidth int
+	Width            int
+	Height           int
+	Codecs           string
+	// VideoRange is omitted when empty, which players read as SDR.
+	VideoRange string
+	Audio      string
+	Subtitles  string
+	URI        string
+}
+
+// IFrameVariant is an EXT-X-I-FRAME-STREAM-INF entry pointing at an I-frame playlist.
+type IFrameVariant struct {
+	Bandwidth  int
+	Width      int
+	Height     int
+	Codecs     string
+	VideoRange string
+	URI        string
+}
+
+// MediaPlaylist is a VOD media playlist; it always ends with EXT-X-ENDLIST.
+type MediaPlaylist struct {
+	Version        int
+	TargetDuration int
+	MediaSequence  int
+	IFramesOnly    bool
+	Map            *Map
+	Key            *Key
+	Segments       []Segment
+}
+
+// Map is the EXT-X-MAP media initialization section.
+type Map struct {
+	URI       string
+	ByteRange *ByteRange
+}
+
+// Key is an EXT-X-KEY; IV is written as given (0x...).
+type Key struct {
+	Method            string
+	URI               string
+	IV                string
+	KeyFormat         string
+	KeyFormatVersions string
+}
+
+type Segment struct {
+	Duration  float64
+	URI       string
+	ByteRange *ByteRange
+}
+
+// ByteRange is a sub-range of a resource: Length bytes starting at Offset.
+type ByteRange struct {
+	Length int64
+	Offset int64
+}
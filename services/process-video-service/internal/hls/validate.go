@@ -0,0 +1,247 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Validate checks the media playlist against the RFC 8216 rules players enforce:
+// a target duration covering every segment and a version high enough for the tags used.
+func (p *MediaPlaylist) Validate() error {
+	var errs []error
+
+	if len(p.Segments) == 0 {
+		errs = append(errs, errors.New("playlist has no segments"))
+	}
+	if p.TargetDuration < 1 {
+		errs = append(errs, fmt.Errorf("EXT-X-TARGETDURATION must be at least 1, got %d", p.TargetDuration))
+	}
+	for i, s := range p.Segments {
+		if s.Duration <= 0 {
+			errs = append(errs, fmt.Errorf("segment %d (%s) has no duration", i, s.URI))
+		}
+		// EXTINF rounded to the nearest integer must not exceed the target duration
+		if int(math.Round(s.Duration)) > p.TargetDuration {
+			errs = append(errs, fmt.Errorf("segment %d (%s) lasts %.3fs, over EXT-X-TARGETDURATION %d", i, s.URI, s.Duration, p.TargetDuration))
+		}
+		if err := validateURI(s.URI); err != nil {
+			errs = append(errs, fmt.Errorf("segment %d: %w", i, err))
+		}
+	}
+	if p.Map != nil {
+		if err := validateURI(p.Map.URI); err != nil {
+			errs = append(errs, fmt.Errorf("EXT-X-MAP: %w", err))
+		}
+	}
+	if p.Key != nil {
+		if p.Key.URI == "" {
+			errs = append(errs, errors.New("EXT-X-KEY without URI"))
+		}
+		if err := validateQuoted("KEYFORMAT", p.Key.KeyFormat); err != nil {
+			errs = append(errs, fmt.Errorf("EXT-X-KEY: %w", err))
+		}
+		if err := validateQuoted("KEYFORMATVERSIONS", p.Key.KeyFormatVersions); err != nil {
+			errs = append(errs, fmt.Errorf("EXT-X-KEY: %w", err))
+		}
+	}
+
+	if required, feature := p.requiredVersion(); p.Version < required {
+		errs = append(errs, fmt.Errorf("EXT-X-VERSION %d is lower than the %d required by %s", p.Version, required, feature))
+	}
+	return errors.Join(errs...)
+}
+
+// requiredVersion follows RFC 8216 section 7.
+func (p *MediaPlaylist) requiredVersion() (int, string) {
+	version, feature := 1, ""
+	need := func(v int, f string) {
+		if v > version {
+			version, feature = v, f
+		}
+	}
+
+	if p.Key != nil && p.Key.IV != "" {
+		need(2, "EXT-X-KEY IV")
+	}
+	if len(p.Segments) > 0 {
+		// Encode always writes EXTINF with decimals
+		need(3, "decimal EXTINF durations")
+	}
+	for _, s := range p.Segments {
+		if s.ByteRange != nil {
+			need(4, "EXT-X-BYTERANGE")
+		}
+	}
+	if p.IFramesOnly {
+		need(4, "EXT-X-I-FRAMES-ONLY")
+	}
+	if p.Key != nil && (p.Key.KeyFormat != "" || p.Key.KeyFormatVersions != "") {
+		need(5, "EXT-X-KEY KEYFORMAT")
+	}
+	if p.Key != nil && strings.HasPrefix(p.Key.Method, "SAMPLE-AES") {
+		need(5, "EXT-X-KEY METHOD="+p.Key.Method)
+	}
+	if p.Map != nil {
+		if p.IFramesOnly {
+			need(5, "EXT-X-MAP in an I-frame playlist")
+		} else {
+			need(6, "EXT-X-MAP")
+		}
+	}
+	return version, feature
+}
+
+// Validate checks that variants carry a bandwidth, that the renditions groups they
+// reference exist with consistent DEFAULT/AUTOSELECT flags and that every attribute
+// can be written quoted.
+func (p *MasterPlaylist) Validate() error {
+	var errs []error
+
+	if p.Version < 1 {
+		errs = append(errs, fmt.Errorf("EXT-X-VERSION must be at least 1, got %d", p.Version))
+	}
+	if len(p.Variants) == 0 {
+		errs = append(errs, errors.New("master playlist has no variants"))
+	}
+
+	groups := map[string]string{}
+	defaults := map[string]int{}
+	names := map[string]bool{}
+	for _, m := range p.Media {
+		for _, attr := range [][2]string{{"GROUP-ID", m.GroupID}, {"NAME", m.Name}, {"LANGUAGE", m.Language}, {"CHANNELS", m.Channels}} {
+			if err := validateQuoted(attr[0], attr[1]); err != nil {
+				errs = append(errs, fmt.Errorf("EXT-X-MEDIA %q: %w", m.Name, err))
+			}
+		}
+		if m.Type != MediaTypeAudio && m.Type != MediaTypeSubtitles {
+			errs = append(errs, fmt.Errorf("EXT-X-MEDIA %q: unsupported TYPE %q", m.Name, m.Type))
+		}
+		if t, ok := groups[m.GroupID]; ok && t != m.Type {
+			errs = append(errs, fmt.Errorf("group %q mixes %s and %s renditions", m.GroupID, t, m.Type))
+		}
+		groups[m.GroupID] = m.Type
+		if names[m.GroupID+"\x00"+m.Name] {
+			errs = append(errs, fmt.Errorf("group %q has two renditions named %q", m.GroupID, m.Name))
+		}
+		names[m.GroupID+"\x00"+m.Name] = true
+		if m.Default {
+			defaults[m.GroupID]++
+			if !m.AutoSelect {
+				errs = append(errs, fmt.Errorf("EXT-X-MEDIA %q: DEFAULT=YES requires AUTOSELECT=YES", m.Name))
+			}
+		}
+		if err := validateURI(m.URI); err != nil {
+			errs = append(errs, fmt.Errorf("EXT-X-MEDIA %q: %w", m.Name, err))
+		}
+		if m.Channels != "" {
+			if err := validateChannels(m); err != nil {
+				errs = append(errs, fmt.Errorf("EXT-X-MEDIA %q: %w", m.Name, err))
+			}
+		}
+	}
+	for group, n := range defaults {
+		if n > 1 {
+			errs = append(errs, fmt.Errorf("group %q has %d DEFAULT renditions", group, n))
+		}
+	}
+
+	for i, v := range p.Variants {
+		if v.Bandwidth <= 0 {
+			errs = append(errs, fmt.Errorf("variant %d (%s) has no BANDWIDTH", i, v.URI))
+		}
+		if err := validateVideoRange(v.VideoRange); err != nil {
+			errs = append(errs, fmt.Errorf("variant %d (%s): %w", i, v.URI, err))
+		}
+		if err := validateQuoted("CODECS", v.Codecs); err != nil {
+			errs = append(errs, fmt.Errorf("variant %d (%s): %w", i, v.URI, err))
+		}
+		if v.Audio != "" && groups[v.Audio] != MediaTypeAudio {
+			errs = append(errs, fmt.Errorf("variant %d (%s) references unknown AUDIO group %q", i, v.URI, v.Audio))
+		}
+		if v.Subtitles != "" && groups[v.Subtitles] != MediaTypeSubtitles {
+			errs = append(errs, fmt.Errorf("variant %d (%s) references unknown SUBTITLES group %q", i, v.URI, v.Subtitles))
+		}
+		if err := validateURI(v.URI); err != nil {
+			errs = append(errs, fmt.Errorf("variant %d: %w", i, err))
+		}
+	}
+	for i, v := range p.IFrameVariants {
+		if v.Bandwidth <= 0 {
+			errs = append(errs, fmt.Errorf("I-frame variant %d (%s) has no BANDWIDTH", i, v.URI))
+		}
+		if err := validateVideoRange(v.VideoRange); err != nil {
+			errs = append(errs, fmt.Errorf("I-frame variant %d (%s): %w", i, v.URI, err))
+		}
+		if err := validateQuoted("CODECS", v.Codecs); err != nil {
+			errs = append(errs, fmt.Errorf("I-frame variant %d (%s): %w", i, v.URI, err))
+		}
+		if err := validateURI(v.URI); err != nil {
+			errs = append(errs, fmt.Errorf("I-frame variant %d: %w", i, err))
+		}
+	}
+
+	if len(p.IFrameVariants) > 0 && p.Version < 4 {
+		errs = append(errs, fmt.Errorf("EXT-X-VERSION %d is lower than the 4 required by EXT-X-I-FRAME-STREAM-INF", p.Version))
+	}
+	return errors.Join(errs...)
+}
+
+func validateVideoRange(v string) error {
+	switch v {
+	case "", VideoRangeSDR, VideoRangePQ, VideoRangeHLG:
+		return nil
+	}
+	return fmt.Errorf("invalid VIDEO-RANGE %q", v)
+}
+
+// validateChannels leaves later slash-separated parameters (16/JOC) alone.
+func validateChannels(m Media) error {
+	if m.Type != MediaTypeAudio {
+		return fmt.Errorf("CHANNELS on a %s rendition", m.Type)
+	}
+	count, _, _ := strings.Cut(m.Channels, "/")
+	if n, err := strconv.Atoi(count); err != nil || n <= 0 {
+		return fmt.Errorf("invalid CHANNELS %q", m.Channels)
+	}
+	return nil
+}
+
+func validateQuoted(name, value string) error {
+	if strings.ContainsAny(value, "\r\n\"") {
+		return fmt.Errorf("%s %q can't be written in a quoted attribute", name, value)
+	}
+	return nil
+}
+
+// ResolveURI resolves a URI found in the playlist stored at playlistKey to a bucket key.
+// Absolute URLs are returned unchanged; relative ones may not climb out of the bucket.
+func ResolveURI(playlistKey, uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid URI %q: %w", uri, err)
+	}
+	if u.IsAbs() {
+		return uri, nil
+	}
+	resolved := path.Join(path.Dir(playlistKey), u.Path)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", fmt.Errorf("URI %q escapes the playlist directory", uri)
+	}
+	return resolved, nil
+}
+
+func validateURI(uri string) error {
+	if uri == "" {
+		return errors.New("empty URI")
+	}
+	if strings.ContainsAny(uri, "\r\n\"") {
+		return fmt.Errorf("URI %q can't be written in a playlist", uri)
+	}
+	_, err := ResolveURI(".", uri)
+	return err
+}
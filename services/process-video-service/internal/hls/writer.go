@@ -0,0 +1,133 @@
+package hls
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+func (p *MasterPlaylist) Encode() []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&b, "#EXT-X-VERSION:%d\n", p.Version)
+
+	for _, m := range p.Media {
+		attrs := []string{
+			"TYPE=" + m.Type,
+			quoted("GROUP-ID", m.GroupID),
+			quoted("NAME", m.Name),
+		}
+		if m.Language != "" {
+			attrs = append(attrs, quoted("LANGUAGE", m.Language))
+		}
+		attrs = append(attrs, "DEFAULT="+yesNo(m.Default), "AUTOSELECT="+yesNo(m.AutoSelect))
+		if m.Type == MediaTypeSubtitles {
+			attrs = append(attrs, "FORCED="+yesNo(m.Forced))
+		}
+		if m.Channels != "" {
+			attrs = append(attrs, quoted("CHANNELS", m.Channels))
+		}
+		attrs = append(attrs, quoted("URI", m.URI))
+		fmt.Fprintf(&b, "#EXT-X-MEDIA:%s\n", strings.Join(attrs, ","))
+	}
+
+	for _, v := range p.Variants {
+		attrs := []string{fmt.Sprintf("BANDWIDTH=%d", v.Bandwidth)}
+		if v.AverageBandwidth > 0 {
+			attrs = append(attrs, fmt.Sprintf("AVERAGE-BANDWIDTH=%d", v.AverageBandwidth))
+		}
+		if v.Width > 0 && v.Height > 0 {
+			attrs = append(attrs, fmt.Sprintf("RESOLUTION=%dx%d", v.Width, v.Height))
+		}
+		if v.Codecs != "" {
+			attrs = append(attrs, quoted("CODECS", v.Codecs))
+		}
+		if v.VideoRange != "" {
+			attrs = append(attrs, "VIDEO-RANGE="+v.VideoRange)
+		}
+		if v.Audio != "" {
+			attrs = append(attrs, quoted("AUDIO", v.Audio))
+		}
+		if v.Subtitles != "" {
+			attrs = append(attrs, quoted("SUBTITLES", v.Subtitles))
+		}
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:%s\n%s\n", strings.Join(attrs, ","), v.URI)
+	}
+
+	for _, v := range p.IFrameVariants {
+		attrs := []string{fmt.Sprintf("BANDWIDTH=%d", v.Bandwidth)}
+		if v.Width > 0 && v.Height > 0 {
+			attrs = append(attrs, fmt.Sprintf("RESOLUTION=%dx%d", v.Width, v.Height))
+		}
+		if v.Codecs != "" {
+			attrs = append(attrs, quoted("CODECS", v.Codecs))
+		}
+		if v.VideoRange != "" {
+			attrs = append(attrs, "VIDEO-RANGE="+v.VideoRange)
+		}
+		attrs = append(attrs, quoted("URI", v.URI))
+		fmt.Fprintf(&b, "#EXT-X-I-FRAME-STREAM-INF:%s\n", strings.Join(attrs, ","))
+	}
+
+	return b.Bytes()
+}
+
+func (p *MediaPlaylist) Encode() []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&b, "#EXT-X-VERSION:%d\n", p.Version)
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", p.TargetDuration)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence)
+	if p.IFramesOnly {
+		b.WriteString("#EXT-X-I-FRAMES-ONLY\n")
+	}
+
+	if p.Map != nil {
+		attrs := []string{quoted("URI", p.Map.URI)}
+		if p.Map.ByteRange != nil {
+			attrs = append(attrs, quoted("BYTERANGE", p.Map.ByteRange.String()))
+		}
+		fmt.Fprintf(&b, "#EXT-X-MAP:%s\n", strings.Join(attrs, ","))
+	}
+
+	if k := p.Key; k != nil {
+		attrs := []string{"METHOD=" + k.Method, quoted("URI", k.URI)}
+		if k.IV != "" {
+			attrs = append(attrs, "IV="+k.IV)
+		}
+		if k.KeyFormat != "" {
+			attrs = append(attrs, quoted("KEYFORMAT", k.KeyFormat))
+		}
+		if k.KeyFormatVersions != "" {
+			attrs = append(attrs, quoted("KEYFORMATVERSIONS", k.KeyFormatVersions))
+		}
+		fmt.Fprintf(&b, "#EXT-X-KEY:%s\n", strings.Join(attrs, ","))
+	}
+
+	for _, s := range p.Segments {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", s.Duration)
+		if s.ByteRange != nil {
+			fmt.Fprintf(&b, "#EXT-X-BYTERANGE:%s\n", s.ByteRange.String())
+		}
+		b.WriteString(s.URI + "\n")
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+
+	return b.Bytes()
+}
+
+// String renders the range as length@offset.
+func (r ByteRange) String() string {
+	return fmt.Sprintf("%d@%d", r.Length, r.Offset)
+}
+
+func quoted(name, value string) string {
+	return fmt.Sprintf(`%s="%s"`, name, value)
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "YES"
+	}
+	return "NO"
+}
@@ -1,11 +1,21 @@
 package interfaces
 
-import "io"
+import (
+	"io"
+
+	"process-video-service/internal/models"
+)
 
 type Bucket interface {
 	UploadFileReader(bucket, key string, body io.Reader) error
+	// UploadStream uploads in parts as body is read, for files too large to buffer.
+	UploadStream(bucket, key string, body io.Reader) error
+	// CreateObject uploads only when key doesn't exist yet, failing with
+	// models.ErrObjectExists otherwise; the check and the write are one request.
+	CreateObject(bucket, key string, body io.Reader) error
 	DeleteObject(bucket, key string) error
 	DeletePrefix(bucket, prefix string) error
 	GetObjectStream(bucket, key string) (io.ReadCloser, error)
 	GetPartOfObjectStream(bucket, key, fileRange string) (io.ReadCloser, error)
+	ListObjects(bucket, prefix string) ([]models.ObjectInfo, error)
 }
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"process-video-service/internal/models"
+)
+
+// KeyStore keeps content keys by episode and key id, so a reprocess stores its key next to
+// the one the published playlists point at. Keys saved before ids existed have an empty
+// key id. Get returns models.ErrKeyNotFound when there is none.
+type KeyStore interface {
+	Save(ctx context.Context, epId, keyId string, key models.ContentKey) error
+	Get(ctx context.Context, epId, keyId string) (*models.ContentKey, error)
+	Delete(ctx context.Context, epId, keyId string) error
+}
@@ -6,6 +6,38 @@ import (
 )
 
 type VideoProcessor interface {
-	Process(ctx context.Context, event models.UploadEvent, resolution int) error
-	GetHeight(ctx context.Context, bucket, key string) (int, error)
+	Process(ctx context.Context, job *models.Job, rung models.Rung) (*models.Rendition, error)
+	ProcessAudio(ctx context.Context, job *models.Job, track models.AudioTrack) (*models.AudioRendition, error)
+	ProcessSubtitle(ctx context.Context, job *models.Job, track models.SubtitleTrack) (*models.SubtitleRendition, error)
+	GenerateThumbnails(ctx context.Context, job *models.Job, opts models.ThumbnailOptions) (*models.ThumbnailAssets, error)
+	GenerateStills(ctx context.Context, job *models.Job, opts models.StillOptions) ([]models.Still, error)
+	// AnalyzeComplexity measures the bitrate every ladder height needs at a constant quality.
+	AnalyzeComplexity(ctx context.Context, job *models.Job, opts models.ComplexityOptions) (*models.ComplexityAnalysis, error)
+	// MeasureQuality scores a published video rendition against the scaled source.
+	MeasureQuality(ctx context.Context, job *models.Job, rendition models.Rendition, opts models.QualityOptions) (*models.QualityScores, error)
+	// AnalyzeQC runs black, silence, freeze and interlace detection over the source.
+	AnalyzeQC(ctx context.Context, job *models.Job) (*models.QCReport, error)
+	// DetectCrop finds the black bars baked into sampled scenes of the source.
+	DetectCrop(ctx context.Context, job *models.Job, opts models.CropOptions) (*models.CropDetection, error)
+	// FingerprintAudio computes the chromaprint of the first seconds of the default audio track.
+	FingerprintAudio(ctx context.Context, job *models.Job, seconds float64) (*models.AudioFingerprint, error)
+	// AnalyzeCredits samples how dark and text-heavy the end of the source is.
+	AnalyzeCredits(ctx context.Context, job *models.Job, seconds float64) (*models.CreditsAnalysis, error)
+	// DetectScenes returns the times of the scene cuts in the source.
+	DetectScenes(ctx context.Context, job *models.Job, threshold float64) ([]float64, error)
+	// GenerateChapters uploads a thumbnail per chapter and the WebVTT chapters track.
+	GenerateChapters(ctx context.Context, job *models.Job, chapters []models.Chapter, opts models.ChapterOptions) (*models.ChapterAssets, error)
+	// ProcessDownload remuxes published segments into a single faststart MP4 for offline viewing.
+	ProcessDownload(ctx context.Context, job *models.Job, plan models.DownloadPlan) (*models.Download, error)
+	// MeasureMotion samples how much the picture changes across the source.
+	MeasureMotion(ctx context.Context, job *models.Job) ([]models.MotionSample, error)
+	// GeneratePreview uploads the hover preview starting at start at every configured width.
+	GeneratePreview(ctx context.Context, job *models.Job, start float64, opts models.PreviewOptions) ([]models.PreviewClip, error)
+	// PrepareOverlay caches the overlay image locally and checks it fits the picture.
+	PrepareOverlay(ctx context.Context, job *models.Job, overlay models.Overlay) (*models.OverlayImage, error)
+	// ExtractClip cuts the planned renditions into a single source file uploaded to bucket/key.
+	ExtractClip(ctx context.Context, plan models.ClipPlan, bucket, key string) (*models.ClipCut, error)
+	Probe(ctx context.Context, bucket, key string) (*models.MediaInfo, error)
+	// VerifySegment decodes a published segment; initKey is empty for MPEG-TS.
+	VerifySegment(ctx context.Context, job *models.Job, key, initKey string) error
 }
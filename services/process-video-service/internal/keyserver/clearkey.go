@@ -0,0 +1,62 @@
+package keyserver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// W3C EME ClearKey JSON; key ids and keys are unpadded base64url.
+type licenseRequest struct {
+	Kids []string `json:"kids"`
+	Type string   `json:"type"`
+}
+
+type licenseResponse struct {
+	Keys []jsonWebKey `json:"keys"`
+	Type string       `json:"type,omitempty"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	K   string `json:"k"`
+}
+
+// License serves POST /keys/{epId}/{keyId}/license, answering a ClearKey license request
+// with the episode key when its KID is among the requested ones.
+func (h *Handler) License(w http.ResponseWriter, r *http.Request) {
+	allowCORS(w)
+	var req licenseRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil || len(req.Kids) == 0 {
+		http.Error(w, "invalid license request", http.StatusBadRequest)
+		return
+	}
+
+	key, ok := h.authorizedKey(w, r)
+	if !ok {
+		return
+	}
+
+	resp := licenseResponse{Keys: []jsonWebKey{}, Type: req.Type}
+	for _, kid := range req.Kids {
+		requested, err := base64.RawURLEncoding.DecodeString(kid)
+		if err != nil || len(key.KID) == 0 || !bytes.Equal(requested, key.KID) {
+			continue
+		}
+		resp.Keys = append(resp.Keys, jsonWebKey{
+			Kty: "oct",
+			Kid: kid,
+			K:   base64.RawURLEncoding.EncodeToString(key.Key),
+		})
+	}
+	if len(resp.Keys) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
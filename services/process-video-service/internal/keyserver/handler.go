@@ -0,0 +1,85 @@
+// Package keyserver delivers content keys to players holding a playback token.
+package keyserver
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"process-video-service/internal/config"
+	"process-video-service/internal/interfaces"
+	"process-video-service/internal/models"
+	"process-video-service/internal/playback"
+)
+
+// Handler serves the key endpoints. The token is read from "Authorization: Bearer",
+// then the "token" query parameter, since not every player can set headers on key requests.
+type Handler struct {
+	store  interfaces.KeyStore
+	secret []byte
+	logger config.Logger
+}
+
+func NewHandler(store interfaces.KeyStore, secret string) *Handler {
+	return &Handler{
+		store:  store,
+		secret: []byte(secret),
+		logger: *config.NewLogger("KeyServer"),
+	}
+}
+
+// Preflight answers the CORS preflight browsers send before a key or license request
+// carrying an Authorization header.
+func (h *Handler) Preflight(w http.ResponseWriter, r *http.Request) {
+	allowCORS(w)
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	w.Header().Set("Access-Control-Max-Age", "86400")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Key serves GET /keys/{epId}/{keyId}: the raw AES-128 key referenced by EXT-X-KEY.
+// Without {keyId} it serves the key of episodes encrypted before keys had ids.
+func (h *Handler) Key(w http.ResponseWriter, r *http.Request) {
+	allowCORS(w)
+	key, ok := h.authorizedKey(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(key.Key)
+}
+
+// authorizedKey writes the error response itself when it can't load the key.
+func (h *Handler) authorizedKey(w http.ResponseWriter, r *http.Request) (*models.ContentKey, bool) {
+	epId, keyId := r.PathValue("epId"), r.PathValue("keyId")
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if err := playback.Verify(h.secret, token, epId, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+
+	key, err := h.store.Get(r.Context(), epId, keyId)
+	if errors.Is(err, models.ErrKeyNotFound) {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Error("Erro lendo chave:", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return key, true
+}
+
+// players are served from other origins; the token, not the origin, authorizes a request
+func allowCORS(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+}
@@ -0,0 +1,159 @@
+package keyserver_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"process-video-service/internal/keyserver"
+	"process-video-service/internal/models"
+	"process-video-service/internal/playback"
+	"process-video-service/tests/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var secret = []byte("secret")
+
+var episodeKey = &models.ContentKey{
+	KID: []byte("0123456789abcdef"),
+	Key: []byte("fedcba9876543210"),
+	IV:  []byte("aaaaaaaaaaaaaaaa"),
+}
+
+// saved before keys had ids
+var legacyKey = &models.ContentKey{
+	Key: []byte("1111111111111111"),
+	IV:  []byte("2222222222222222"),
+}
+
+func newServer() *http.ServeMux {
+	store := new(mocks.MockKeyStore)
+	store.On("Get", mock.Anything, "ep123", "k1").Return(episodeKey, nil)
+	store.On("Get", mock.Anything, "ep123", "").Return(legacyKey, nil)
+	store.On("Get", mock.Anything, "ep123", "k0").Return(nil, models.ErrKeyNotFound)
+	store.On("Get", mock.Anything, "ep404", "k1").Return(nil, models.ErrKeyNotFound)
+	store.On("Get", mock.Anything, "ep500", "k1").Return(nil, errors.New("database down"))
+
+	keys := keyserver.NewHandler(store, string(secret))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys/{epId}/{keyId}", keys.Key)
+	mux.HandleFunc("POST /keys/{epId}/{keyId}/license", keys.License)
+	mux.HandleFunc("OPTIONS /keys/{epId}/{keyId}", keys.Preflight)
+	mux.HandleFunc("OPTIONS /keys/{epId}/{keyId}/license", keys.Preflight)
+	mux.HandleFunc("GET /keys/{epId}", keys.Key)
+	mux.HandleFunc("POST /keys/{epId}/license", keys.License)
+	mux.HandleFunc("OPTIONS /keys/{epId}", keys.Preflight)
+	return mux
+}
+
+func token(epId string, ttl time.Duration) string {
+	return playback.Sign(secret, epId, time.Now().Add(ttl))
+}
+
+func TestKey_Auth(t *testing.T) {
+	mux := newServer()
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		status int
+		key    []byte
+	}{
+		{"bearer header", "/keys/ep123/k1", "Bearer " + token("ep123", time.Hour), http.StatusOK, episodeKey.Key},
+		{"query parameter", "/keys/ep123/k1?token=" + token("ep123", time.Hour), "", http.StatusOK, episodeKey.Key},
+		{"key without id", "/keys/ep123", "Bearer " + token("ep123", time.Hour), http.StatusOK, legacyKey.Key},
+		{"no token", "/keys/ep123/k1", "", http.StatusUnauthorized, nil},
+		{"expired token", "/keys/ep123/k1", "Bearer " + token("ep123", -time.Hour), http.StatusUnauthorized, nil},
+		{"token for another episode", "/keys/ep123/k1", "Bearer " + token("ep456", time.Hour), http.StatusUnauthorized, nil},
+		{"deleted key", "/keys/ep123/k0", "Bearer " + token("ep123", time.Hour), http.StatusNotFound, nil},
+		{"no key", "/keys/ep404/k1", "Bearer " + token("ep404", time.Hour), http.StatusNotFound, nil},
+		{"store error", "/keys/ep500/k1", "Bearer " + token("ep500", time.Hour), http.StatusInternalServerError, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.key, rec.Body.Bytes())
+				assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+			} else {
+				assert.NotEqual(t, episodeKey.Key, rec.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestLicense(t *testing.T) {
+	mux := newServer()
+	kid := base64.RawURLEncoding.EncodeToString(episodeKey.KID)
+	other := base64.RawURLEncoding.EncodeToString([]byte("ffffffffffffffff"))
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{"requested kid", token("ep123", time.Hour), `{"kids":["` + other + `","` + kid + `"],"type":"temporary"}`, http.StatusOK},
+		{"unknown kid", token("ep123", time.Hour), `{"kids":["` + other + `"]}`, http.StatusNotFound},
+		{"no kids", token("ep123", time.Hour), `{"kids":[]}`, http.StatusBadRequest},
+		{"invalid token", "1.abc", `{"kids":["` + kid + `"]}`, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/keys/ep123/k1/license", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Keys []map[string]string `json:"keys"`
+				Type string              `json:"type"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "temporary", resp.Type)
+			assert.Equal(t, []map[string]string{{
+				"kty": "oct",
+				"kid": kid,
+				"k":   base64.RawURLEncoding.EncodeToString(episodeKey.Key),
+			}}, resp.Keys)
+		})
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	mux := newServer()
+
+	for _, target := range []string{"/keys/ep123/k1", "/keys/ep123/k1/license", "/keys/ep123", "/keys/ep123/license"} {
+		req := httptest.NewRequest(http.MethodOptions, target, nil)
+		req.Header.Set("Origin", "https://player.test")
+		req.Header.Set("Access-Control-Request-Headers", "authorization")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code, target)
+		assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"), target)
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Authorization", target)
+	}
+}
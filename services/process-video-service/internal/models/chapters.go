@@ -0,0 +1,31 @@
+package models
+
+// Where the chapters of an episode came from.
+const (
+	ChapterSourceEmbedded = "embedded"
+	ChapterSourceScenes   = "scenes"
+)
+
+type ChapterOptions struct {
+	// MinSeconds is the shortest chapter scene cuts are merged into.
+	MinSeconds float64
+	// SceneThreshold is the scene change score (0 to 1) that counts as a cut.
+	SceneThreshold float64
+	// ThumbnailWidth is the width of each chapter's thumbnail.
+	ThumbnailWidth int
+}
+
+type Chapter struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Title string  `json:"title"`
+	// ThumbnailKey is empty when no usable frame was found in the chapter.
+	ThumbnailKey string `json:"thumbnailKey,omitempty"`
+}
+
+// ChapterAssets are the chapters of an episode, also published as a WebVTT chapters track.
+type ChapterAssets struct {
+	Source   string    `json:"source"`
+	VTTKey   string    `json:"vttKey"`
+	Chapters []Chapter `json:"chapters"`
+}
@@ -0,0 +1,47 @@
+package models
+
+const (
+	// ClipModeReencode trims to the exact frame and encodes the ladder again.
+	ClipModeReencode = "reencode"
+	// ClipModeCopy moves the in and out points to the segment boundaries around them
+	// and repackages the tallest SDR rendition without decoding it. Audio is still
+	// encoded: it's cheap and gives surround tracks their stereo downmix back.
+	ClipModeCopy = "copy"
+)
+
+// ClipRequestEvent asks for a new episode cut out of an already processed one.
+type ClipRequestEvent struct {
+	SourceEpId string `json:"source_episode_id"`
+	// EpId is the episode the clip is published as; it must not exist yet.
+	EpId string `json:"episode_id"`
+	// In and Out are seconds of the source episode; Out 0 runs to its end.
+	In  float64 `json:"in"`
+	Out float64 `json:"out,omitempty"`
+	// Mode is ClipModeReencode (the default) or ClipModeCopy.
+	Mode string `json:"mode,omitempty"`
+}
+
+// ClipInput is one rendition of the source episode, limited to the segments covering
+// the clip. Its keys, concatenated, are a playable file from Start to End.
+type ClipInput struct {
+	Keys  []string
+	Start float64
+	End   float64
+	// Track is empty for the video input.
+	Track AudioTrack
+}
+
+type ClipPlan struct {
+	Video ClipInput
+	Audio []ClipInput
+	In    float64
+	Out   float64
+	Mode  string
+}
+
+type ClipCut struct {
+	SourceEpId string  `json:"sourceEpId"`
+	Mode       string  `json:"mode"`
+	In         float64 `json:"in"`
+	Out        float64 `json:"out"`
+}
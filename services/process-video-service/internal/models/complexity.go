@@ -0,0 +1,37 @@
+package models
+
+// ComplexityOptions drives the per-title analysis: Samples scenes of SampleSeconds
+// each are encoded at every ladder height with a constant CRF.
+type ComplexityOptions struct {
+	Samples       int
+	SampleSeconds float64
+	CRF           int
+}
+
+// ComplexityAnalysis is what the probe encode measured for one source.
+type ComplexityAnalysis struct {
+	CRF            int               `json:"crf"`
+	SampledSeconds float64           `json:"sampledSeconds"`
+	Probes         []ComplexityProbe `json:"probes"`
+}
+
+// ComplexityProbe is the bitrate a height needed to reach the probe CRF.
+type ComplexityProbe struct {
+	Height  int `json:"height"`
+	Bitrate int `json:"bitrate"`
+}
+
+// LadderDecision records the ladder that was encoded and why.
+type LadderDecision struct {
+	PerTitle bool                `json:"perTitle"`
+	Analysis *ComplexityAnalysis `json:"analysis,omitempty"`
+	Rungs    []LadderRung        `json:"rungs"`
+}
+
+type LadderRung struct {
+	Height     int    `json:"height"`
+	Codec      string `json:"codec"`
+	MaxBitrate int    `json:"maxBitrate,omitempty"`
+	Dropped    bool   `json:"dropped,omitempty"`
+	Reason     string `json:"reason"`
+}
@@ -0,0 +1,37 @@
+package models
+
+const (
+	CropSourceDetected = "detected"
+	CropSourceOverride = "override"
+)
+
+// Crop is a rectangle in source pixels.
+type Crop struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	X      int `json:"x"`
+	Y      int `json:"y"`
+}
+
+// CropOverride replaces crop detection for one upload: Off keeps the full frame, Rect
+// is applied as given.
+type CropOverride struct {
+	Off  bool  `json:"off,omitempty"`
+	Rect *Crop `json:"rect,omitempty"`
+}
+
+// CropOptions drives cropdetect: Samples scenes of SampleSeconds each.
+type CropOptions struct {
+	Samples       int
+	SampleSeconds float64
+}
+
+// CropDetection is the crop decision recorded in the manifest. Confidence is the share
+// of sampled frames that agreed on Rect.
+type CropDetection struct {
+	Rect       Crop    `json:"rect"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Source     string  `json:"source"`
+	Applied    bool    `json:"applied"`
+	Reason     string  `json:"reason,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+// Download is a single faststart MP4 of one ladder rung, with the default audio track
+// in stereo, for clients that save episodes for offline viewing.
+type Download struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Key    string `json:"key"`
+	// Size is in bytes and Bitrate in bits per second, over the whole file, so clients
+	// can tell how much a download will take before starting it.
+	Size     int64   `json:"size"`
+	Bitrate  int     `json:"bitrate"`
+	Duration float64 `json:"duration"`
+}
+
+// DownloadsOmitted values say why an episode has no downloads.
+const (
+	DownloadsOmittedEncrypted = "encrypted"
+	DownloadsOmittedNoRung    = "no_rung"
+)
+
+// DownloadPlan lists the published segments a download is remuxed from: those of one
+// video rendition and of the default stereo audio rendition, when there is audio.
+type DownloadPlan struct {
+	Name      string
+	VideoKeys []string
+	AudioKeys []string
+}
@@ -0,0 +1,32 @@
+package models
+
+import "errors"
+
+const (
+	EncryptionNone   = "none"
+	EncryptionAES128 = "aes-128"
+	EncryptionCENC   = "cenc"
+)
+
+var ErrKeyNotFound = errors.New("content key not found")
+
+// ContentKey is the per-episode key material kept in the key store. KID identifies
+// the key in CENC segments and ClearKey license requests.
+type ContentKey struct {
+	KID []byte
+	Key []byte
+	IV  []byte
+}
+
+// Encryption is what the encoder needs to encrypt a job's segments and
+// point players at the key-delivery endpoint.
+type Encryption struct {
+	ContentKey
+	// Scheme is EncryptionAES128 or EncryptionCENC.
+	Scheme string
+	// KeyID is the key's id in the key store, part of both URIs.
+	KeyID string
+	// KeyURI serves the raw key (AES-128); LicenseURL is the ClearKey license endpoint (CENC).
+	KeyURI     string
+	LicenseURL string
+}
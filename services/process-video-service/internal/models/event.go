@@ -1,9 +1,32 @@
 package models
 
+// SuccessEventVersion is bumped whenever UploadSuccessEvent changes in a way consumers must know about.
+const SuccessEventVersion = 2
+
 type UploadEvent struct {
 	Bucket string `json:"bucket"`
 	Key    string `json:"object_key"`
 	EpId   string `json:"episode_id"`
+	// Packaging overrides the configured packaging for this upload.
+	Packaging *PackagingOverride `json:"packaging,omitempty"`
+	// Crop overrides crop detection for this upload.
+	Crop *CropOverride `json:"crop,omitempty"`
+	// Overlay is burned into every video rendition of this upload.
+	Overlay *Overlay `json:"overlay,omitempty"`
+	// PreviewAt is where the hover preview starts, in seconds, instead of the
+	// window with the most motion.
+	PreviewAt *float64 `json:"preview_at,omitempty"`
+	// SeasonId groups the episodes whose audio is compared to find the intro.
+	SeasonId string `json:"season_id,omitempty"`
+	// Clip is set by clip jobs on the cut they stage; it is never read from the queue.
+	Clip *ClipCut `json:"-"`
+}
+
+// MarkersUpdatedEvent carries markers found for an episode that was already published,
+// while processing another one; the episode's manifest.json stays as it was published.
+type MarkersUpdatedEvent struct {
+	EpId    string   `json:"epId"`
+	Markers *Markers `json:"markers"`
 }
 
 type UploadFailedEvent struct {
@@ -13,8 +36,34 @@ type UploadFailedEvent struct {
 	Reason string `json:"reason"`
 }
 
+// UploadSuccessEvent is published once an episode is packaged; the same document is
+// stored as manifest.json next to the master playlist.
 type UploadSuccessEvent struct {
-	EpId   string `json:"epId"`
-	Key    string `json:"key"`
-	Bucket string `json:"bucket"`
+	Version               int                 `json:"version"`
+	EpId                  string              `json:"epId"`
+	Key                   string              `json:"key"`
+	Bucket                string              `json:"bucket"`
+	MasterPlaylistKey     string              `json:"masterPlaylistKey"`
+	DashManifestKey       string              `json:"dashManifestKey,omitempty"`
+	ManifestKey           string              `json:"manifestKey"`
+	Packaging             Packaging           `json:"packaging"`
+	Duration              float64             `json:"duration"`
+	Source                SourceSummary       `json:"source"`
+	Renditions            []Rendition         `json:"renditions"`
+	AudioTracks           []AudioRendition    `json:"audioTracks"`
+	Subtitles             []SubtitleRendition `json:"subtitles"`
+	Thumbnails            *ThumbnailAssets    `json:"thumbnails,omitempty"`
+	Stills                []Still             `json:"stills,omitempty"`
+	Ladder                *LadderDecision     `json:"ladder,omitempty"`
+	QC                    *QCReport           `json:"qc,omitempty"`
+	Crop                  *CropDetection      `json:"crop,omitempty"`
+	Markers               *Markers            `json:"markers,omitempty"`
+	Chapters              *ChapterAssets      `json:"chapters,omitempty"`
+	Downloads             []Download          `json:"downloads,omitempty"`
+	DownloadsOmitted      string              `json:"downloadsOmitted,omitempty"`
+	Preview               *PreviewAssets      `json:"preview,omitempty"`
+	Overlay               *Overlay            `json:"overlay,omitempty"`
+	Clip                  *ClipCut            `json:"clip,omitempty"`
+	TotalBytes            int64               `json:"totalBytes"`
+	ProcessingTimeSeconds float64             `json:"processingTimeSeconds"`
 }
@@ -0,0 +1,67 @@
+package models
+
+const (
+	SegmentTypeMPEGTS = "mpegts"
+	SegmentTypeFMP4   = "fmp4"
+)
+
+// Packaging selects how renditions are segmented and which manifests are written.
+type Packaging struct {
+	// SegmentType is either mpegts (HLS v3) or fmp4 (CMAF, HLS v7).
+	SegmentType string `json:"segment_type,omitempty"`
+	// Dash writes a manifest.mpd over the same CMAF segments; it requires fmp4.
+	Dash bool `json:"dash,omitempty"`
+	// Encryption is none, aes-128 (whole-segment, MPEG-TS only) or cenc (CMAF, ClearKey).
+	Encryption string `json:"encryption,omitempty"`
+}
+
+// PackagingOverride replaces the configured packaging for one upload; unset fields
+// keep the configured value.
+type PackagingOverride struct {
+	SegmentType string `json:"segment_type,omitempty"`
+	Dash        *bool  `json:"dash,omitempty"`
+	Encryption  string `json:"encryption,omitempty"`
+}
+
+// Job is the resolved work for one upload: the event plus everything decided
+// before encoding starts.
+type Job struct {
+	Event     UploadEvent
+	Info      *MediaInfo
+	Packaging Packaging
+	Ladder    []Rung
+	// Encryption is nil unless Packaging.Encryption asks for it.
+	Encryption *Encryption
+	// Deinterlace is the filter (yadif, bwdif) QC chose for an interlaced source.
+	Deinterlace string
+	// Crop removes baked-in black bars before scaling; nil keeps the full frame.
+	Crop *Crop
+	// Loudness normalizes every audio track; nil passes levels through.
+	Loudness *LoudnessTarget
+	// Overlay is composited onto every video rendition; nil leaves the picture alone.
+	Overlay *OverlayImage
+	// SurroundCodec encodes the multichannel audio renditions (eac3, ac3 or aac).
+	SurroundCodec string
+}
+
+const (
+	CodecH264 = "h264"
+	CodecHEVC = "hevc"
+	CodecAV1  = "av1"
+)
+
+// Rung is one step of the video ladder.
+type Rung struct {
+	Height int
+	Codec  string
+	// IFrames asks for a byte-range I-frame playlist next to the rendition.
+	IFrames bool
+	// HDR keeps the source's PQ/HLG range in 10-bit instead of tone mapping to SDR.
+	HDR bool
+	// MaxBitrate caps the encoder (bits/s) when per-title encoding picked one; 0 keeps
+	// the codec's plain constant quality.
+	MaxBitrate int
+	// Copy repackages the source's video stream without encoding it; only copy-mode
+	// clips have such a rung.
+	Copy bool
+}
@@ -0,0 +1,23 @@
+package models
+
+// LoudnessTarget is what every audio track is normalized to: integrated loudness in
+// LUFS, maximum true peak in dBTP and loudness range in LU (EBU R128 is -23/-1,
+// ATSC A/85 is -24/-2).
+type LoudnessTarget struct {
+	Integrated float64
+	TruePeak   float64
+	Range      float64
+}
+
+// Loudness is the first-pass measurement of a source audio track and how it was
+// normalized. Linear is false when reaching the target loudness would have pushed the
+// true peak over its limit and loudnorm had to compress instead.
+type Loudness struct {
+	Integrated       float64 `json:"integrated"`
+	TruePeak         float64 `json:"truePeak"`
+	Range            float64 `json:"range"`
+	Threshold        float64 `json:"threshold"`
+	TargetIntegrated float64 `json:"targetIntegrated"`
+	TargetTruePeak   float64 `json:"targetTruePeak"`
+	Linear           bool    `json:"linear"`
+}
@@ -0,0 +1,45 @@
+package models
+
+// MarkerOptions bound how much of the episode the intro and credits searches look at.
+type MarkerOptions struct {
+	// IntroSeconds of the start are searched for the intro.
+	IntroSeconds float64
+	// CreditsSeconds of the end are scanned for the credits.
+	CreditsSeconds float64
+}
+
+// AudioFingerprint is the chromaprint of an episode. One is kept per episode of a
+// season so later episodes can find the intro they share and the clips they recap.
+type AudioFingerprint struct {
+	EpId string `json:"epId"`
+	// Interval is the duration, in seconds, each point covers.
+	Interval float64  `json:"interval"`
+	Points   []uint32 `json:"points"`
+}
+
+// CreditsAnalysis is what the end of the episode looks like, sampled once per second,
+// with the black intervals blackdetect found there.
+type CreditsAnalysis struct {
+	Frames []CreditsFrame
+	Black  []QCInterval
+}
+
+type CreditsFrame struct {
+	Time float64
+	// Dark is the share of pixels under the black threshold, 0 to 1.
+	Dark float64
+	// Edges is the mean luma of the edge-detected frame: text raises it, flat black doesn't.
+	Edges float64
+}
+
+// Markers are the points players use for "Skip recap", "Skip intro" and "Next episode".
+// A marker that wasn't found is left out.
+type Markers struct {
+	RecapStart   *float64 `json:"recap_start,omitempty"`
+	RecapEnd     *float64 `json:"recap_end,omitempty"`
+	IntroStart   *float64 `json:"intro_start,omitempty"`
+	IntroEnd     *float64 `json:"intro_end,omitempty"`
+	CreditsStart *float64 `json:"credits_start,omitempty"`
+	// IntroMatchedEpId is the episode of the season the intro was found in as well.
+	IntroMatchedEpId string `json:"intro_matched_episode_id,omitempty"`
+}
@@ -0,0 +1,102 @@
+package models
+
+// Video ranges, named as the HLS VIDEO-RANGE attribute names them.
+const (
+	VideoRangeSDR = "SDR"
+	VideoRangePQ  = "PQ"
+	VideoRangeHLG = "HLG"
+)
+
+type MediaInfo struct {
+	Container  string
+	VideoCodec string
+	Width      int
+	Height     int
+	FrameRate  float64
+	Bitrate    int
+	Duration   float64
+	// DurationEstimated is set when Duration is ffprobe's guess from the start of the
+	// source rather than a measurement of all of it.
+	DurationEstimated bool
+	// ColorTransfer and ColorPrimaries are ffprobe's names (smpte2084, bt2020);
+	// VideoRange is derived from the transfer.
+	ColorTransfer  string
+	ColorPrimaries string
+	VideoRange     string
+	AudioTracks    []AudioTrack
+	SubtitleTracks []SubtitleTrack
+	// Chapters are the ones embedded in the source, if any.
+	Chapters []Chapter
+}
+
+// HDR reports whether the source needs tone mapping for SDR renditions.
+func (m *MediaInfo) HDR() bool {
+	return m.VideoRange == VideoRangePQ || m.VideoRange == VideoRangeHLG
+}
+
+// Codecs of the multichannel audio renditions; none keeps only the stereo downmix.
+const (
+	SurroundCodecNone = "none"
+	SurroundCodecAAC  = "aac"
+	SurroundCodecAC3  = "ac3"
+	SurroundCodecEAC3 = "eac3"
+)
+
+type AudioTrack struct {
+	// Index is the position of the stream among the source audio streams (0:a:N).
+	Index    int    `json:"index"`
+	Language string `json:"language,omitempty"`
+	Name     string `json:"name"`
+	Default  bool   `json:"default"`
+	// Channels is the source's channel count when probed and the rendition's once packaged.
+	Channels int `json:"channels,omitempty"`
+	// ChannelLayout is ffprobe's layout name (stereo, 5.1(side)), used to pick the downmix.
+	ChannelLayout string `json:"-"`
+	// Surround marks the multichannel rendition packaged next to a track's stereo downmix.
+	Surround bool `json:"surround,omitempty"`
+}
+
+type SubtitleTrack struct {
+	// Index is the position of the track in the output (subtitles/<Index>/).
+	Index int `json:"index"`
+	// StreamIndex is the position among the source subtitle streams (0:s:N); ignored for sidecars.
+	StreamIndex int `json:"-"`
+	// SidecarKey is set when the track comes from a .srt/.vtt file uploaded next to the video.
+	SidecarKey string `json:"sidecarKey,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Name       string `json:"name"`
+	Forced     bool   `json:"forced"`
+}
+
+type ThumbnailOptions struct {
+	Interval float64
+	Width    int
+	Columns  int
+	Rows     int
+	Format   string
+}
+
+type ThumbnailAssets struct {
+	VTTKey     string   `json:"vttKey"`
+	SpriteKeys []string `json:"spriteKeys"`
+	Interval   float64  `json:"interval"`
+	Width      int      `json:"width"`
+	Height     int      `json:"height"`
+}
+
+type StillOptions struct {
+	Samples    int
+	Candidates int
+	Widths     []int
+}
+
+type Still struct {
+	Time   float64      `json:"time"`
+	Score  float64      `json:"score"`
+	Images []StillImage `json:"images"`
+}
+
+type StillImage struct {
+	Width int    `json:"width"`
+	Key   string `json:"key"`
+}
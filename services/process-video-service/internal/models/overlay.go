@@ -0,0 +1,32 @@
+package models
+
+const (
+	OverlayTopLeft     = "top-left"
+	OverlayTopRight    = "top-right"
+	OverlayBottomLeft  = "bottom-left"
+	OverlayBottomRight = "bottom-right"
+)
+
+// Overlay burns an image from the upload bucket (a channel bug, a partner logo) into
+// every video rendition and hover preview. Sizes are in source pixels: the image and its margin shrink
+// with each rung so the overlay covers the same share of the picture everywhere.
+type Overlay struct {
+	ImageKey string `json:"image_key"`
+	// Corner is top-left, top-right, bottom-left or bottom-right; top-right when empty.
+	Corner string `json:"corner,omitempty"`
+	// Margin is the distance to both edges of the corner.
+	Margin int `json:"margin,omitempty"`
+	// Opacity goes from 0 to 1; unset is fully opaque.
+	Opacity float64 `json:"opacity,omitempty"`
+	// Start and End, in seconds, limit when the overlay shows; End 0 keeps it to the end.
+	Start float64 `json:"start,omitempty"`
+	End   float64 `json:"end,omitempty"`
+}
+
+// OverlayImage is a validated overlay with the local copy of its image.
+type OverlayImage struct {
+	Overlay
+	Path   string
+	Width  int
+	Height int
+}
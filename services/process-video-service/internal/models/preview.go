@@ -0,0 +1,34 @@
+package models
+
+// Where the preview window of an episode came from.
+const (
+	PreviewSourceMotion   = "motion"
+	PreviewSourceOperator = "operator"
+)
+
+type PreviewOptions struct {
+	// Seconds is the length of the preview.
+	Seconds float64
+	// Widths are the sizes the preview is rendered at; the source is never upscaled.
+	Widths []int
+}
+
+// MotionSample is how much the picture changed at one sampled frame, 0 to 1.
+type MotionSample struct {
+	Time  float64
+	Score float64
+}
+
+// PreviewAssets is the muted hover preview of an episode, as MP4 and animated WebP.
+type PreviewAssets struct {
+	Source   string        `json:"source"`
+	Start    float64       `json:"start"`
+	Duration float64       `json:"duration"`
+	Clips    []PreviewClip `json:"clips"`
+}
+
+type PreviewClip struct {
+	Width   int    `json:"width"`
+	MP4Key  string `json:"mp4Key"`
+	WebPKey string `json:"webpKey"`
+}
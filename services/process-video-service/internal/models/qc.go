@@ -0,0 +1,36 @@
+package models
+
+const (
+	DeinterlaceNone  = "off"
+	DeinterlaceYadif = "yadif"
+	DeinterlaceBwdif = "bwdif"
+)
+
+// QCReport is what the QC pass found in the source; times are in seconds from the start.
+type QCReport struct {
+	Black   []QCInterval `json:"black"`
+	Silence []QCInterval `json:"silence"`
+	Freeze  []QCInterval `json:"freeze"`
+	// BlackRatio and SilenceRatio are the share of the duration covered by each.
+	BlackRatio   float64     `json:"blackRatio"`
+	SilenceRatio float64     `json:"silenceRatio"`
+	Interlace    QCInterlace `json:"interlace"`
+	// Deinterlace is the filter applied to the renditions, empty when none was needed.
+	Deinterlace string   `json:"deinterlace,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+type QCInterval struct {
+	Start    float64 `json:"start"`
+	End      float64 `json:"end"`
+	Duration float64 `json:"duration"`
+}
+
+// QCInterlace holds the idet multi-frame counts.
+type QCInterlace struct {
+	TFF          int  `json:"tff"`
+	BFF          int  `json:"bff"`
+	Progressive  int  `json:"progressive"`
+	Undetermined int  `json:"undetermined"`
+	Interlaced   bool `json:"interlaced"`
+}
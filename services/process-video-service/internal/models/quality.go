@@ -0,0 +1,18 @@
+package models
+
+// QualityOptions selects the metrics computed against the source.
+type QualityOptions struct {
+	// VMAF is computed only when the local ffmpeg build has libvmaf.
+	VMAF bool
+}
+
+// QualityScores are full-reference scores of a rendition against the source scaled
+// to the rendition size; VMAF is 0 when it wasn't computed.
+type QualityScores struct {
+	PSNR float64 `json:"psnr"`
+	SSIM float64 `json:"ssim"`
+	VMAF float64 `json:"vmaf,omitempty"`
+	// Flagged is set when a score fell below its configured minimum; Reasons says which.
+	Flagged bool     `json:"flagged,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import "errors"
+
+type Rendition struct {
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// VideoCodec is the ladder codec (h264, hevc, av1); empty for audio.
+	VideoCodec string `json:"videoCodec,omitempty"`
+	// VideoRange is SDR, PQ or HLG; empty for audio.
+	VideoRange string `json:"videoRange,omitempty"`
+	// Codecs is the RFC 6381 codec string used in the master playlist (avc1.640028, mp4a.40.2).
+	Codecs string `json:"codecs"`
+	// Bitrate and PeakBitrate are in bits per second, measured over the uploaded segments.
+	Bitrate     int     `json:"bitrate"`
+	PeakBitrate int     `json:"peakBitrate"`
+	Duration    float64 `json:"duration"`
+	Bytes       int64   `json:"bytes"`
+	PlaylistKey string  `json:"playlistKey"`
+	// InitKey is the fMP4 initialization segment, empty for MPEG-TS renditions.
+	InitKey string `json:"initKey,omitempty"`
+	// IFramePlaylistKey is the byte-range I-frame playlist for trick play; IFrameBitrate is its peak.
+	IFramePlaylistKey string `json:"iFramePlaylistKey,omitempty"`
+	IFrameBitrate     int    `json:"iFrameBitrate,omitempty"`
+	// Quality is only measured when quality metrics are enabled.
+	Quality  *QualityScores `json:"quality,omitempty"`
+	Segments []Segment      `json:"-"`
+}
+
+type Segment struct {
+	Name     string
+	Duration float64
+	Size     int64
+	// IFrames are the keyframes found in the segment, as byte ranges within it.
+	IFrames []IFrame
+}
+
+type IFrame struct {
+	Offset   int64
+	Length   int64
+	Duration float64
+}
+
+type AudioRendition struct {
+	AudioTrack
+	Rendition
+	// Loudness is the source track's measurement, set when loudness normalization is on.
+	Loudness *Loudness `json:"loudness,omitempty"`
+}
+
+type SubtitleRendition struct {
+	SubtitleTrack
+	PlaylistKey string `json:"playlistKey"`
+	// FileKey is the whole track as a single WebVTT file, written for DASH.
+	FileKey string `json:"fileKey,omitempty"`
+}
+
+type SourceSummary struct {
+	Container       string  `json:"container"`
+	VideoCodec      string  `json:"videoCodec"`
+	Width           int     `json:"width"`
+	Height          int     `json:"height"`
+	FrameRate       float64 `json:"frameRate"`
+	Bitrate         int     `json:"bitrate"`
+	Duration        float64 `json:"duration"`
+	ColorTransfer   string  `json:"colorTransfer,omitempty"`
+	ColorPrimaries  string  `json:"colorPrimaries,omitempty"`
+	VideoRange      string  `json:"videoRange,omitempty"`
+	AudioStreams    int     `json:"audioStreams"`
+	SubtitleStreams int     `json:"subtitleStreams"`
+}
+
+var ErrObjectExists = errors.New("object already exists")
+
+type ObjectInfo struct {
+	Key  string
+	Size int64
+	ETag string
+}
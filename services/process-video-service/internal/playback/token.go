@@ -0,0 +1,50 @@
+// Package playback signs and checks the tokens players present to fetch content keys.
+//
+// A token is "<unix expiry>.<base64url HMAC-SHA256(epId + "." + expiry)>", so any
+// service holding the shared secret can mint one for an episode.
+package playback
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid playback token")
+	ErrExpiredToken = errors.New("expired playback token")
+)
+
+func Sign(secret []byte, epId string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + base64.RawURLEncoding.EncodeToString(mac(secret, epId, exp))
+}
+
+func Verify(secret []byte, token, epId string, now time.Time) error {
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac(secret, epId, exp)) {
+		return ErrInvalidToken
+	}
+	if now.Unix() > expires {
+		return ErrExpiredToken
+	}
+	return nil
+}
+
+func mac(secret []byte, epId, exp string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(epId + "." + exp))
+	return h.Sum(nil)
+}
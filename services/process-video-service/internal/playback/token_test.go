@@ -0,0 +1,40 @@
+package playback_test
+
+import (
+	"testing"
+	"time"
+
+	"process-video-service/internal/playback"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1700000000, 0)
+	token := playback.Sign(secret, "ep123", now.Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		secret []byte
+		token  string
+		epId   string
+		now    time.Time
+		want   error
+	}{
+		{"valid", secret, token, "ep123", now, nil},
+		{"valid until the expiry second", secret, token, "ep123", now.Add(time.Hour), nil},
+		{"expired", secret, token, "ep123", now.Add(time.Hour + time.Second), playback.ErrExpiredToken},
+		{"other episode", secret, token, "ep456", now, playback.ErrInvalidToken},
+		{"other secret", []byte("other"), token, "ep123", now, playback.ErrInvalidToken},
+		{"tampered expiry", secret, "9" + token, "ep123", now, playback.ErrInvalidToken},
+		{"no signature", secret, "1700003600", "ep123", now, playback.ErrInvalidToken},
+		{"bad expiry", secret, "soon.abc", "ep123", now, playback.ErrInvalidToken},
+		{"empty", secret, "", "ep123", now, playback.ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, playback.Verify(tt.secret, tt.token, tt.epId, tt.now), tt.want)
+		})
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS content_keys (
+    episode_id VARCHAR(255) PRIMARY KEY,
+    key BYTEA NOT NULL,
+    iv BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
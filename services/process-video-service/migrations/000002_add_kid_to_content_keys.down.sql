@@ -0,0 +1 @@
+ALTER TABLE content_keys DROP COLUMN IF EXISTS kid;
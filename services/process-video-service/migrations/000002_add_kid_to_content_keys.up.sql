@@ -0,0 +1 @@
+ALTER TABLE content_keys ADD COLUMN IF NOT EXISTS kid BYTEA;
@@ -0,0 +1,5 @@
+DELETE FROM content_keys a USING content_keys b
+WHERE a.episode_id = b.episode_id AND (a.updated_at, a.key_id) < (b.updated_at, b.key_id);
+ALTER TABLE content_keys DROP CONSTRAINT IF EXISTS content_keys_pkey;
+ALTER TABLE content_keys ADD PRIMARY KEY (episode_id);
+ALTER TABLE content_keys DROP COLUMN IF EXISTS key_id;
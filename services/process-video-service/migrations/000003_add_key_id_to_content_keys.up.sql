@@ -0,0 +1,3 @@
+ALTER TABLE content_keys ADD COLUMN IF NOT EXISTS key_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE content_keys DROP CONSTRAINT IF EXISTS content_keys_pkey;
+ALTER TABLE content_keys ADD PRIMARY KEY (episode_id, key_id);
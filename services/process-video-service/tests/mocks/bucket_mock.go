@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"io"
+	"process-video-service/internal/models"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -12,6 +13,14 @@ func (m *MockBucket) UploadFileReader(bucket, key string, body io.Reader) error
 	args := m.Called(bucket, key, body)
 	return args.Error(0)
 }
+func (m *MockBucket) CreateObject(bucket, key string, body io.Reader) error {
+	args := m.Called(bucket, key, body)
+	return args.Error(0)
+}
+func (m *MockBucket) UploadStream(bucket, key string, body io.Reader) error {
+	args := m.Called(bucket, key, body)
+	return args.Error(0)
+}
 func (m *MockBucket) DeleteObject(bucket, key string) error {
 	args := m.Called(bucket, key)
 	return args.Error(0)
@@ -28,3 +37,8 @@ func (m *MockBucket) GetPartOfObjectStream(bucket, key, fileRange string) (io.Re
 	args := m.Called(bucket, key, fileRange)
 	return args.Get(0).(io.ReadCloser), args.Error(1)
 }
+func (m *MockBucket) ListObjects(bucket, prefix string) ([]models.ObjectInfo, error) {
+	args := m.Called(bucket, prefix)
+	objects, _ := args.Get(0).([]models.ObjectInfo)
+	return objects, args.Error(1)
+}
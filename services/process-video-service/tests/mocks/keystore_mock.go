@@ -0,0 +1,24 @@
+package mocks
+
+import (
+	"context"
+	"process-video-service/internal/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockKeyStore struct{ mock.Mock }
+
+func (m *MockKeyStore) Save(ctx context.Context, epId, keyId string, key models.ContentKey) error {
+	args := m.Called(ctx, epId, keyId, key)
+	return args.Error(0)
+}
+func (m *MockKeyStore) Get(ctx context.Context, epId, keyId string) (*models.ContentKey, error) {
+	args := m.Called(ctx, epId, keyId)
+	key, _ := args.Get(0).(*models.ContentKey)
+	return key, args.Error(1)
+}
+func (m *MockKeyStore) Delete(ctx context.Context, epId, keyId string) error {
+	args := m.Called(ctx, epId, keyId)
+	return args.Error(0)
+}
@@ -11,4 +11,11 @@ type MockQueue struct{ mock.Mock }
 func (m *MockQueue) Consume(queue string, handler func(event models.UploadEvent, ack func(), nack func(requeue bool))) {
 	m.Called(queue, handler)
 }
+func (m *MockQueue) ConsumeClips(queue string, handler func(event models.ClipRequestEvent, ack func(), nack func(requeue bool))) {
+	m.Called(queue, handler)
+}
+func (m *MockQueue) Publish(queue string, event any) error {
+	args := m.Called(queue, event)
+	return args.Error(0)
+}
 func (m *MockQueue) Close() { m.Called() }
@@ -9,11 +9,102 @@ import (
 
 type MockVideo struct{ mock.Mock }
 
-func (m *MockVideo) Process(ctx context.Context, event models.UploadEvent, resolution int) error {
-	args := m.Called(ctx, event, resolution)
-	return args.Error(0)
+func (m *MockVideo) Process(ctx context.Context, job *models.Job, rung models.Rung) (*models.Rendition, error) {
+	args := m.Called(ctx, job, rung)
+	rendition, _ := args.Get(0).(*models.Rendition)
+	return rendition, args.Error(1)
+}
+func (m *MockVideo) ProcessAudio(ctx context.Context, job *models.Job, track models.AudioTrack) (*models.AudioRendition, error) {
+	args := m.Called(ctx, job, track)
+	rendition, _ := args.Get(0).(*models.AudioRendition)
+	return rendition, args.Error(1)
+}
+func (m *MockVideo) ProcessSubtitle(ctx context.Context, job *models.Job, track models.SubtitleTrack) (*models.SubtitleRendition, error) {
+	args := m.Called(ctx, job, track)
+	rendition, _ := args.Get(0).(*models.SubtitleRendition)
+	return rendition, args.Error(1)
+}
+func (m *MockVideo) GenerateThumbnails(ctx context.Context, job *models.Job, opts models.ThumbnailOptions) (*models.ThumbnailAssets, error) {
+	args := m.Called(ctx, job, opts)
+	assets, _ := args.Get(0).(*models.ThumbnailAssets)
+	return assets, args.Error(1)
+}
+func (m *MockVideo) GenerateStills(ctx context.Context, job *models.Job, opts models.StillOptions) ([]models.Still, error) {
+	args := m.Called(ctx, job, opts)
+	stills, _ := args.Get(0).([]models.Still)
+	return stills, args.Error(1)
+}
+func (m *MockVideo) AnalyzeComplexity(ctx context.Context, job *models.Job, opts models.ComplexityOptions) (*models.ComplexityAnalysis, error) {
+	args := m.Called(ctx, job, opts)
+	analysis, _ := args.Get(0).(*models.ComplexityAnalysis)
+	return analysis, args.Error(1)
+}
+func (m *MockVideo) MeasureQuality(ctx context.Context, job *models.Job, rendition models.Rendition, opts models.QualityOptions) (*models.QualityScores, error) {
+	args := m.Called(ctx, job, rendition, opts)
+	scores, _ := args.Get(0).(*models.QualityScores)
+	return scores, args.Error(1)
+}
+func (m *MockVideo) AnalyzeQC(ctx context.Context, job *models.Job) (*models.QCReport, error) {
+	args := m.Called(ctx, job)
+	report, _ := args.Get(0).(*models.QCReport)
+	return report, args.Error(1)
+}
+func (m *MockVideo) DetectCrop(ctx context.Context, job *models.Job, opts models.CropOptions) (*models.CropDetection, error) {
+	args := m.Called(ctx, job, opts)
+	detection, _ := args.Get(0).(*models.CropDetection)
+	return detection, args.Error(1)
+}
+func (m *MockVideo) FingerprintAudio(ctx context.Context, job *models.Job, seconds float64) (*models.AudioFingerprint, error) {
+	args := m.Called(ctx, job, seconds)
+	fingerprint, _ := args.Get(0).(*models.AudioFingerprint)
+	return fingerprint, args.Error(1)
 }
-func (m *MockVideo) GetHeight(ctx context.Context, bucket, key string) (int, error) {
+func (m *MockVideo) AnalyzeCredits(ctx context.Context, job *models.Job, seconds float64) (*models.CreditsAnalysis, error) {
+	args := m.Called(ctx, job, seconds)
+	analysis, _ := args.Get(0).(*models.CreditsAnalysis)
+	return analysis, args.Error(1)
+}
+func (m *MockVideo) DetectScenes(ctx context.Context, job *models.Job, threshold float64) ([]float64, error) {
+	args := m.Called(ctx, job, threshold)
+	cuts, _ := args.Get(0).([]float64)
+	return cuts, args.Error(1)
+}
+func (m *MockVideo) GenerateChapters(ctx context.Context, job *models.Job, chapters []models.Chapter, opts models.ChapterOptions) (*models.ChapterAssets, error) {
+	args := m.Called(ctx, job, chapters, opts)
+	assets, _ := args.Get(0).(*models.ChapterAssets)
+	return assets, args.Error(1)
+}
+func (m *MockVideo) ProcessDownload(ctx context.Context, job *models.Job, plan models.DownloadPlan) (*models.Download, error) {
+	args := m.Called(ctx, job, plan)
+	download, _ := args.Get(0).(*models.Download)
+	return download, args.Error(1)
+}
+func (m *MockVideo) MeasureMotion(ctx context.Context, job *models.Job) ([]models.MotionSample, error) {
+	args := m.Called(ctx, job)
+	samples, _ := args.Get(0).([]models.MotionSample)
+	return samples, args.Error(1)
+}
+func (m *MockVideo) GeneratePreview(ctx context.Context, job *models.Job, start float64, opts models.PreviewOptions) ([]models.PreviewClip, error) {
+	args := m.Called(ctx, job, start, opts)
+	clips, _ := args.Get(0).([]models.PreviewClip)
+	return clips, args.Error(1)
+}
+func (m *MockVideo) PrepareOverlay(ctx context.Context, job *models.Job, overlay models.Overlay) (*models.OverlayImage, error) {
+	args := m.Called(ctx, job, overlay)
+	image, _ := args.Get(0).(*models.OverlayImage)
+	return image, args.Error(1)
+}
+func (m *MockVideo) ExtractClip(ctx context.Context, plan models.ClipPlan, bucket, key string) (*models.ClipCut, error) {
+	args := m.Called(ctx, plan, bucket, key)
+	cut, _ := args.Get(0).(*models.ClipCut)
+	return cut, args.Error(1)
+}
+func (m *MockVideo) Probe(ctx context.Context, bucket, key string) (*models.MediaInfo, error) {
 	args := m.Called(ctx, bucket, key)
-	return args.Int(0), args.Error(1)
+	info, _ := args.Get(0).(*models.MediaInfo)
+	return info, args.Error(1)
+}
+func (m *MockVideo) VerifySegment(ctx context.Context, job *models.Job, key, initKey string) error {
+	args := m.Called(ctx, job, key, initKey)
+	return args.Error(0)
 }